@@ -0,0 +1,186 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/sas"
+)
+
+// Block size and parallel block uploads of Azure.Put.
+const (
+	azureBlockSize   = 8 << 20
+	azureConcurrency = 4
+)
+
+// Azure stores objects in Azure Blob Storage (or Azurite); buckets are
+// containers.
+type Azure struct {
+	client *azblob.Client
+	cred   *azblob.SharedKeyCredential // signs Presign's SAS
+}
+
+func newAzureFromEnv() (*Azure, error) {
+	connStr := getSecret("/mnt/secrets-store/azure-storage-connection-string", "AZURE_STORAGE_CONNECTION_STRING")
+	account := getSecret("/mnt/secrets-store/azure-storage-account", "AZURE_STORAGE_ACCOUNT")
+	key := getSecret("/mnt/secrets-store/azure-storage-key", "AZURE_STORAGE_KEY")
+	endpoint := ""
+	if connStr != "" {
+		// parsed here rather than by azblob.NewClientFromConnectionString,
+		// which keeps the account key to itself
+		fields := map[string]string{}
+		for _, kv := range strings.Split(connStr, ";") {
+			if k, v, ok := strings.Cut(kv, "="); ok {
+				fields[k] = v
+			}
+		}
+		account, key, endpoint = fields["AccountName"], fields["AccountKey"], fields["BlobEndpoint"]
+		if endpoint == "" {
+			proto, suffix := fields["DefaultEndpointsProtocol"], fields["EndpointSuffix"]
+			if proto == "" {
+				proto = "https"
+			}
+			if suffix == "" {
+				suffix = "core.windows.net"
+			}
+			endpoint = fmt.Sprintf("%s://%s.blob.%s", proto, account, suffix)
+		}
+	}
+	if account == "" || key == "" {
+		return nil, fmt.Errorf("missing Azure storage credentials - need either AZURE_STORAGE_CONNECTION_STRING or AZURE_STORAGE_ACCOUNT+AZURE_STORAGE_KEY")
+	}
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://%s.blob.core.windows.net", account)
+	}
+	return NewAzure(account, key, endpoint)
+}
+
+// NewAzure returns a store for the storage account; key is the base64
+// account key.
+func NewAzure(account, key, endpoint string) (*Azure, error) {
+	cred, err := azblob.NewSharedKeyCredential(account, key)
+	if err != nil {
+		return nil, fmt.Errorf("azure account key: %w", err)
+	}
+	client, err := azblob.NewClientWithSharedKeyCredential(strings.TrimRight(endpoint, "/")+"/", cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure client: %w", err)
+	}
+	return &Azure{client: client, cred: cred}, nil
+}
+
+// azureErr maps a missing blob or container to ErrNotFound.
+func azureErr(err error) error {
+	if bloberror.HasCode(err, bloberror.BlobNotFound, bloberror.ContainerNotFound, bloberror.ResourceNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+func (a *Azure) blobClient(container, key string) *blob.Client {
+	return a.client.ServiceClient().NewContainerClient(container).NewBlobClient(key)
+}
+
+func (a *Azure) Get(ctx context.Context, container, key string) ([]byte, error) {
+	rc, err := a.Open(ctx, container, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return io.ReadAll(rc)
+}
+
+func (a *Azure) Open(ctx context.Context, container, key string) (io.ReadCloser, error) {
+	resp, err := a.client.DownloadStream(ctx, container, key, nil)
+	if err != nil {
+		return nil, azureErr(err)
+	}
+	return resp.Body, nil
+}
+
+// Put uploads body in blocks, so it need not fit in memory or be seekable.
+func (a *Azure) Put(ctx context.Context, container, key string, body io.Reader, contentType string) error {
+	opts := &azblob.UploadStreamOptions{BlockSize: azureBlockSize, Concurrency: azureConcurrency}
+	if contentType != "" {
+		opts.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: &contentType}
+	}
+	_, err := a.client.UploadStream(ctx, container, key, body, opts)
+	return err
+}
+
+func (a *Azure) List(ctx context.Context, container, prefix string) ([]string, error) {
+	var keys []string
+	pager := a.client.NewListBlobsFlatPager(container, &azblob.ListBlobsFlatOptions{Prefix: &prefix})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list blobs with prefix %s: %w", prefix, err)
+		}
+		for _, b := range page.Segment.BlobItems {
+			if b.Name != nil {
+				keys = append(keys, *b.Name)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (a *Azure) Delete(ctx context.Context, container, key string) error {
+	_, err := a.client.DeleteBlob(ctx, container, key, nil)
+	if err = azureErr(err); err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+func (a *Azure) DeletePrefix(ctx context.Context, container, prefix string) error {
+	keys, err := a.List(ctx, container, prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := a.Delete(ctx, container, k); err != nil {
+			return fmt.Errorf("failed to delete blob %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func (a *Azure) Exists(ctx context.Context, container, key string) (bool, error) {
+	_, err := a.Size(ctx, container, key)
+	if err == ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (a *Azure) Size(ctx context.Context, container, key string) (int64, error) {
+	props, err := a.blobClient(container, key).GetProperties(ctx, nil)
+	if err != nil {
+		return 0, azureErr(err)
+	}
+	if props.ContentLength == nil {
+		return 0, nil
+	}
+	return *props.ContentLength, nil
+}
+
+// Presign returns the blob URL with a read-only service SAS.
+func (a *Azure) Presign(ctx context.Context, container, key string, ttl time.Duration) (string, error) {
+	qp, err := sas.BlobSignatureValues{
+		ExpiryTime:    time.Now().UTC().Add(ttl),
+		Permissions:   (&sas.BlobPermissions{Read: true}).String(),
+		ContainerName: container,
+		BlobName:      key,
+	}.SignWithSharedKey(a.cred)
+	if err != nil {
+		return "", fmt.Errorf("sign SAS for %s: %w", key, err)
+	}
+	return a.blobClient(container, key).URL() + "?" + qp.Encode(), nil
+}
@@ -0,0 +1,198 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// tempPrefix marks objects being written; they are skipped by List.
+const tempPrefix = ".blobstore-"
+
+// Local stores objects as files under root/<bucket>/<key>, for running the
+// services without object storage. Writes are atomic (temp file + rename).
+type Local struct {
+	root string
+}
+
+// NewLocal returns a store rooted at root, creating the directory.
+func NewLocal(root string) (*Local, error) {
+	if root == "" {
+		root = filepath.Join(os.TempDir(), "streamhive-blobs")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &Local{root: root}, nil
+}
+
+// path maps bucket and key to a file, rejecting names that would escape the
+// bucket directory.
+func (l *Local) path(bucket, key string) (string, error) {
+	if bucket == "" || strings.ContainsAny(bucket, `/\`) || bucket == "." || bucket == ".." {
+		return "", fmt.Errorf("invalid bucket %q", bucket)
+	}
+	if key == "" || path.Clean("/"+key) != "/"+key {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+	return filepath.Join(l.root, bucket, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (l *Local) Open(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Put(ctx context.Context, bucket, key string, body io.Reader, contentType string) error {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(p), tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
+
+func (l *Local) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	dir, err := l.path(bucket, "x")
+	if err != nil {
+		return nil, err
+	}
+	dir = filepath.Dir(dir)
+	// walk only the directory the prefix is in
+	start := dir
+	if i := strings.LastIndex(prefix, "/"); i > 0 {
+		if _, err := l.path(bucket, prefix[:i]); err != nil {
+			return nil, err
+		}
+		start = filepath.Join(dir, filepath.FromSlash(prefix[:i]))
+	}
+	var keys []string
+	err = filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), tempPrefix) {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (l *Local) Delete(ctx context.Context, bucket, key string) error {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	keys, err := l.List(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := l.Delete(ctx, bucket, k); err != nil {
+			return fmt.Errorf("delete %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func (l *Local) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(p); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (l *Local) Size(ctx context.Context, bucket, key string) (int64, error) {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Presign returns a file:// URL; files need no credentials, so ttl is
+// ignored.
+func (l *Local) Presign(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	p, err := l.path(bucket, key)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(p)}).String(), nil
+}
@@ -0,0 +1,186 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+func put(t *testing.T, s Store, bucket, key, body string) {
+	t.Helper()
+	if err := s.Put(context.Background(), bucket, key, strings.NewReader(body), "text/plain"); err != nil {
+		t.Fatalf("Put(%s/%s): %v", bucket, key, err)
+	}
+}
+
+func TestLocalRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	put(t, l, "videos", "u1/v1/master.m3u8", "#EXTM3U")
+	put(t, l, "videos", "u1/v1/master.m3u8", "#EXTM3U\n") // overwrite
+
+	got, err := l.Get(ctx, "videos", "u1/v1/master.m3u8")
+	if err != nil || string(got) != "#EXTM3U\n" {
+		t.Fatalf("Get() = %q, %v", got, err)
+	}
+	rc, err := l.Open(ctx, "videos", "u1/v1/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(b) != "#EXTM3U\n" {
+		t.Fatalf("Open() read %q, %v", b, err)
+	}
+	if ok, err := l.Exists(ctx, "videos", "u1/v1/master.m3u8"); !ok || err != nil {
+		t.Errorf("Exists() = %v, %v, want true", ok, err)
+	}
+	if n, err := l.Size(ctx, "videos", "u1/v1/master.m3u8"); n != 8 || err != nil {
+		t.Errorf("Size() = %d, %v, want 8", n, err)
+	}
+
+	if _, err := l.Get(ctx, "videos", "u1/v1/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Open(ctx, "videos", "u1/v1/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := l.Size(ctx, "videos", "u1/v1/missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Size(missing) error = %v, want ErrNotFound", err)
+	}
+	if ok, err := l.Exists(ctx, "videos", "u1/v1/missing"); ok || err != nil {
+		t.Errorf("Exists(missing) = %v, %v, want false", ok, err)
+	}
+	if err := l.Delete(ctx, "videos", "u1/v1/missing"); err != nil {
+		t.Errorf("Delete(missing) = %v, want nil", err)
+	}
+}
+
+func TestLocalListAndDeletePrefix(t *testing.T) {
+	ctx := context.Background()
+	l := newTestLocal(t)
+	for _, k := range []string{"u1/v1/720p/seg_00000.ts", "u1/v1/720p/index.m3u8", "u1/v1/master.m3u8", "u1/v10/master.m3u8", "u2/v2/master.m3u8"} {
+		put(t, l, "videos", k, k)
+	}
+	// a write in progress is not an object yet
+	if err := os.WriteFile(filepath.Join(l.root, "videos", "u1", "v1", tempPrefix+"123"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"u1/v1/", []string{"u1/v1/720p/index.m3u8", "u1/v1/720p/seg_00000.ts", "u1/v1/master.m3u8"}},
+		{"u1/v1", []string{"u1/v1/720p/index.m3u8", "u1/v1/720p/seg_00000.ts", "u1/v1/master.m3u8", "u1/v10/master.m3u8"}},
+		{"u1/v1/720p/seg", []string{"u1/v1/720p/seg_00000.ts"}},
+		{"u3/", nil},
+	}
+	for _, tt := range tests {
+		got, err := l.List(ctx, "videos", tt.prefix)
+		if err != nil {
+			t.Fatalf("List(%q): %v", tt.prefix, err)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List(%q) = %v, want %v", tt.prefix, got, tt.want)
+		}
+	}
+
+	if err := l.DeletePrefix(ctx, "videos", "u1/v1/"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.List(ctx, "videos", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(got)
+	if want := []string{"u1/v10/master.m3u8", "u2/v2/master.m3u8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after DeletePrefix, List() = %v, want %v", got, want)
+	}
+}
+
+func TestLocalPresign(t *testing.T) {
+	l := newTestLocal(t)
+	put(t, l, "raw", "u1/source.mp4", "video")
+	s, err := l.Presign(context.Background(), "raw", "u1/source.mp4", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Scheme != "file" {
+		t.Fatalf("Presign() = %q, want a file URL", s)
+	}
+	if b, err := os.ReadFile(filepath.FromSlash(u.Path)); err != nil || string(b) != "video" {
+		t.Errorf("presigned file holds %q, %v", b, err)
+	}
+}
+
+func TestLocalRejectsEscapingNames(t *testing.T) {
+	ctx := context.Background()
+	parent := t.TempDir()
+	l, err := NewLocal(filepath.Join(parent, "root"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(parent, "secret"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct{ bucket, key string }{
+		{"videos", "../../secret"},
+		{"videos", "a/../../../secret"},
+		{"videos", "/abs"},
+		{"videos", "a//b"},
+		{"videos", ""},
+		{"..", "secret"},
+		{"a/b", "key"},
+		{"", "key"},
+	}
+	for _, tt := range tests {
+		if err := l.Put(ctx, tt.bucket, tt.key, strings.NewReader("x"), ""); err == nil {
+			t.Errorf("Put(%q, %q) succeeded", tt.bucket, tt.key)
+		}
+		if _, err := l.Get(ctx, tt.bucket, tt.key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Get(%q, %q) error = %v, want an invalid name error", tt.bucket, tt.key, err)
+		}
+		if _, err := l.Exists(ctx, tt.bucket, tt.key); err == nil {
+			t.Errorf("Exists(%q, %q) succeeded", tt.bucket, tt.key)
+		}
+		if _, err := l.Size(ctx, tt.bucket, tt.key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Size(%q, %q) error = %v, want an invalid name error", tt.bucket, tt.key, err)
+		}
+		if err := l.Delete(ctx, tt.bucket, tt.key); err == nil {
+			t.Errorf("Delete(%q, %q) succeeded", tt.bucket, tt.key)
+		}
+		if _, err := l.Presign(ctx, tt.bucket, tt.key, time.Minute); err == nil {
+			t.Errorf("Presign(%q, %q) succeeded", tt.bucket, tt.key)
+		}
+	}
+	if _, err := l.List(ctx, "videos", "../../"); err == nil {
+		t.Error("List(../../) succeeded")
+	}
+	if err := l.DeletePrefix(ctx, "videos", "../x/"); err == nil {
+		t.Error("DeletePrefix(../x/) succeeded")
+	}
+	if b, err := os.ReadFile(filepath.Join(parent, "secret")); err != nil || string(b) != "secret" {
+		t.Errorf("file outside the root changed: %q, %v", b, err)
+	}
+}
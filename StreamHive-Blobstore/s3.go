@@ -0,0 +1,242 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	s3 "github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Part size and parallel part transfers of S3.Put and S3.Get.
+const (
+	s3PartSize    = 16 << 20
+	s3Concurrency = 4
+)
+
+// S3 stores objects in S3 or MinIO.
+type S3 struct {
+	client     *s3.Client
+	presign    *s3.PresignClient
+	uploader   *manager.Uploader
+	downloader *manager.Downloader
+	// stream fetches one part at a time, in order, for Open
+	stream *manager.Downloader
+}
+
+func newS3FromEnv(ctx context.Context) (*S3, error) {
+	endpoint := getSecret("/mnt/secrets-store/minio-endpoint", "MINIO_ENDPOINT")
+	access := getSecret("/mnt/secrets-store/minio-access-key", "MINIO_ACCESS_KEY")
+	secret := getSecret("/mnt/secrets-store/minio-secret-key", "MINIO_SECRET_KEY")
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	var opts []func(*config.LoadOptions) error
+	var url string
+	if endpoint != "" {
+		// Use static creds and custom endpoint for MinIO
+		port := getSecret("/mnt/secrets-store/minio-port", "MINIO_PORT")
+		if port == "" {
+			port = "9000"
+		}
+		scheme := "http"
+		if strings.EqualFold(os.Getenv("MINIO_USE_SSL"), "true") {
+			scheme = "https"
+		}
+		url = fmt.Sprintf("%s://%s:%s", scheme, endpoint, port)
+		opts = append(opts,
+			config.WithRegion(region),
+			config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(access, secret, "")),
+		)
+	}
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if url != "" {
+			o.BaseEndpoint = aws.String(url)
+		}
+		// allow path style for MinIO
+		o.UsePathStyle = true
+	})
+	return &S3{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		uploader: manager.NewUploader(client, func(u *manager.Uploader) {
+			u.PartSize = s3PartSize
+			u.Concurrency = s3Concurrency
+		}),
+		downloader: manager.NewDownloader(client, func(d *manager.Downloader) {
+			d.PartSize = s3PartSize
+			d.Concurrency = s3Concurrency
+		}),
+		stream: manager.NewDownloader(client, func(d *manager.Downloader) {
+			d.PartSize = s3PartSize
+			d.Concurrency = 1
+		}),
+	}, nil
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "NotFound") || strings.Contains(err.Error(), "NoSuchKey")
+}
+
+func (s *S3) Get(ctx context.Context, bucket, key string) ([]byte, error) {
+	buf := manager.NewWriteAtBuffer([]byte{})
+	_, err := s.downloader.Download(ctx, buf, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Open streams the object through a pipe, downloading it part by part. It
+// returns once the first part arrived, so a missing object is reported here
+// rather than by the reader.
+func (s *S3) Open(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	pr, pw := io.Pipe()
+	w := &pipeWriterAt{pw: pw, started: make(chan struct{})}
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.stream.Download(ctx, w, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+		if err != nil && isNotFound(err) {
+			err = ErrNotFound
+		}
+		done <- err
+		pw.CloseWithError(err)
+	}()
+	select {
+	case <-w.started:
+	case err := <-done:
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+	}
+	return &cancelReadCloser{ReadCloser: pr, cancel: cancel}, nil
+}
+
+// pipeWriterAt writes the in-order parts of a single-part-at-a-time
+// download into a pipe.
+type pipeWriterAt struct {
+	pw      *io.PipeWriter
+	off     int64
+	once    sync.Once
+	started chan struct{} // closed at the first write
+}
+
+func (w *pipeWriterAt) WriteAt(p []byte, off int64) (int, error) {
+	w.once.Do(func() { close(w.started) })
+	if off != w.off {
+		return 0, fmt.Errorf("out of order part at offset %d, expected %d", off, w.off)
+	}
+	n, err := w.pw.Write(p)
+	w.off += int64(n)
+	return n, err
+}
+
+// cancelReadCloser stops the download behind an Open reader when it is
+// closed early.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// Put uploads body in parts, so it need not fit in memory or be seekable.
+func (s *S3) Put(ctx context.Context, bucket, key string, body io.Reader, contentType string) error {
+	in := &s3.PutObjectInput{Bucket: aws.String(bucket), Key: aws.String(key), Body: body}
+	if contentType != "" {
+		in.ContentType = aws.String(contentType)
+	}
+	_, err := s.uploader.Upload(ctx, in)
+	return err
+}
+
+func (s *S3) List(ctx context.Context, bucket, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{Bucket: aws.String(bucket), Prefix: aws.String(prefix)})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list objects with prefix %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			if obj.Key != nil {
+				keys = append(keys, *obj.Key)
+			}
+		}
+	}
+	return keys, nil
+}
+
+func (s *S3) Delete(ctx context.Context, bucket, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil && isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *S3) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	keys, err := s.List(ctx, bucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.Delete(ctx, bucket, k); err != nil {
+			return fmt.Errorf("failed to delete object %s: %w", k, err)
+		}
+	}
+	return nil
+}
+
+func (s *S3) Exists(ctx context.Context, bucket, key string) (bool, error) {
+	_, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *S3) Size(ctx context.Context, bucket, key string) (int64, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)})
+	if err != nil {
+		if isNotFound(err) {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return aws.ToInt64(out.ContentLength), nil
+}
+
+func (s *S3) Presign(ctx context.Context, bucket, key string, ttl time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{Bucket: aws.String(bucket), Key: aws.String(key)}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", err
+	}
+	return req.URL, nil
+}
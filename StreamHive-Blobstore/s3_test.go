@@ -0,0 +1,39 @@
+package blobstore
+
+import (
+	"io"
+	"testing"
+)
+
+func TestPipeWriterAt(t *testing.T) {
+	pr, pw := io.Pipe()
+	w := &pipeWriterAt{pw: pw, started: make(chan struct{})}
+	go func() {
+		for _, part := range []struct {
+			data string
+			off  int64
+		}{{"hello ", 0}, {"world", 6}} {
+			if _, err := w.WriteAt([]byte(part.data), part.off); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.Close()
+	}()
+	got, err := io.ReadAll(pr)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(got) != "hello world" {
+		t.Errorf("read %q, want %q", got, "hello world")
+	}
+	select {
+	case <-w.started:
+	default:
+		t.Error("started not closed after the first write")
+	}
+
+	if _, err := w.WriteAt([]byte("x"), 0); err == nil {
+		t.Error("WriteAt at a past offset succeeded, want an error")
+	}
+}
@@ -0,0 +1,87 @@
+// Package blobstore is the object storage the StreamHive services share:
+// one interface over S3/MinIO, Azure Blob Storage and a local directory,
+// selected with STORAGE_BACKEND. The services use this module through a
+// replace directive pointing at ../StreamHive-Blobstore, so their Docker
+// images are built from the repository root.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Store holds objects in named buckets (Azure containers, directories for
+// the local backend). Keys are slash separated.
+type Store interface {
+	// Get returns the content of an object.
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+	// Open streams an object; the caller closes the reader.
+	Open(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	// Put creates or replaces an object, streaming body.
+	Put(ctx context.Context, bucket, key string, body io.Reader, contentType string) error
+	// List returns the keys under prefix.
+	List(ctx context.Context, bucket, prefix string) ([]string, error)
+	// Delete removes an object; a missing object is not an error.
+	Delete(ctx context.Context, bucket, key string) error
+	// DeletePrefix removes every object under prefix.
+	DeletePrefix(ctx context.Context, bucket, prefix string) error
+	// Exists reports whether an object exists.
+	Exists(ctx context.Context, bucket, key string) (bool, error)
+	// Size returns the length of an object in bytes.
+	Size(ctx context.Context, bucket, key string) (int64, error)
+	// Presign returns a URL that reads the object without credentials
+	// until ttl has passed (a file:// URL for the local backend).
+	Presign(ctx context.Context, bucket, key string, ttl time.Duration) (string, error)
+}
+
+// ErrNotFound is returned by Get, Open and Size when the object does not
+// exist.
+var ErrNotFound = errors.New("blob not found")
+
+const (
+	BackendS3    = "s3"
+	BackendAzure = "azure"
+	BackendLocal = "local"
+)
+
+// Backend returns the configured STORAGE_BACKEND, s3 by default.
+func Backend() string {
+	if b := os.Getenv("STORAGE_BACKEND"); b != "" {
+		return strings.ToLower(b)
+	}
+	return BackendS3
+}
+
+// FromEnv opens the store selected by STORAGE_BACKEND:
+//
+//	s3     MINIO_ENDPOINT, MINIO_PORT, MINIO_USE_SSL, MINIO_ACCESS_KEY,
+//	       MINIO_SECRET_KEY, AWS_REGION; without MINIO_ENDPOINT the default
+//	       AWS configuration is used
+//	azure  AZURE_STORAGE_CONNECTION_STRING, or AZURE_STORAGE_ACCOUNT and
+//	       AZURE_STORAGE_KEY
+//	local  LOCAL_STORAGE_ROOT (default $TMPDIR/streamhive-blobs)
+func FromEnv(ctx context.Context) (Store, error) {
+	switch b := Backend(); b {
+	case BackendS3:
+		return newS3FromEnv(ctx)
+	case BackendAzure:
+		return newAzureFromEnv()
+	case BackendLocal:
+		return NewLocal(os.Getenv("LOCAL_STORAGE_ROOT"))
+	default:
+		return nil, fmt.Errorf("unknown STORAGE_BACKEND %q", b)
+	}
+}
+
+// getSecret reads a mounted secret, falling back to the environment.
+func getSecret(filePath, envVar string) string {
+	if data, err := os.ReadFile(filePath); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	return os.Getenv(envVar)
+}
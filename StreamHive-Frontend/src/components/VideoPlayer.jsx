@@ -77,6 +77,14 @@ export default function VideoPlayer({ uploadId }) {
       const hls = new Hls({
         enableWorker: true,
         lowLatencyMode: false,
+        // Content keys of encrypted private videos are only handed to their owner
+        xhrSetup: (xhr, url) => {
+          const jwt = window.runtimeConfig.VITE_JWT;
+          if (jwt && /\/keys\/[0-9]+\.key$/.test(url)) {
+            xhr.open('GET', url, true);
+            xhr.setRequestHeader('Authorization', `Bearer ${jwt}`);
+          }
+        },
         // you can tune here (maxBufferLength, capLevelToPlayerSize, etc.)
       });
       hlsRef.current = hls;
@@ -48,9 +48,14 @@ func main() {
 	r.GET("/health", h.Ready)
 	r.GET("/playback/videos/:uploadId", h.GetDescriptor)
 	r.GET("/playback/videos/:uploadId/master.m3u8", h.GetMaster)
+	r.GET("/playback/videos/:uploadId/manifest.mpd", h.GetManifest)
 	r.GET("/playback/videos/:uploadId/:rendition/index.m3u8", h.GetVariant)
 	r.GET("/playback/videos/:uploadId/:rendition/:segment", h.GetSegment)
 	r.GET("/playback/videos/:uploadId/thumbnail.jpg", h.GetThumbnail)
+	r.GET("/playback/videos/:uploadId/thumbnails/:file", h.GetThumbnailTrack)
+	r.GET("/playback/videos/:uploadId/preview", h.GetPreview)
+	r.GET("/playback/videos/:uploadId/subtitles/:track/:file", h.GetSubtitles)
+	r.GET("/playback/videos/:uploadId/keys/:key", h.GetKey)
 
 	port := getEnv("PORT", "8090")
 	srv := &http.Server{Addr: ":" + port, Handler: r, ReadHeaderTimeout: 10 * time.Second}
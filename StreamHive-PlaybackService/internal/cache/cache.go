@@ -72,6 +72,19 @@ func (c *CacheService) Set(ctx context.Context, key string, value []byte) error
 	return nil
 }
 
+// SetWithTTL stores value like Set but with its own expiry, for objects
+// that rarely change and are worth keeping longer than CACHE_TTL.
+func (c *CacheService) SetWithTTL(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	err := c.client.Set(ctx, key, value, ttl).Err()
+	if err != nil {
+		c.logger.Errorw("Cache set error", "key", key, "error", err)
+		return err
+	}
+
+	c.logger.Debugw("Cache set", "key", key, "size", len(value), "ttl", ttl)
+	return nil
+}
+
 func (c *CacheService) GenerateKey(prefix, uploadID, path string) string {
 	// Create a hash-based key to avoid key length issues
 	hash := md5.Sum([]byte(fmt.Sprintf("%s:%s:%s", prefix, uploadID, path)))
@@ -10,26 +10,32 @@ import (
 
 // Minimal video model for read-only playback lookup.
 type Video struct {
-	ID               uint      `gorm:"primaryKey" json:"id"`
-	UploadID         string    `gorm:"uniqueIndex" json:"upload_id"`
-	UserID           string    `json:"user_id"`
-	Title            string    `json:"title"`
-	Description      string    `json:"description"`
-	Tags             string    `json:"-" gorm:"type:text[]"`
-	TagsList         []string  `json:"tags" gorm:"-"`
-	IsPrivate        bool      `json:"is_private"`
-	Category         string    `json:"category"`
-	OriginalFilename string    `json:"original_filename"`
-	HLSMasterURL     string    `json:"hls_master_url"`
-	ThumbnailURL     string    `json:"thumbnail_url"`
-	Duration         float64   `json:"duration"`
-	CreatedAt        time.Time `json:"created_at"`
-	UpdatedAt        time.Time `json:"updated_at"`
+	ID                   uint      `gorm:"primaryKey" json:"id"`
+	UploadID             string    `gorm:"uniqueIndex" json:"upload_id"`
+	UserID               string    `json:"user_id"`
+	Title                string    `json:"title"`
+	Description          string    `json:"description"`
+	Tags                 string    `json:"-" gorm:"type:text[]"`
+	TagsList             []string  `json:"tags" gorm:"-"`
+	IsPrivate            bool      `json:"is_private"`
+	Category             string    `json:"category"`
+	OriginalFilename     string    `json:"original_filename"`
+	HLSMasterURL         string    `json:"hls_master_url"`
+	DASHManifestURL      string    `json:"dash_manifest_url"`
+	ThumbnailURL         string    `json:"thumbnail_url"`
+	ThumbnailTrackURL    string    `json:"thumbnail_track_url"`
+	PreviewURL           string    `json:"preview_url"`
+	CaptionLanguages     string    `json:"-" gorm:"type:text[]"`
+	CaptionLanguagesList []string  `json:"caption_languages" gorm:"-"`
+	Duration             float64   `json:"duration"`
+	CreatedAt            time.Time `json:"created_at"`
+	UpdatedAt            time.Time `json:"updated_at"`
 }
 
-// AfterFind hook to convert Tags to TagsList after database query
+// AfterFind hook to convert the array columns after database query
 func (v *Video) AfterFind(tx *gorm.DB) error {
 	v.TagsList = convertPostgresArrayToSlice(v.Tags)
+	v.CaptionLanguagesList = convertPostgresArrayToSlice(v.CaptionLanguages)
 	return nil
 }
 
@@ -10,16 +10,13 @@ import (
 	"path"
 	"regexp"
 	"strings"
+	"time"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
-	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
-	s3 "github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/streamhive/blobstore"
 	"github.com/streamhive/playback-service/internal/cache"
 	"github.com/streamhive/playback-service/internal/models"
 )
@@ -36,10 +33,12 @@ type Handler struct {
 	db         *gorm.DB
 	log        *zap.SugaredLogger
 	client     *http.Client
-	s3client   *s3.Client
-	downloader *manager.Downloader
+	store      blobstore.Store // nil: fetch blobs from their public URLs
 	bucket     string
-	cache      *cache.CacheService
+	keysBucket string // HLS content keys
+	// securityURL is SecurityService's token validation endpoint
+	securityURL string
+	cache       *cache.CacheService
 }
 
 func NewHandler(db *gorm.DB, log *zap.SugaredLogger) *Handler {
@@ -51,60 +50,47 @@ func NewHandler(db *gorm.DB, log *zap.SugaredLogger) *Handler {
 		cacheService = nil // Continue without cache
 	}
 
-	// Initialize S3/MinIO client if credentials are provided via mounted secrets or env
-	endpoint := getSecret("/mnt/secrets-store/minio-endpoint", "MINIO_ENDPOINT")
-	access := getSecret("/mnt/secrets-store/minio-access-key", "MINIO_ACCESS_KEY")
-	secret := getSecret("/mnt/secrets-store/minio-secret-key", "MINIO_SECRET_KEY")
 	bucket := getSecret("/mnt/secrets-store/minio-processed-bucket", "MINIO_PROCESSED_BUCKET")
 	if bucket == "" {
 		bucket = os.Getenv("MINIO_RAW_BUCKET")
 	}
-	if endpoint != "" && access != "" && secret != "" {
-		port := getSecret("/mnt/secrets-store/minio-port", "MINIO_PORT")
-		if port == "" {
-			port = os.Getenv("MINIO_PORT")
-		}
-		if port == "" {
-			port = "9000"
-		}
-		url := fmt.Sprintf("http://%s:%s", endpoint, port)
-		resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
-			return aws.Endpoint{URL: url, SigningRegion: region, HostnameImmutable: true}, nil
-		})
-		cfg, err := config.LoadDefaultConfig(ctx, config.WithEndpointResolverWithOptions(resolver), config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(access, secret, "")))
-		if err != nil {
-			log.Errorw("s3 config", "err", err)
-		} else {
-			client := s3.NewFromConfig(cfg, func(o *s3.Options) { o.UsePathStyle = true })
-			downloader := manager.NewDownloader(client)
-			// Only set bucket if provided
-			if bucket == "" {
-				bucket = "raw-videos"
-			}
-			// attach s3 client to handler and continue
-			// fallthrough to normal return below
-			handler := &Handler{
-				db:         db,
-				log:        log,
-				client:     &http.Client{},
-				s3client:   client,
-				downloader: downloader,
-				bucket:     bucket,
-				cache:      cacheService,
-			}
-			return handler
-		}
-	} else {
-		log.Warn("S3/MinIO client not initialized; playback will fallback to public URLs for blobs")
+	keysBucket := getSecret("/mnt/secrets-store/minio-keys-bucket", "MINIO_KEYS_BUCKET")
+	if keysBucket == "" {
+		keysBucket = "hls-keys"
+	}
+	securityURL := os.Getenv("SECURITY_SERVICE_URL")
+	if securityURL == "" {
+		securityURL = "http://security-service:8080/api/auth/validate"
+	}
+	handler := &Handler{
+		db:          db,
+		log:         log,
+		client:      &http.Client{},
+		keysBucket:  keysBucket,
+		securityURL: securityURL,
+		cache:       cacheService,
 	}
 
-	_ = ctx // reserved
-	return &Handler{
-		db:     db,
-		log:    log,
-		client: &http.Client{},
-		cache:  cacheService,
+	// Without MinIO credentials the s3 backend falls back to public URLs
+	if blobstore.Backend() == blobstore.BackendS3 &&
+		(getSecret("/mnt/secrets-store/minio-endpoint", "MINIO_ENDPOINT") == "" ||
+			getSecret("/mnt/secrets-store/minio-access-key", "MINIO_ACCESS_KEY") == "" ||
+			getSecret("/mnt/secrets-store/minio-secret-key", "MINIO_SECRET_KEY") == "") {
+		log.Warn("S3/MinIO client not initialized; playback will fallback to public URLs for blobs")
+		return handler
 	}
+	store, err := blobstore.FromEnv(ctx)
+	if err != nil {
+		log.Errorw("blob store init", "err", err, "backend", blobstore.Backend())
+		return handler
+	}
+	// Only set bucket if provided
+	if bucket == "" {
+		bucket = "raw-videos"
+	}
+	handler.store = store
+	handler.bucket = bucket
+	return handler
 }
 
 // GET /playback/videos/:uploadId
@@ -115,7 +101,7 @@ func (h *Handler) GetDescriptor(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{
+	desc := gin.H{
 		"uploadId":    v.UploadID,
 		"title":       v.Title,
 		"description": v.Description,
@@ -125,10 +111,28 @@ func (h *Handler) GetDescriptor(c *gin.Context) {
 		"hls": gin.H{
 			"master": c.FullPath() + "/master.m3u8", // will rewrite below
 		},
-	})
+	}
+	if v.DASHManifestURL != "" {
+		desc["dash"] = gin.H{
+			"manifest": c.FullPath() + "/manifest.mpd",
+		}
+	}
+	if v.PreviewURL != "" {
+		desc["preview"] = c.FullPath() + "/preview"
+	}
+	if v.ThumbnailTrackURL != "" {
+		desc["thumbnails"] = gin.H{
+			"track": c.FullPath() + "/thumbnails/" + thumbnailTrackName,
+		}
+	}
+	if len(v.CaptionLanguagesList) > 0 {
+		// the tracks themselves are listed in the master playlist
+		desc["captions"] = v.CaptionLanguagesList
+	}
+	c.JSON(http.StatusOK, desc)
 }
 
-// Proxy master playlist; rewrite variant URIs to proxy endpoints.
+// Proxy master playlist; rewrite variant and audio URIs to proxy endpoints.
 func (h *Handler) GetMaster(c *gin.Context) {
 	uploadID := c.Param("uploadId")
 	var v models.Video
@@ -140,7 +144,7 @@ func (h *Handler) GetMaster(c *gin.Context) {
 		c.String(http.StatusBadRequest, "master not ready")
 		return
 	}
-	if h.s3client == nil {
+	if h.store == nil {
 		// fallback to original HTTP (likely public) path
 		resp, err := h.client.Get(v.HLSMasterURL)
 		if err != nil {
@@ -150,14 +154,8 @@ func (h *Handler) GetMaster(c *gin.Context) {
 		}
 		defer resp.Body.Close()
 		b, _ := io.ReadAll(resp.Body)
-		// Naive rewrite of rendition lines (<res>/index.m3u8)
-		re := regexp.MustCompile(`(?m)^(1080p|720p|480p|360p)/index.m3u8$`)
-		rewritten := re.ReplaceAllStringFunc(string(b), func(s string) string {
-			parts := strings.Split(s, "/")
-			return path.Join(parts[0], "index.m3u8")
-		})
 		c.Header("Content-Type", "application/vnd.apple.mpegurl")
-		c.String(http.StatusOK, rewritten)
+		c.String(http.StatusOK, rewriteMaster(string(b)))
 		return
 	}
 	// Private: fetch blob path derived from stored URL
@@ -168,13 +166,72 @@ func (h *Handler) GetMaster(c *gin.Context) {
 		c.String(http.StatusBadGateway, "blob error")
 		return
 	}
-	// Naive rewrite of rendition lines (<res>/index.m3u8)
-	re := regexp.MustCompile(`(?m)^(1080p|720p|480p|360p)/index.m3u8$`)
-	rewritten := re.ReplaceAllStringFunc(string(data), func(s string) string {
-		parts := strings.Split(s, "/")
-		return path.Join(parts[0], "index.m3u8")
-	})
 	c.Header("Content-Type", "application/vnd.apple.mpegurl")
+	c.String(http.StatusOK, rewriteMaster(string(data)))
+}
+
+// masterURIRe matches rendition playlist references in a master playlist:
+// variant URI lines and the URI attribute of EXT-X-MEDIA tags, relative or
+// absolute.
+var masterURIRe = regexp.MustCompile(`(?m)(^|URI=")([^"\s]*)/index\.m3u8`)
+
+// rewriteMaster points every rendition playlist referenced by a master
+// playlist, video and audio alike, at the variant proxy endpoint
+// (<rendition>/index.m3u8, relative to the master), and caption tracks at
+// the subtitles endpoint (subtitles/<track>/index.m3u8). Unknown names are
+// left as they are.
+func rewriteMaster(master string) string {
+	return masterURIRe.ReplaceAllStringFunc(master, func(s string) string {
+		m := masterURIRe.FindStringSubmatch(s)
+		dir := path.Base(m[2])
+		switch {
+		case allowedRendition(dir):
+			return m[1] + path.Join(dir, "index.m3u8")
+		case path.Base(path.Dir(m[2])) == subtitleDir && subtitleTrackRe.MatchString(dir):
+			return m[1] + path.Join(subtitleDir, dir, "index.m3u8")
+		}
+		return s
+	})
+}
+
+// absoluteBaseURLRe matches MPD BaseURL elements pointing straight at storage.
+var absoluteBaseURLRe = regexp.MustCompile(`(?m)^\s*<BaseURL>https?://[^<]*</BaseURL>\s*\n?`)
+
+// GetManifest proxies the DASH MPD. Segment references in the MPD are
+// relative (<rendition>/init.mp4, dash/chunk-...), so once any absolute
+// storage BaseURL is dropped they resolve to the segment proxy endpoints.
+func (h *Handler) GetManifest(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	var v models.Video
+	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	if v.DASHManifestURL == "" {
+		c.String(http.StatusNotFound, "dash manifest not available")
+		return
+	}
+	var data []byte
+	if h.store == nil {
+		resp, err := h.client.Get(v.DASHManifestURL)
+		if err != nil {
+			h.log.Errorw("fetch mpd", "err", err)
+			c.String(http.StatusBadGateway, "upstream error")
+			return
+		}
+		defer resp.Body.Close()
+		data, _ = io.ReadAll(resp.Body)
+	} else {
+		var err error
+		data, err = h.downloadBlob(c, h.extractBlobPath(v.DASHManifestURL))
+		if err != nil {
+			h.log.Errorw("mpd download", "err", err)
+			c.String(http.StatusBadGateway, "blob error")
+			return
+		}
+	}
+	rewritten := absoluteBaseURLRe.ReplaceAllString(string(data), "")
+	c.Header("Content-Type", "application/dash+xml")
 	c.String(http.StatusOK, rewritten)
 }
 
@@ -191,7 +248,7 @@ func (h *Handler) GetVariant(c *gin.Context) {
 		c.String(http.StatusNotFound, "not found")
 		return
 	}
-	if h.s3client != nil { // private blob path
+	if h.store != nil { // private blob path
 		base := h.blobBase(v.HLSMasterURL)
 		blobPath := base + "/" + rendition + "/index.m3u8"
 		data, err := h.downloadBlob(c, blobPath)
@@ -201,12 +258,12 @@ func (h *Handler) GetVariant(c *gin.Context) {
 			return
 		}
 		c.Header("Content-Type", "application/vnd.apple.mpegurl")
-		c.String(http.StatusOK, string(data))
+		c.String(http.StatusOK, rewriteKeys(string(data)))
 		return
 	}
 	base := baseHLSPath(v.HLSMasterURL)
 	url := base + "/" + rendition + "/index.m3u8"
-	proxyM3U8(c, h.client, url)
+	proxyM3U8(c, h.client, url, rewriteKeys)
 }
 
 // Segment
@@ -214,7 +271,7 @@ func (h *Handler) GetSegment(c *gin.Context) {
 	uploadID := c.Param("uploadId")
 	rendition := c.Param("rendition")
 	segment := c.Param("segment")
-	if !allowedRendition(rendition) || !strings.HasSuffix(segment, ".ts") && !strings.HasSuffix(segment, ".m4s") {
+	if !(allowedRendition(rendition) || rendition == dashSegmentDir) || segmentContentType(segment) == "" {
 		c.String(http.StatusBadRequest, "invalid segment")
 		return
 	}
@@ -223,7 +280,7 @@ func (h *Handler) GetSegment(c *gin.Context) {
 		c.String(http.StatusNotFound, "not found")
 		return
 	}
-	if h.s3client != nil { // private
+	if h.store != nil { // private
 		base := h.blobBase(v.HLSMasterURL)
 		blobPath := base + "/" + rendition + "/" + segment
 
@@ -257,12 +314,7 @@ func (h *Handler) GetSegment(c *gin.Context) {
 			}
 		}
 
-		// Basic content-type guess
-		if strings.HasSuffix(segment, ".m3u8") {
-			c.Header("Content-Type", "application/vnd.apple.mpegurl")
-		} else {
-			c.Header("Content-Type", "video/MP2T")
-		}
+		c.Header("Content-Type", segmentContentType(segment))
 		c.Header("Cache-Control", "public, max-age=60")
 		c.Data(http.StatusOK, c.Writer.Header().Get("Content-Type"), data)
 		return
@@ -286,9 +338,11 @@ func (h *Handler) GetThumbnail(c *gin.Context) {
 		return
 	}
 
-	if h.s3client != nil {
-		// Private blob: serve from S3/MinIO storage with caching
-		thumbnailPath := fmt.Sprintf("thumbnails/%s/%s.jpg", v.UserID, v.UploadID)
+	if h.store != nil {
+		// Private blob: serve from S3/MinIO storage with caching. The path
+		// follows the stored URL, which changes when the owner picks another
+		// poster candidate, so the cache key changes with it.
+		thumbnailPath := h.extractBlobPath(v.ThumbnailURL)
 
 		// Try cache first
 		var data []byte
@@ -330,12 +384,205 @@ func (h *Handler) GetThumbnail(c *gin.Context) {
 	c.Redirect(http.StatusFound, v.ThumbnailURL)
 }
 
+// thumbnailTrackName is the WebVTT scrub-preview track; its cues reference
+// sprite sheets in the same directory.
+const thumbnailTrackName = "thumbnails.vtt"
+
+// spriteRe matches the sprite sheets written by the transcoder.
+var spriteRe = regexp.MustCompile(`^sprite_[0-9]{3,}\.jpg$`)
+
+// GetThumbnailTrack serves the scrub-preview WebVTT track and the sprite
+// sheets it references, cached like the poster thumbnail.
+func (h *Handler) GetThumbnailTrack(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	file := c.Param("file")
+	contentType := "image/jpeg"
+	if file == thumbnailTrackName {
+		contentType = "text/vtt"
+	} else if !spriteRe.MatchString(file) {
+		c.String(http.StatusBadRequest, "invalid thumbnails file")
+		return
+	}
+	var v models.Video
+	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+		c.String(http.StatusNotFound, "Video not found")
+		return
+	}
+	if v.ThumbnailTrackURL == "" {
+		c.String(http.StatusNotFound, "Thumbnails track not available")
+		return
+	}
+
+	if h.store != nil {
+		blobPath := fmt.Sprintf("thumbnails/%s/%s/%s", v.UserID, v.UploadID, file)
+		data, err := h.cachedBlob(c, "thumbnail", uploadID, blobPath, 0)
+		if err != nil {
+			h.log.Errorw("thumbnails download", "err", err, "file", file)
+			c.String(http.StatusNotFound, "Thumbnails file not found")
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=3600")
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+
+	// Public blob: redirect next to the stored track URL
+	base := strings.TrimSuffix(v.ThumbnailTrackURL, "/"+thumbnailTrackName)
+	c.Redirect(http.StatusFound, base+"/"+file)
+}
+
+// subtitleDir holds the WebVTT caption tracks next to the renditions.
+const subtitleDir = "subtitles"
+
+// subtitleTrackRe matches caption track names: a language tag, with the
+// source stream index appended when the language repeats ("en", "en_2").
+var subtitleTrackRe = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*(_[0-9]+)?$`)
+
+// subtitleSegmentRe matches the WebVTT segments of a caption track.
+var subtitleSegmentRe = regexp.MustCompile(`^seg_[0-9]{5,}\.vtt$`)
+
+// GetSubtitles serves a caption track's media playlist (index.m3u8) and its
+// WebVTT segments. Neither is kept in Redis: sidecar tracks can be replaced
+// in place, and the files are small.
+func (h *Handler) GetSubtitles(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	track := c.Param("track")
+	file := c.Param("file")
+	isPlaylist := file == "index.m3u8"
+	if !subtitleTrackRe.MatchString(track) || !(isPlaylist || subtitleSegmentRe.MatchString(file)) {
+		c.String(http.StatusBadRequest, "invalid subtitles file")
+		return
+	}
+	var v models.Video
+	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	if v.HLSMasterURL == "" {
+		c.String(http.StatusNotFound, "subtitles not available")
+		return
+	}
+
+	if h.store != nil {
+		blobPath := h.blobBase(v.HLSMasterURL) + "/" + subtitleDir + "/" + track + "/" + file
+		data, err := h.downloadBlob(c, blobPath)
+		if err != nil {
+			h.log.Errorw("subtitles download", "err", err, "track", track, "file", file)
+			c.String(http.StatusNotFound, "subtitles file not found")
+			return
+		}
+		if isPlaylist {
+			c.Header("Content-Type", "application/vnd.apple.mpegurl")
+			c.String(http.StatusOK, string(data))
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=60")
+		c.Data(http.StatusOK, "text/vtt", data)
+		return
+	}
+
+	url := baseHLSPath(v.HLSMasterURL) + "/" + subtitleDir + "/" + track + "/" + file
+	if isPlaylist {
+		proxyM3U8(c, h.client, url, nil)
+		return
+	}
+	proxyBinary(c, h.client, url)
+}
+
+// previewMaxAge is how long hover-preview clips are cached, in Redis and by
+// clients. A re-transcode writes a new clip only for a new upload ID.
+const previewMaxAge = 7 * 24 * time.Hour
+
+// GetPreview serves the hover-preview clip (MP4 or animated WebP).
+func (h *Handler) GetPreview(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	var v models.Video
+	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+		c.String(http.StatusNotFound, "Video not found")
+		return
+	}
+	if v.PreviewURL == "" {
+		c.String(http.StatusNotFound, "Preview not available")
+		return
+	}
+
+	if h.store != nil {
+		blobPath := h.extractBlobPath(v.PreviewURL)
+		data, err := h.cachedBlob(c, "preview", uploadID, blobPath, previewMaxAge)
+		if err != nil {
+			h.log.Errorw("preview download", "err", err)
+			c.String(http.StatusNotFound, "Preview not found")
+			return
+		}
+		contentType := "video/mp4"
+		if strings.HasSuffix(blobPath, ".webp") {
+			contentType = "image/webp"
+		}
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(previewMaxAge.Seconds())))
+		c.Data(http.StatusOK, contentType, data)
+		return
+	}
+
+	// Public blob: redirect to direct URL
+	c.Redirect(http.StatusFound, v.PreviewURL)
+}
+
+// cachedBlob returns blobPath from the Redis cache, downloading and caching
+// it on a miss; ttl 0 uses the cache's default. Cache errors only cost the cache.
+func (h *Handler) cachedBlob(c *gin.Context, prefix, uploadID, blobPath string, ttl time.Duration) ([]byte, error) {
+	var cacheKey string
+	if h.cache != nil {
+		cacheKey = h.cache.GenerateKey(prefix, uploadID, blobPath)
+		data, err := h.cache.Get(c.Request.Context(), cacheKey)
+		if err != nil {
+			h.log.Warnw("cache get error", "err", err)
+		}
+		if data != nil {
+			return data, nil
+		}
+	}
+	data, err := h.downloadBlob(c, blobPath)
+	if err != nil {
+		return nil, err
+	}
+	if h.cache != nil {
+		if ttl <= 0 {
+			err = h.cache.Set(c.Request.Context(), cacheKey, data)
+		} else {
+			err = h.cache.SetWithTTL(c.Request.Context(), cacheKey, data, ttl)
+		}
+		if err != nil {
+			h.log.Warnw("cache set error", "err", err)
+		}
+	}
+	return data, nil
+}
+
+// renditionRe matches rendition names produced by the transcoder: ladder
+// rungs ("720p"), native-size rungs such as "240p" for small sources, the
+// HEVC/AV1 ladders ("720p_hevc", "720p_av1") and audio renditions ("audio_0").
+var renditionRe = regexp.MustCompile(`^([0-9]{2,4}p(_(hevc|av1))?|audio_[0-9]+)$`)
+
+// dashSegmentDir holds DASH segments remuxed from MPEG-TS renditions.
+const dashSegmentDir = "dash"
+
 func allowedRendition(r string) bool {
-	switch r {
-	case "1080p", "720p", "480p", "360p":
-		return true
+	return renditionRe.MatchString(r)
+}
+
+// segmentContentType returns the MIME type for a media segment name, or ""
+// when the name is not a segment the transcoder produces: MPEG-TS segments,
+// fMP4/CMAF .m4s segments and the fMP4 init segment referenced by EXT-X-MAP.
+func segmentContentType(name string) string {
+	switch {
+	case strings.HasSuffix(name, ".ts"):
+		return "video/MP2T"
+	case strings.HasSuffix(name, ".m4s"):
+		return "video/iso.segment"
+	case name == "init.mp4":
+		return "video/mp4"
 	}
-	return false
+	return ""
 }
 
 func baseHLSPath(master string) string {
@@ -343,7 +590,8 @@ func baseHLSPath(master string) string {
 	return strings.TrimSuffix(master, "/master.m3u8")
 }
 
-func proxyM3U8(c *gin.Context, cl *http.Client, url string) {
+// proxyM3U8 relays a playlist, passed through rewrite when it is set.
+func proxyM3U8(c *gin.Context, cl *http.Client, url string, rewrite func(string) string) {
 	resp, err := cl.Get(url)
 	if err != nil {
 		c.String(http.StatusBadGateway, "upstream error")
@@ -351,8 +599,12 @@ func proxyM3U8(c *gin.Context, cl *http.Client, url string) {
 	}
 	defer resp.Body.Close()
 	b, _ := io.ReadAll(resp.Body)
+	body := string(b)
+	if rewrite != nil {
+		body = rewrite(body)
+	}
 	c.Header("Content-Type", "application/vnd.apple.mpegurl")
-	c.String(resp.StatusCode, string(b))
+	c.String(resp.StatusCode, body)
 }
 
 func proxyBinary(c *gin.Context, cl *http.Client, url string) {
@@ -379,14 +631,14 @@ func (h *Handler) Ready(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"status":
 func (h *Handler) Config(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"env": os.Environ()}) }
 
 func (h *Handler) downloadBlob(c *gin.Context, path string) ([]byte, error) {
+	return h.downloadFrom(c, h.bucket, path)
+}
+
+// downloadFrom is downloadBlob for an object in bucket.
+func (h *Handler) downloadFrom(c *gin.Context, bucket, path string) ([]byte, error) {
 	ctx := c.Request.Context()
-	if h.s3client != nil {
-		buf := manager.NewWriteAtBuffer([]byte{})
-		_, err := h.downloader.Download(ctx, buf, &s3.GetObjectInput{Bucket: aws.String(h.bucket), Key: aws.String(path)})
-		if err != nil {
-			return nil, err
-		}
-		return buf.Bytes(), nil
+	if h.store != nil {
+		return h.store.Get(ctx, bucket, path)
 	}
 	// fallback: try HTTP fetch
 	resp, err := h.client.Get(path)
@@ -0,0 +1,40 @@
+package playback
+
+import "testing"
+
+func TestRewriteMaster(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "relative variants",
+			in:   "#EXT-X-STREAM-INF:BANDWIDTH=2800000\n720p/index.m3u8\n",
+			want: "#EXT-X-STREAM-INF:BANDWIDTH=2800000\n720p/index.m3u8\n",
+		},
+		{
+			name: "absolute variants of every codec",
+			in:   "https://blob/c/u/v/1080p/index.m3u8\nhttps://blob/c/u/v/720p_hevc/index.m3u8\nhttps://blob/c/u/v/720p_av1/index.m3u8\n",
+			want: "1080p/index.m3u8\n720p_hevc/index.m3u8\n720p_av1/index.m3u8\n",
+		},
+		{
+			name: "audio media uri",
+			in:   "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",URI=\"https://blob/c/u/v/audio_0/index.m3u8\"\n",
+			want: "#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID=\"aud\",URI=\"audio_0/index.m3u8\"\n",
+		},
+		{
+			name: "subtitle track",
+			in:   "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",URI=\"https://blob/c/u/v/subtitles/en_2/index.m3u8\"\n",
+			want: "#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID=\"subs\",URI=\"subtitles/en_2/index.m3u8\"\n",
+		},
+		{
+			name: "unknown names untouched",
+			in:   "https://blob/c/u/v/secret/index.m3u8\n#EXT-X-MEDIA:TYPE=SUBTITLES,URI=\"other/en/index.m3u8\"\n",
+			want: "https://blob/c/u/v/secret/index.m3u8\n#EXT-X-MEDIA:TYPE=SUBTITLES,URI=\"other/en/index.m3u8\"\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := rewriteMaster(tt.in); got != tt.want {
+			t.Errorf("%s: rewriteMaster() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package playback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/streamhive/playback-service/internal/models"
+)
+
+// keyNameRe matches the AES-128 content keys written by the transcoder.
+var keyNameRe = regexp.MustCompile(`^[0-9]{5}\.key$`)
+
+// keyURIRe matches the URI attribute of EXT-X-KEY tags in a media playlist.
+var keyURIRe = regexp.MustCompile(`(?m)^(#EXT-X-KEY:.*URI=")[^"]*?([0-9]{5}\.key)"`)
+
+// rewriteKeys points the EXT-X-KEY URIs of a media playlist at the key
+// endpoint (keys/<name>, relative to the video like the variant endpoints).
+func rewriteKeys(playlist string) string {
+	return keyURIRe.ReplaceAllString(playlist, `${1}../keys/${2}"`)
+}
+
+var (
+	errNoToken      = errors.New("authentication required")
+	errInvalidToken = errors.New("invalid or expired token")
+)
+
+// GET /playback/videos/:uploadId/keys/:key
+//
+// GetKey hands out a content key of an encrypted video. Keys of a private
+// video go only to its owner, identified by a bearer token that
+// SecurityService validates; anyone who may watch a public video gets its
+// keys. Keys are never cached, here or by clients.
+func (h *Handler) GetKey(c *gin.Context) {
+	uploadID := c.Param("uploadId")
+	key := c.Param("key")
+	if !keyNameRe.MatchString(key) {
+		c.String(http.StatusBadRequest, "invalid key")
+		return
+	}
+	var v models.Video
+	if err := h.db.Where("upload_id = ?", uploadID).First(&v).Error; err != nil {
+		c.String(http.StatusNotFound, "not found")
+		return
+	}
+	if v.IsPrivate {
+		viewer, err := h.viewerID(c)
+		switch {
+		case errors.Is(err, errNoToken) || errors.Is(err, errInvalidToken):
+			c.String(http.StatusUnauthorized, err.Error())
+			return
+		case err != nil:
+			h.log.Errorw("token validation", "err", err)
+			c.String(http.StatusBadGateway, "auth unavailable")
+			return
+		case viewer != v.UserID:
+			c.String(http.StatusForbidden, "forbidden")
+			return
+		}
+	}
+	if h.store == nil {
+		// keys are never publicly readable, so there is no URL to fall back to
+		c.String(http.StatusServiceUnavailable, "key store unavailable")
+		return
+	}
+	data, err := h.downloadFrom(c, h.keysBucket, v.UserID+"/"+v.UploadID+"/"+key)
+	if err != nil {
+		h.log.Errorw("key download", "err", err, "uploadId", uploadID, "key", key)
+		c.String(http.StatusNotFound, "key not found")
+		return
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/octet-stream", data)
+}
+
+// viewerID validates the request's bearer token with SecurityService and
+// returns the ID of the user it belongs to.
+func (h *Handler) viewerID(c *gin.Context) (string, error) {
+	auth := c.GetHeader("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return "", errNoToken
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.securityURL, strings.NewReader("{}"))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", auth)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", errInvalidToken
+	case resp.StatusCode != http.StatusOK:
+		return "", fmt.Errorf("security service returned %d", resp.StatusCode)
+	}
+	var body struct {
+		User struct {
+			ID json.Number `json:"id"`
+		} `json:"user"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decode validation response: %w", err)
+	}
+	if body.User.ID == "" {
+		return "", errInvalidToken
+	}
+	return body.User.ID.String(), nil
+}
@@ -0,0 +1,40 @@
+package playback
+
+import "testing"
+
+func TestRewriteKeys(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{
+			name: "relative key uri",
+			in:   "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/00000.key\"\n#EXTINF:4.000,\nseg_00000.ts\n",
+			want: "#EXTM3U\n#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/00000.key\"\n#EXTINF:4.000,\nseg_00000.ts\n",
+		},
+		{
+			name: "absolute storage uri",
+			in:   "#EXT-X-KEY:METHOD=AES-128,URI=\"https://minio:9000/hls-keys/u/v/00003.key\"\n",
+			want: "#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/00003.key\"\n",
+		},
+		{
+			name: "every rotated key",
+			in:   "#EXT-X-KEY:METHOD=AES-128,URI=\"a/00000.key\"\nseg_00000.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"a/00001.key\"\n",
+			want: "#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/00000.key\"\nseg_00000.ts\n#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/00001.key\"\n",
+		},
+		{
+			name: "foreign key names untouched",
+			in:   "#EXT-X-KEY:METHOD=AES-128,URI=\"https://example.com/k.bin\"\n",
+			want: "#EXT-X-KEY:METHOD=AES-128,URI=\"https://example.com/k.bin\"\n",
+		},
+		{
+			name: "clear playlist untouched",
+			in:   "#EXTM3U\n#EXTINF:4.000,\nseg_00000.ts\n",
+			want: "#EXTM3U\n#EXTINF:4.000,\nseg_00000.ts\n",
+		},
+	}
+	for _, tt := range tests {
+		if got := rewriteKeys(tt.in); got != tt.want {
+			t.Errorf("%s: rewriteKeys() =\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// healthCheck is one dependency probed by /healthz and /readyz; check
+// returns nil when it is usable.
+type healthCheck struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+// healthHandler runs checks and answers 200 when all pass, 503 otherwise,
+// with the state of each check as JSON.
+func healthHandler(checks ...healthCheck) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+		defer cancel()
+
+		status, code := "ok", http.StatusOK
+		results := make(map[string]string, len(checks))
+		for _, c := range checks {
+			if err := c.check(ctx); err != nil {
+				results[c.name] = err.Error()
+				status, code = "unavailable", http.StatusServiceUnavailable
+				continue
+			}
+			results[c.name] = "ok"
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": results})
+	}
+}
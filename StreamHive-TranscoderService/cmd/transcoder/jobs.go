@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/streamhive/transcoder/pkg"
+)
+
+// registerJobRoutes adds the job API to the metrics server:
+//
+//	GET  /jobs                     running and recently finished jobs
+//	GET  /jobs/{uploadId}          the running, else most recent, job of an upload
+//	POST /jobs/{uploadId}/cancel   cancel a running job
+//
+// Every route needs token as a bearer token, since jobs expose user and
+// upload IDs; without one the API is not served.
+func registerJobRoutes(mux *http.ServeMux, t *pkg.Transcoder, token string) {
+	if token == "" {
+		return
+	}
+	mux.HandleFunc("GET /jobs", requireBearer(token, func(w http.ResponseWriter, r *http.Request) {
+		running, recent := t.Jobs()
+		writeJSON(w, http.StatusOK, map[string]any{"running": running, "recent": recent})
+	}))
+	mux.HandleFunc("GET /jobs/{uploadId}", requireBearer(token, func(w http.ResponseWriter, r *http.Request) {
+		job, ok := t.Job(r.PathValue("uploadId"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "job not found"})
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	}))
+	mux.HandleFunc("POST /jobs/{uploadId}/cancel", requireBearer(token, func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("uploadId")
+		if !t.CancelJob(id) {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no running job"})
+			return
+		}
+		// the job stops at its next cancellation point; poll it for the result
+		job, _ := t.Job(id)
+		writeJSON(w, http.StatusAccepted, job)
+	}))
+}
+
+// requireBearer answers 401 to requests without token as their bearer
+// token and passes the others to h.
+func requireBearer(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !bearerMatches(r, token) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid or missing token"})
+			return
+		}
+		h(w, r)
+	}
+}
+
+// bearerMatches reports whether r carries token as its bearer token.
+func bearerMatches(r *http.Request, token string) bool {
+	got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+
+	"github.com/streamhive/transcoder/pkg"
+)
+
+func TestJobRoutesAuth(t *testing.T) {
+	const token = "s3cret"
+	tests := []struct {
+		name   string
+		token  string // configured JOB_API_TOKEN
+		method string
+		path   string
+		auth   string
+		want   int
+	}{
+		{"list", token, "GET", "/jobs", "Bearer " + token, http.StatusOK},
+		{"list without token", token, "GET", "/jobs", "", http.StatusUnauthorized},
+		{"list with wrong token", token, "GET", "/jobs", "Bearer nope", http.StatusUnauthorized},
+		{"get", token, "GET", "/jobs/up1", "Bearer " + token, http.StatusNotFound},
+		{"get without token", token, "GET", "/jobs/up1", "", http.StatusUnauthorized},
+		{"cancel", token, "POST", "/jobs/up1/cancel", "Bearer " + token, http.StatusNotFound},
+		{"cancel without token", token, "POST", "/jobs/up1/cancel", "", http.StatusUnauthorized},
+		{"cancel with wrong token", token, "POST", "/jobs/up1/cancel", "Bearer " + token + "x", http.StatusUnauthorized},
+		{"cancel with basic auth", token, "POST", "/jobs/up1/cancel", "Basic " + token, http.StatusUnauthorized},
+		{"list when disabled", "", "GET", "/jobs", "Bearer ", http.StatusNotFound},
+		{"cancel when disabled", "", "POST", "/jobs/up1/cancel", "Bearer ", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			registerJobRoutes(mux, pkg.NewTranscoder(zap.NewNop().Sugar(), nil, nil, nil, nil, pkg.Options{JobHistory: 10}), tt.token)
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("401 without WWW-Authenticate: Bearer")
+			}
+		})
+	}
+}
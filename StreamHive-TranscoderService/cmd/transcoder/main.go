@@ -3,17 +3,20 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
 
+	"github.com/streamhive/transcoder/internal/ffmpeg"
 	"github.com/streamhive/transcoder/internal/queue"
 	"github.com/streamhive/transcoder/internal/storage"
 	"github.com/streamhive/transcoder/pkg"
@@ -30,8 +33,21 @@ func main() {
 
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
+	go func() {
+		// a second signal during the drain exits at once
+		<-ctx.Done()
+		stop()
+	}()
+	// runCtx outlives the drain; it ends once the consumer has settled
+	runCtx, stopRun := context.WithCancel(context.Background())
+	defer stopRun()
+
+	// ready is set while the consumer takes deliveries; it is cleared on
+	// shutdown so Kubernetes stops routing to a draining pod
+	var ready atomic.Bool
 
-	// Metrics server
+	// Metrics server; /healthz, /readyz and the /jobs API are added once
+	// their dependencies are set up and answer 404 until then
 	mux := http.NewServeMux()
 	mux.Handle("/metrics", promhttp.Handler())
 	srv := &http.Server{Addr: metricsAddr, Handler: mux}
@@ -55,27 +71,85 @@ func main() {
 	}
 	defer pub.Close()
 
-	s3client, err := storage.NewS3ClientFromEnv(ctx)
+	// publisher for progress events, on its own channel so throttled
+	// updates never interleave with the transcoded publish
+	progressPub, err := queue.NewPublisher(consumer.Conn(), consumer.Exchange(), getenv("AMQP_PROGRESS_ROUTING_KEY", "video.transcoding.progress"))
+	if err != nil {
+		log.Fatalf("progress publisher init: %v", err)
+	}
+	defer progressPub.Close()
+
+	// publisher for transcode_failed events
+	failedPub, err := queue.NewPublisher(consumer.Conn(), consumer.Exchange(), getenv("AMQP_FAILED_ROUTING_KEY", "video.transcode_failed"))
+	if err != nil {
+		log.Fatalf("failed publisher init: %v", err)
+	}
+	defer failedPub.Close()
+
+	store, err := storage.NewClientFromEnv(ctx)
 	if err != nil {
 		log.Fatalf("storage init: %v", err)
 	}
 
-	pipeline := pkg.NewTranscoder(log, s3client, pub)
+	opts, err := pkg.OptionsFromEnv()
+	if err != nil {
+		log.Fatalf("pipeline options: %v", err)
+	}
+	pipeline := pkg.NewTranscoder(log, store, pub, progressPub, failedPub, opts)
+
+	amqpCheck := healthCheck{"amqp", func(context.Context) error {
+		if !consumer.Connected() {
+			return errors.New("connection closed")
+		}
+		return nil
+	}}
+	ffmpegCheck := healthCheck{"ffmpeg", func(context.Context) error { return ffmpeg.Available() }}
+	// liveness leaves out storage, which a restart cannot fix
+	mux.Handle("/healthz", healthHandler(amqpCheck, ffmpegCheck))
+	mux.Handle("/readyz", healthHandler(amqpCheck, ffmpegCheck,
+		healthCheck{"storage", store.Ping},
+		healthCheck{"consumer", func(context.Context) error {
+			if ctx.Err() != nil {
+				return errors.New("draining")
+			}
+			if !ready.Load() {
+				return errors.New("not consuming")
+			}
+			return nil
+		}},
+	))
+
+	jobToken := os.Getenv("JOB_API_TOKEN")
+	if jobToken == "" {
+		log.Infow("JOB_API_TOKEN not set, job API disabled")
+	}
+	registerJobRoutes(mux, pipeline, jobToken)
+
+	consumer.OnParked(func(body []byte, err error) { pipeline.ReportFailure(runCtx, body, err) })
+
+	// video.deleted cancels the deleted video's job on whichever replica runs it
+	if err := consumer.Subscribe(runCtx, getenv("AMQP_DELETED_ROUTING_KEY", "video.deleted"), pipeline.HandleDeleted); err != nil {
+		log.Fatalf("video.deleted subscription: %v", err)
+	}
 
 	concurrency := queue.GetEnvInt("CONCURRENCY", 1)
+	grace := time.Duration(queue.GetEnvInt("DRAIN_TIMEOUT_SECONDS", 300)) * time.Second
 	log.Infof("starting consumer with concurrency=%d", concurrency)
 
-	err = consumer.Consume(ctx, concurrency, func(b []byte) error {
+	ready.Store(true)
+	err = consumer.Consume(ctx, concurrency, grace, func(jobCtx context.Context, b []byte) error {
 		var check map[string]any
 		if err := json.Unmarshal(b, &check); err != nil {
-			return fmt.Errorf("invalid json: %w", err)
+			return queue.Permanent(fmt.Errorf("invalid json: %w", err))
 		}
 		log.Infow("upload event", "uploadId", check["uploadId"], "userId", check["userId"])
-		return pipeline.Handle(ctx, b)
+		return pipeline.Handle(jobCtx, b)
 	})
+	ready.Store(false)
 	if err != nil {
 		log.Fatalf("consume error: %v", err)
 	}
+	stopRun()
 
 	// graceful shutdown metrics server
 	ctxTimeout, cancel := context.WithTimeout(context.Background(), 3*time.Second)
@@ -0,0 +1,153 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// AudioGroupID is the EXT-X-MEDIA GROUP-ID every video rendition's AUDIO
+// attribute references.
+const AudioGroupID = "audio"
+
+// Audio renditions are stereo AAC-LC at a single bitrate: the same track
+// serves every video rung, so there is nothing to gain from an audio ladder.
+const (
+	audioBitrate  = 128 // kbps
+	audioChannels = 2
+	// AudioCodecs is the RFC 6381 codec of the encoded audio renditions.
+	AudioCodecs = "mp4a.40.2"
+)
+
+// AudioTrack is one audio stream of the source.
+type AudioTrack struct {
+	// Index is the stream's position among the source's audio streams,
+	// i.e. N in the 0:a:N map specifier.
+	Index    int
+	Codec    string
+	Language string // ISO 639-2 tag from the source, "" when untagged
+	Title    string
+	Channels int
+	Default  bool // default disposition in the source
+}
+
+// AudioRendition is one encoded audio track. It lives in its own directory
+// next to the video renditions and is listed as EXT-X-MEDIA in the master.
+type AudioRendition struct {
+	Name     string // "audio_<index>"
+	Track    AudioTrack
+	Default  bool // DEFAULT=YES; exactly one rendition per group
+	Bitrate  int  // kbps
+	Channels int  // output channels, for the CHANNELS attribute
+	// Filter is an audio filter applied before encoding, e.g. the second
+	// loudnorm pass; empty for none.
+	Filter string
+}
+
+// Bandwidth is the nominal bitrate in bps.
+func (a AudioRendition) Bandwidth() int {
+	return a.Bitrate * 1000
+}
+
+// SelectAudio returns one rendition per source audio track, in source order.
+// The source's default track is the default rendition, or the first when
+// none is marked.
+func SelectAudio(tracks []AudioTrack) []AudioRendition {
+	out := make([]AudioRendition, 0, len(tracks))
+	def := 0
+	for i, t := range tracks {
+		if t.Default {
+			def = i
+			break
+		}
+	}
+	for i, t := range tracks {
+		out = append(out, AudioRendition{
+			Name:     fmt.Sprintf("audio_%d", t.Index),
+			Track:    t,
+			Default:  i == def,
+			Bitrate:  audioBitrate,
+			Channels: audioChannels,
+		})
+	}
+	return out
+}
+
+// languages maps common ISO 639-2 codes to their RFC 5646 primary tag and
+// English name. Codes missing here are used as is; three letter tags are
+// valid RFC 5646 too.
+var languages = map[string]struct{ tag, name string }{
+	"ara": {"ar", "Arabic"},
+	"chi": {"zh", "Chinese"}, "zho": {"zh", "Chinese"},
+	"dut": {"nl", "Dutch"}, "nld": {"nl", "Dutch"},
+	"eng": {"en", "English"},
+	"fre": {"fr", "French"}, "fra": {"fr", "French"},
+	"ger": {"de", "German"}, "deu": {"de", "German"},
+	"hin": {"hi", "Hindi"},
+	"ita": {"it", "Italian"},
+	"jpn": {"ja", "Japanese"},
+	"kor": {"ko", "Korean"},
+	"por": {"pt", "Portuguese"},
+	"rus": {"ru", "Russian"},
+	"sin": {"si", "Sinhala"},
+	"spa": {"es", "Spanish"},
+	"tam": {"ta", "Tamil"},
+}
+
+// LanguageTag returns the RFC 5646 tag for a source language, or "" when
+// it is unknown ("und" or untagged).
+func LanguageTag(lang string) string {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" || lang == "und" {
+		return ""
+	}
+	if l, ok := languages[lang]; ok {
+		return l.tag
+	}
+	return lang
+}
+
+// DisplayName is the NAME shown in player track menus.
+func (a AudioRendition) DisplayName() string {
+	return trackLabel(a.Track.Title, a.Track.Language, fmt.Sprintf("Audio %d", a.Track.Index+1))
+}
+
+// trackLabel is the source title, else the language name, else fallback.
+// It is safe to quote in a playlist attribute.
+func trackLabel(title, lang, fallback string) string {
+	if t := strings.TrimSpace(title); t != "" {
+		return strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(t)
+	}
+	if l, ok := languages[strings.ToLower(lang)]; ok {
+		return l.name
+	}
+	if tag := LanguageTag(lang); tag != "" {
+		return tag
+	}
+	return fallback
+}
+
+// BuildAudioHLSCommand encodes every audio rendition in one ffmpeg process,
+// writing root/<name>/index.m3u8 for each. The rendition directories must
+// exist. Only the audio streams are demuxed and decoded. Progress is written
+// to stdout, as for BuildHLSCommand.
+func BuildAudioHLSCommand(ctx context.Context, input, root string, audio []AudioRendition, seg SegmentType) *exec.Cmd {
+	args := append([]string{"-y"}, progressArgs...)
+	args = append(args, inputArgs(input)...)
+	for _, a := range audio {
+		args = append(args, "-map", fmt.Sprintf("0:a:%d", a.Track.Index))
+		if a.Filter != "" {
+			args = append(args, "-af", a.Filter)
+		}
+		args = append(args,
+			"-c:a", "aac", "-ar", "48000", "-ac", fmt.Sprint(a.Channels),
+			"-b:a", fmt.Sprintf("%dk", a.Bitrate),
+		)
+		if a.Track.Language != "" {
+			args = append(args, "-metadata:s:a:0", "language="+a.Track.Language)
+		}
+		args = append(args, hlsArgs(fmt.Sprintf("%s/%s", root, a.Name), seg)...)
+	}
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
@@ -0,0 +1,101 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Video codec families a ladder can be encoded in.
+const (
+	FamilyH264 = "h264"
+	FamilyHEVC = "hevc"
+	FamilyAV1  = "av1"
+)
+
+// VideoCodec is a codec family plus the software encoder that produces it.
+type VideoCodec struct {
+	Family  string
+	Encoder string
+}
+
+// H264 is the default, universally playable codec.
+var H264 = VideoCodec{Family: FamilyH264, Encoder: "libx264"}
+
+// ParseVideoCodecs parses a comma separated family list such as
+// "h264,hevc,av1". av1Encoder picks libsvtav1 (default) or libaom-av1.
+// An empty list means H.264 only.
+func ParseVideoCodecs(list, av1Encoder string) ([]VideoCodec, error) {
+	switch av1Encoder {
+	case "":
+		av1Encoder = "libsvtav1"
+	case "libsvtav1", "libaom-av1":
+	default:
+		return nil, fmt.Errorf("unknown AV1 encoder %q", av1Encoder)
+	}
+
+	var out []VideoCodec
+	seen := map[string]bool{}
+	for _, f := range strings.Split(list, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if f == "" || seen[f] {
+			continue
+		}
+		seen[f] = true
+		switch f {
+		case "h264", "avc":
+			out = append(out, H264)
+		case "hevc", "h265":
+			out = append(out, VideoCodec{Family: FamilyHEVC, Encoder: "libx265"})
+		case "av1":
+			out = append(out, VideoCodec{Family: FamilyAV1, Encoder: av1Encoder})
+		default:
+			return nil, fmt.Errorf("unknown video codec %q", f)
+		}
+	}
+	if len(out) == 0 {
+		out = []VideoCodec{H264}
+	}
+	return out, nil
+}
+
+// bitrateFactor is the share of the H.264 bitrate a family needs for
+// comparable quality.
+func (c VideoCodec) bitrateFactor() (num, den int) {
+	switch c.Family {
+	case FamilyHEVC:
+		return 6, 10
+	case FamilyAV1:
+		return 5, 10
+	}
+	return 1, 1
+}
+
+// suffix is appended to rung names so renditions of different codecs live in
+// separate directories ("720p", "720p_hevc", "720p_av1").
+func (c VideoCodec) suffix() string {
+	if c.Family == "" || c.Family == FamilyH264 {
+		return ""
+	}
+	return "_" + c.Family
+}
+
+// encoderArgs selects and tunes the encoder. GOP flags are shared and set by
+// the caller; x265 needs scene-cut disabled through its own params.
+func (c VideoCodec) encoderArgs() []string {
+	switch c.Encoder {
+	case "libx265":
+		// hvc1 tagging is required for HEVC playback on Apple devices
+		return []string{"-c:v", "libx265", "-preset", "fast", "-tag:v", "hvc1", "-x265-params", "scenecut=0:open-gop=0:log-level=error"}
+	case "libsvtav1":
+		return []string{"-c:v", "libsvtav1", "-preset", "8"}
+	case "libaom-av1":
+		return []string{"-c:v", "libaom-av1", "-cpu-used", "6", "-row-mt", "1", "-usage", "good"}
+	}
+	return []string{"-c:v", "libx264", "-preset", "veryfast"}
+}
+
+// supportsVBV reports whether the encoder honours -maxrate/-bufsize in
+// plain bitrate mode (SVT-AV1 only accepts them with CRF).
+func (c VideoCodec) supportsVBV() bool {
+	return c.Encoder != "libsvtav1"
+}
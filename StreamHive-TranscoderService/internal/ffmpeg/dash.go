@@ -0,0 +1,203 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// DASHManifestName is the MPD written next to master.m3u8.
+const DASHManifestName = "manifest.mpd"
+
+// DASHSegmentDir holds the remuxed DASH segments when HLS uses MPEG-TS and
+// its segments cannot be shared.
+const DASHSegmentDir = "dash"
+
+// DASHRepresentation is one CMAF rendition to list in a generated MPD.
+type DASHRepresentation struct {
+	Rendition Rendition
+	// Codecs is the RFC 6381 codecs value, e.g. "avc1.640028,mp4a.40.2".
+	Codecs string
+	// Bandwidth is the measured average bitrate (bps); 0 uses the nominal one.
+	Bandwidth int
+	// SegmentDurations are the EXTINF durations (seconds) of the rendition's
+	// media playlist, in order.
+	SegmentDurations []float64
+}
+
+// DASHAudioRepresentation is one CMAF audio rendition to list in a
+// generated MPD.
+type DASHAudioRepresentation struct {
+	Rendition AudioRendition
+	// Codecs is the RFC 6381 codecs value, e.g. "mp4a.40.2".
+	Codecs string
+	// Bandwidth is the measured average bitrate (bps); 0 uses the nominal one.
+	Bandwidth int
+	// SegmentDurations are the EXTINF durations (seconds) of the rendition's
+	// media playlist, in order.
+	SegmentDurations []float64
+}
+
+// BuildCMAFManifest renders a static MPD that points at the fMP4 segments
+// the HLS muxer already wrote (<rendition>/init.mp4, <rendition>/seg_NNNNN.m4s),
+// so DASH and HLS share one copy of the media. Players cannot switch codecs
+// within an adaptation set, so each codec family gets its own. Every audio
+// track gets its own adaptation set too, tagged with its language.
+func BuildCMAFManifest(reps []DASHRepresentation, audio []DASHAudioRepresentation, frameRate float64) string {
+	const timescale = 1000
+	var total float64
+	var families []string
+	byFamily := map[string][]DASHRepresentation{}
+	for _, r := range reps {
+		total = max(total, sumDurations(r.SegmentDurations))
+		f := r.Rendition.Codec.Family
+		if _, ok := byFamily[f]; !ok {
+			families = append(families, f)
+		}
+		byFamily[f] = append(byFamily[f], r)
+	}
+	for _, a := range audio {
+		total = max(total, sumDurations(a.SegmentDurations))
+	}
+	fr := ""
+	if frameRate > 0 {
+		fr = fmt.Sprintf(` frameRate="%s"`, formatFrameRate(frameRate))
+	}
+
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	fmt.Fprintf(&b, `<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" profiles="urn:mpeg:dash:profile:isoff-live:2011" type="static" minBufferTime="PT2S" mediaPresentationDuration="PT%.3fS">`+"\n", total)
+	b.WriteString(`  <Period id="0" start="PT0S">` + "\n")
+	for i, f := range families {
+		fmt.Fprintf(&b, `    <AdaptationSet id="%d" contentType="video" mimeType="video/mp4" segmentAlignment="true" startWithSAP="1">`+"\n", i)
+		for _, r := range byFamily[f] {
+			bw := r.Bandwidth
+			if bw <= 0 {
+				bw = r.Rendition.Bandwidth()
+			}
+			// codecs is left out when the probe could not tell the level
+			codecs := ""
+			if r.Codecs != "" {
+				codecs = fmt.Sprintf(` codecs="%s"`, r.Codecs)
+			}
+			fmt.Fprintf(&b, `      <Representation id="%s" bandwidth="%d" width="%d" height="%d"%s%s>`+"\n",
+				r.Rendition.Name, bw, r.Rendition.Width, r.Rendition.Height, codecs, fr)
+			writeSegmentTemplate(&b, r.Rendition.Name, r.SegmentDurations, timescale)
+			b.WriteString(`      </Representation>` + "\n")
+		}
+		b.WriteString(`    </AdaptationSet>` + "\n")
+	}
+	for i, a := range audio {
+		lang := ""
+		if tag := LanguageTag(a.Rendition.Track.Language); tag != "" {
+			lang = fmt.Sprintf(` lang="%s"`, tag)
+		}
+		fmt.Fprintf(&b, `    <AdaptationSet id="%d" contentType="audio" mimeType="audio/mp4"%s segmentAlignment="true" startWithSAP="1">`+"\n", len(families)+i, lang)
+		if a.Rendition.Default {
+			b.WriteString(`      <Role schemeIdUri="urn:mpeg:dash:role:2011" value="main"/>` + "\n")
+		}
+		bw := a.Bandwidth
+		if bw <= 0 {
+			bw = a.Rendition.Bandwidth()
+		}
+		fmt.Fprintf(&b, `      <Representation id="%s" bandwidth="%d" codecs="%s" audioSamplingRate="48000">`+"\n", a.Rendition.Name, bw, a.Codecs)
+		fmt.Fprintf(&b, `        <AudioChannelConfiguration schemeIdUri="urn:mpeg:dash:23003:3:audio_channel_configuration:2011" value="%d"/>`+"\n", a.Rendition.Channels)
+		writeSegmentTemplate(&b, a.Rendition.Name, a.SegmentDurations, timescale)
+		b.WriteString(`      </Representation>` + "\n")
+		b.WriteString(`    </AdaptationSet>` + "\n")
+	}
+	b.WriteString(`  </Period>` + "\n")
+	b.WriteString(`</MPD>` + "\n")
+	return b.String()
+}
+
+func sumDurations(durations []float64) float64 {
+	var d float64
+	for _, s := range durations {
+		d += s
+	}
+	return d
+}
+
+// writeSegmentTemplate emits the BaseURL and SegmentTemplate of the
+// rendition in directory name.
+func writeSegmentTemplate(b *strings.Builder, name string, durations []float64, timescale int) {
+	fmt.Fprintf(b, `        <BaseURL>%s/</BaseURL>`+"\n", name)
+	fmt.Fprintf(b, `        <SegmentTemplate timescale="%d" initialization="%s" media="seg_$Number%%05d$.m4s" startNumber="0">`+"\n", timescale, InitSegmentName)
+	b.WriteString(`          <SegmentTimeline>` + "\n")
+	writeTimeline(b, durations, timescale)
+	b.WriteString(`          </SegmentTimeline>` + "\n")
+	b.WriteString(`        </SegmentTemplate>` + "\n")
+}
+
+// writeTimeline emits S elements, run-length encoding equal durations.
+func writeTimeline(b *strings.Builder, durations []float64, timescale int) {
+	var t int64
+	for i := 0; i < len(durations); {
+		d := int64(durations[i]*float64(timescale) + 0.5)
+		n := 1
+		for i+n < len(durations) && int64(durations[i+n]*float64(timescale)+0.5) == d {
+			n++
+		}
+		if n > 1 {
+			fmt.Fprintf(b, `            <S t="%d" d="%d" r="%d"/>`+"\n", t, d, n-1)
+		} else {
+			fmt.Fprintf(b, `            <S t="%d" d="%d"/>`+"\n", t, d)
+		}
+		t += d * int64(n)
+		i += n
+	}
+}
+
+func formatFrameRate(fps float64) string {
+	// NTSC rates are expressed as rationals, e.g. 30000/1001
+	for _, base := range []int{24, 30, 60} {
+		if ntsc := float64(base*1000) / 1001; fps > ntsc-0.01 && fps < ntsc+0.01 {
+			return fmt.Sprintf("%d/1001", base*1000)
+		}
+	}
+	return strconv.FormatFloat(fps, 'f', -1, 64)
+}
+
+// BuildDASHRemuxCommand stream-copies the MPEG-TS HLS renditions under root
+// into fMP4 DASH segments in root/dash and writes root/manifest.mpd. Video
+// is taken from every video rendition; each audio rendition becomes its own
+// adaptation set. The dash directory must exist before running the command.
+func BuildDASHRemuxCommand(ctx context.Context, root string, renditions []Rendition, audio []AudioRendition) *exec.Cmd {
+	args := []string{"-y"}
+	for _, r := range renditions {
+		args = append(args, "-i", fmt.Sprintf("%s/%s/index.m3u8", root, r.Name))
+	}
+	for _, a := range audio {
+		args = append(args, "-i", fmt.Sprintf("%s/%s/index.m3u8", root, a.Name))
+	}
+	for i := range renditions {
+		args = append(args, "-map", fmt.Sprintf("%d:v:0", i))
+	}
+	sets := []string{"id=0,streams=v"}
+	for i, a := range audio {
+		args = append(args, "-map", fmt.Sprintf("%d:a:0", len(renditions)+i))
+		if a.Track.Language != "" {
+			args = append(args, fmt.Sprintf("-metadata:s:a:%d", i), "language="+a.Track.Language)
+		}
+		// output stream index: the video streams come first
+		sets = append(sets, fmt.Sprintf("id=%d,streams=%d", i+1, len(renditions)+i))
+	}
+	args = append(args, "-c", "copy")
+	if len(audio) > 0 {
+		args = append(args, "-bsf:a", "aac_adtstoasc")
+	}
+	args = append(args,
+		"-f", "dash",
+		"-seg_duration", "6",
+		"-use_template", "1",
+		"-use_timeline", "1",
+		"-adaptation_sets", strings.Join(sets, " "),
+		"-init_seg_name", DASHSegmentDir+"/init-$RepresentationID$.m4s",
+		"-media_seg_name", DASHSegmentDir+"/chunk-$RepresentationID$-$Number%05d$.m4s",
+		fmt.Sprintf("%s/%s", root, DASHManifestName),
+	)
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
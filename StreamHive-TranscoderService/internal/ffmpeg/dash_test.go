@@ -0,0 +1,101 @@
+package ffmpeg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildCMAFManifest(t *testing.T) {
+	h264 := Rendition{Name: "720p", Codec: H264, Width: 1280, Height: 720, VideoBitrate: 2800}
+	hevc := Rendition{Name: "720p_hevc", Codec: VideoCodec{Family: FamilyHEVC}, Width: 1280, Height: 720, VideoBitrate: 1680}
+	eng := AudioRendition{Name: "audio_0", Track: AudioTrack{Language: "eng"}, Default: true, Bitrate: 128, Channels: 2}
+
+	tests := []struct {
+		name      string
+		reps      []DASHRepresentation
+		audio     []DASHAudioRepresentation
+		frameRate float64
+		want      []string
+		notWant   []string
+	}{
+		{
+			name: "measured bandwidth and codecs",
+			reps: []DASHRepresentation{{Rendition: h264, Codecs: "avc1.64001F", Bandwidth: 2500000, SegmentDurations: []float64{4, 4, 2.5}}},
+			want: []string{
+				`mediaPresentationDuration="PT10.500S"`,
+				`<Representation id="720p" bandwidth="2500000" width="1280" height="720" codecs="avc1.64001F">`,
+				`<BaseURL>720p/</BaseURL>`,
+				`<S t="0" d="4000" r="1"/>`,
+				`<S t="8000" d="2500"/>`,
+			},
+		},
+		{
+			name:    "unknown level omits codecs and falls back to nominal bandwidth",
+			reps:    []DASHRepresentation{{Rendition: h264, SegmentDurations: []float64{4}}},
+			want:    []string{`<Representation id="720p" bandwidth="2800000" width="1280" height="720">`},
+			notWant: []string{`codecs=`},
+		},
+		{
+			name: "one adaptation set per codec family",
+			reps: []DASHRepresentation{
+				{Rendition: h264, SegmentDurations: []float64{4}},
+				{Rendition: hevc, SegmentDurations: []float64{4}},
+			},
+			want: []string{
+				`<AdaptationSet id="0" contentType="video"`,
+				`<AdaptationSet id="1" contentType="video"`,
+			},
+		},
+		{
+			name:      "NTSC frame rate as a rational",
+			reps:      []DASHRepresentation{{Rendition: h264, SegmentDurations: []float64{4}}},
+			frameRate: 29.97,
+			want:      []string{`frameRate="30000/1001"`},
+		},
+		{
+			name:  "audio tagged with language and role",
+			reps:  []DASHRepresentation{{Rendition: h264, SegmentDurations: []float64{4}}},
+			audio: []DASHAudioRepresentation{{Rendition: eng, Codecs: "mp4a.40.2", SegmentDurations: []float64{4}}},
+			want: []string{
+				`<AdaptationSet id="1" contentType="audio" mimeType="audio/mp4" lang="en"`,
+				`<Role schemeIdUri="urn:mpeg:dash:role:2011" value="main"/>`,
+				`<Representation id="audio_0" bandwidth="128000" codecs="mp4a.40.2" audioSamplingRate="48000">`,
+				`value="2"/>`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mpd := BuildCMAFManifest(tt.reps, tt.audio, tt.frameRate)
+			for _, s := range tt.want {
+				if !strings.Contains(mpd, s) {
+					t.Errorf("manifest lacks %s:\n%s", s, mpd)
+				}
+			}
+			for _, s := range tt.notWant {
+				if strings.Contains(mpd, s) {
+					t.Errorf("manifest contains %s:\n%s", s, mpd)
+				}
+			}
+		})
+	}
+}
+
+func TestFormatFrameRate(t *testing.T) {
+	tests := []struct {
+		fps  float64
+		want string
+	}{
+		{25, "25"},
+		{30, "30"},
+		{23.976, "24000/1001"},
+		{29.97, "30000/1001"},
+		{59.94, "60000/1001"},
+		{12.5, "12.5"},
+	}
+	for _, tt := range tests {
+		if got := formatFrameRate(tt.fps); got != tt.want {
+			t.Errorf("formatFrameRate(%v) = %q, want %q", tt.fps, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// KeyDir is where the AES-128 key URIs of encrypted media playlists point,
+// relative to the video's HLS root. Keys are not stored there: the playback
+// service serves them from the key store after checking the viewer.
+const KeyDir = "keys"
+
+// KeyName is the object name of content key n.
+func KeyName(n int) string {
+	return fmt.Sprintf("%05d.key", n)
+}
+
+// EncryptRendition copies the rendition in src to dst with every media
+// segment encrypted with AES-128 (CBC, PKCS#7), as HLS METHOD=AES-128
+// expects. Segment i uses key i/rotate (a single key when rotate <= 0),
+// obtained from keyFor, and its media sequence number as IV, so the
+// playlist carries one EXT-X-KEY per key and no IV attribute. The fMP4 init
+// segment precedes the first EXT-X-KEY and stays in the clear.
+func EncryptRendition(src, dst string, rotate int, keyFor func(n int) ([]byte, error)) error {
+	playlist, err := os.ReadFile(filepath.Join(src, "index.m3u8"))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dst, 0o755); err != nil {
+		return err
+	}
+	entries, err := os.ReadDir(src)
+	if err != nil {
+		return err
+	}
+	segments := map[string]bool{}
+	for _, s := range ParseMediaPlaylist(playlist) {
+		segments[s.URI] = true
+	}
+	for _, e := range entries {
+		if e.IsDir() || e.Name() == "index.m3u8" || segments[e.Name()] {
+			continue
+		}
+		// init.mp4 and anything else the muxer left next to the segments
+		data, err := os.ReadFile(filepath.Join(src, e.Name()))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dst, e.Name()), data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	var out bytes.Buffer
+	seq, i, key := 0, 0, -1
+	sc := bufio.NewScanner(bytes.NewReader(playlist))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			seq, _ = strconv.Atoi(strings.TrimPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"))
+		case strings.HasPrefix(line, "#EXTINF:"):
+			n := 0
+			if rotate > 0 {
+				n = i / rotate
+			}
+			if n != key {
+				key = n
+				fmt.Fprintf(&out, "#EXT-X-KEY:METHOD=AES-128,URI=\"../%s/%s\"\n", KeyDir, KeyName(n))
+			}
+		case line != "" && !strings.HasPrefix(line, "#"):
+			k, err := keyFor(key)
+			if err != nil {
+				return fmt.Errorf("key %d: %w", key, err)
+			}
+			if err := encryptSegment(filepath.Join(src, line), filepath.Join(dst, line), k, uint64(seq+i)); err != nil {
+				return fmt.Errorf("encrypt %s: %w", line, err)
+			}
+			i++
+		}
+		out.WriteString(line + "\n")
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dst, "index.m3u8"), out.Bytes(), 0o644)
+}
+
+// encryptSegment writes src to dst encrypted with key, using sequence number
+// seq as the IV.
+func encryptSegment(src, dst string, key []byte, seq uint64) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	pad := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(data, bytes.Repeat([]byte{byte(pad)}, pad)...)
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], seq)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return os.WriteFile(dst, data, 0o644)
+}
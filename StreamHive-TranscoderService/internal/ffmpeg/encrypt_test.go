@@ -0,0 +1,117 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptRendition(t *testing.T) {
+	tests := []struct {
+		name     string
+		segments int
+		rotate   int
+		keys     []int // key index per segment
+	}{
+		{"single key", 3, 0, []int{0, 0, 0}},
+		{"rotate every two", 5, 2, []int{0, 0, 1, 1, 2}},
+		{"rotate every one", 2, 1, []int{0, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, dst := t.TempDir(), t.TempDir()
+			const firstSeq = 7
+			var pl strings.Builder
+			fmt.Fprintf(&pl, "#EXTM3U\n#EXT-X-MEDIA-SEQUENCE:%d\n#EXT-X-MAP:URI=\"%s\"\n", firstSeq, InitSegmentName)
+			plain := make([][]byte, tt.segments)
+			for i := range plain {
+				name := fmt.Sprintf("seg_%05d.m4s", i)
+				plain[i] = bytes.Repeat([]byte{byte('a' + i)}, 20+i)
+				writeFile(t, filepath.Join(src, name), plain[i])
+				fmt.Fprintf(&pl, "#EXTINF:4.000,\n%s\n", name)
+			}
+			pl.WriteString("#EXT-X-ENDLIST\n")
+			writeFile(t, filepath.Join(src, "index.m3u8"), []byte(pl.String()))
+			writeFile(t, filepath.Join(src, InitSegmentName), []byte("init"))
+
+			keyFor := func(n int) ([]byte, error) { return bytes.Repeat([]byte{byte(n + 1)}, 16), nil }
+			if err := EncryptRendition(src, dst, tt.rotate, keyFor); err != nil {
+				t.Fatal(err)
+			}
+
+			if b := readFile(t, filepath.Join(dst, InitSegmentName)); string(b) != "init" {
+				t.Errorf("init segment = %q, want it copied in the clear", b)
+			}
+			out := string(readFile(t, filepath.Join(dst, "index.m3u8")))
+			if strings.Index(out, "#EXT-X-MAP:") > strings.Index(out, "#EXT-X-KEY:") {
+				t.Errorf("EXT-X-KEY precedes EXT-X-MAP:\n%s", out)
+			}
+			if got, want := strings.Count(out, "#EXT-X-KEY:"), tt.keys[len(tt.keys)-1]+1; got != want {
+				t.Errorf("playlist has %d EXT-X-KEY tags, want %d:\n%s", got, want, out)
+			}
+			for i, n := range tt.keys {
+				if !strings.Contains(out, fmt.Sprintf(`URI="../%s/%s"`, KeyDir, KeyName(n))) {
+					t.Errorf("playlist does not reference key %d", n)
+				}
+				enc := readFile(t, filepath.Join(dst, fmt.Sprintf("seg_%05d.m4s", i)))
+				key, _ := keyFor(n)
+				if got := decryptSegment(t, enc, key, uint64(firstSeq+i)); !bytes.Equal(got, plain[i]) {
+					t.Errorf("segment %d decrypts to %q, want %q", i, got, plain[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEncryptRenditionKeyError(t *testing.T) {
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "index.m3u8"), []byte("#EXTM3U\n#EXTINF:4.000,\nseg_00000.ts\n"))
+	writeFile(t, filepath.Join(src, "seg_00000.ts"), []byte("data"))
+	keyFor := func(int) ([]byte, error) { return nil, os.ErrNotExist }
+	if err := EncryptRendition(src, t.TempDir(), 0, keyFor); err == nil {
+		t.Fatal("EncryptRendition() succeeded without a key")
+	}
+}
+
+// decryptSegment reverses encryptSegment the way an HLS player does.
+func decryptSegment(t *testing.T, data, key []byte, seq uint64) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		t.Fatalf("ciphertext length %d is not a multiple of the block size", len(data))
+	}
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[8:], seq)
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(out, data)
+	pad := int(out[len(out)-1])
+	if pad < 1 || pad > aes.BlockSize {
+		t.Fatalf("bad padding %d", pad)
+	}
+	return out[:len(out)-pad]
+}
+
+func writeFile(t *testing.T, name string, data []byte) {
+	t.Helper()
+	if err := os.WriteFile(name, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, name string) []byte {
+	t.Helper()
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
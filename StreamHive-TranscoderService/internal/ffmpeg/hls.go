@@ -4,37 +4,116 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"strings"
 )
 
-// Variant preset for HLS ladder
-var Presets = map[string][]string{
-	"1080p": {"-vf", "scale=-2:1080", "-b:v", "5000k", "-maxrate", "5350k", "-bufsize", "7500k", "-b:a", "192k"},
-	"720p":  {"-vf", "scale=-2:720", "-b:v", "2800k", "-maxrate", "2996k", "-bufsize", "4200k", "-b:a", "128k"},
-	"480p":  {"-vf", "scale=-2:480", "-b:v", "1400k", "-maxrate", "1498k", "-bufsize", "2100k", "-b:a", "96k"},
-	"360p":  {"-vf", "scale=-2:360", "-b:v", "800k", "-maxrate", "856k", "-bufsize", "1200k", "-b:a", "64k"},
+// SegmentType selects the HLS segment container.
+type SegmentType string
+
+const (
+	SegmentTS   SegmentType = "mpegts"
+	SegmentFMP4 SegmentType = "fmp4"
+)
+
+// InitSegmentName is the fMP4 initialization segment each rendition
+// references through EXT-X-MAP.
+const InitSegmentName = "init.mp4"
+
+// ParseSegmentType accepts "mpegts"/"ts" and "fmp4"/"cmaf"; empty means mpegts.
+func ParseSegmentType(s string) (SegmentType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "ts", "mpegts":
+		return SegmentTS, nil
+	case "fmp4", "cmaf":
+		return SegmentFMP4, nil
+	}
+	return "", fmt.Errorf("unknown HLS segment type %q", s)
 }
 
-func BuildHLSCommand(ctx context.Context, input, outDir string, res string) *exec.Cmd {
-	args := []string{
-		"-y",
-		"-i", input,
-		"-preset", "veryfast",
+// BuildHLSCommand encodes a single video-only rendition of input into
+// outDir/index.m3u8; audio is encoded separately by BuildAudioHLSCommand.
+// Progress is written to stdout; run it with RunWithProgress.
+func BuildHLSCommand(ctx context.Context, input, outDir string, r Rendition, seg SegmentType) *exec.Cmd {
+	args := append([]string{"-y"}, progressArgs...)
+	args = append(args, inputArgs(input)...)
+	args = append(args,
+		"-vf", scaleFilter(r),
+		"-an",
+	)
+	args = append(args, encodeArgs(r)...)
+	args = append(args, hlsArgs(outDir, seg)...)
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
+
+// BuildMultiHLSCommand encodes every rendition in one ffmpeg process: the
+// source is decoded once, split with filter_complex and scaled per output,
+// and each output writes a video-only root/<rendition>/index.m3u8. The rendition
+// directories must exist. Progress is written to stdout, as for BuildHLSCommand.
+func BuildMultiHLSCommand(ctx context.Context, input, root string, renditions []Rendition, seg SegmentType) *exec.Cmd {
+	var graph strings.Builder
+	fmt.Fprintf(&graph, "[0:v]split=%d", len(renditions))
+	for i := range renditions {
+		fmt.Fprintf(&graph, "[s%d]", i)
+	}
+	for i, r := range renditions {
+		fmt.Fprintf(&graph, ";[s%d]%s[v%d]", i, scaleFilter(r), i)
+	}
+
+	args := append([]string{"-y"}, progressArgs...)
+	args = append(args, inputArgs(input)...)
+	args = append(args, "-filter_complex", graph.String())
+	for i, r := range renditions {
+		args = append(args, "-map", fmt.Sprintf("[v%d]", i))
+		args = append(args, encodeArgs(r)...)
+		args = append(args, hlsArgs(fmt.Sprintf("%s/%s", root, r.Name), seg)...)
+	}
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
+
+func scaleFilter(r Rendition) string {
+	return fmt.Sprintf("scale=%d:%d", r.Width, r.Height)
+}
+
+// encodeArgs are the per-output video encoder options for r.
+func encodeArgs(r Rendition) []string {
+	args := r.Codec.encoderArgs()
+	args = append(args,
+		"-pix_fmt", "yuv420p",
 		"-g", "48", "-keyint_min", "48", "-sc_threshold", "0",
-		"-c:a", "aac", "-ar", "48000",
+	)
+	return append(args, renditionArgs(r)...)
+}
+
+// hlsArgs are the HLS muxer options writing outDir/index.m3u8. fMP4 output
+// gets an init segment plus .m4s media segments (CMAF compatible).
+func hlsArgs(outDir string, seg SegmentType) []string {
+	if seg == "" {
+		seg = SegmentTS
 	}
-	args = append(args, Presets[res]...)
-	args = append(args, []string{
+	args := []string{
 		"-hls_time", "6",
 		"-hls_playlist_type", "vod",
-		"-hls_segment_type", "mpegts",
+		"-hls_segment_type", string(seg),
 		"-hls_flags", "independent_segments",
-		"-f", "hls",
-		fmt.Sprintf("%s/index.m3u8", outDir),
-	}...)
-	return exec.CommandContext(ctx, "ffmpeg", args...)
+	}
+	if seg == SegmentFMP4 {
+		args = append(args,
+			"-hls_fmp4_init_filename", InitSegmentName,
+			"-hls_segment_filename", fmt.Sprintf("%s/seg_%%05d.m4s", outDir),
+		)
+	}
+	return append(args, "-f", "hls", fmt.Sprintf("%s/index.m3u8", outDir))
 }
 
-func GenerateThumbnail(ctx context.Context, input, outPath string) *exec.Cmd {
-	// grab a frame at 3s
-	return exec.CommandContext(ctx, "ffmpeg", "-y", "-ss", "3", "-i", input, "-frames:v", "1", "-q:v", "2", outPath)
+func renditionArgs(r Rendition) []string {
+	args := []string{
+		"-b:v", fmt.Sprintf("%dk", r.VideoBitrate),
+	}
+	if r.Codec.supportsVBV() {
+		args = append(args,
+			"-maxrate", fmt.Sprintf("%dk", r.MaxRate),
+			"-bufsize", fmt.Sprintf("%dk", r.BufSize),
+		)
+	}
+	return args
 }
@@ -0,0 +1,38 @@
+package ffmpeg
+
+import (
+	"regexp"
+	"strings"
+)
+
+// IsRemote reports whether input is read over HTTP(S), e.g. a presigned
+// object storage URL, rather than from a local file.
+func IsRemote(input string) bool {
+	return strings.HasPrefix(input, "http://") || strings.HasPrefix(input, "https://")
+}
+
+// inputArgs opens input. A remote input reconnects after dropped
+// connections and is marked seekable, so demuxers that jump around the file
+// (an MP4 with its moov atom at the end) use HTTP range requests instead of
+// reading through.
+func inputArgs(input string) []string {
+	if !IsRemote(input) {
+		return []string{"-i", input}
+	}
+	return []string{
+		"-seekable", "1",
+		"-reconnect", "1",
+		"-reconnect_on_network_error", "1",
+		"-reconnect_delay_max", "30",
+		"-i", input,
+	}
+}
+
+// signedQueryRe matches the query of a URL in ffmpeg output.
+var signedQueryRe = regexp.MustCompile(`(https?://[^\s?'"]+)\?[^\s'"]*`)
+
+// redactURLs drops URL queries from an ffmpeg message before it ends up in
+// an error; presigned URLs carry their signature there.
+func redactURLs(msg string) string {
+	return signedQueryRe.ReplaceAllString(msg, "$1?REDACTED")
+}
@@ -0,0 +1,135 @@
+package ffmpeg
+
+import "fmt"
+
+// Rendition is one rung of the adaptive bitrate ladder. Height is the
+// nominal "p" size and always refers to the short side of the frame, so a
+// portrait 720p rendition is 720 pixels wide. Bitrates are in kbps.
+type Rendition struct {
+	Name         string
+	Codec        VideoCodec
+	Width        int
+	Height       int
+	VideoBitrate int
+	MaxRate      int
+	BufSize      int
+}
+
+// Bandwidth is the nominal video bitrate in bps, used when the encoded
+// segments cannot be measured. Audio is a separate rendition.
+func (r Rendition) Bandwidth() int {
+	return r.VideoBitrate * 1000
+}
+
+// PeakBandwidth is the nominal VBV ceiling in bps.
+func (r Rendition) PeakBandwidth() int {
+	return max(r.MaxRate, r.VideoBitrate) * 1000
+}
+
+// Resolution returns the WxH string used in EXT-X-STREAM-INF.
+func (r Rendition) Resolution() string {
+	return fmt.Sprintf("%dx%d", r.Width, r.Height)
+}
+
+// Ladder is the reference 16:9 ladder, highest first.
+var Ladder = []Rendition{
+	{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: 5000, MaxRate: 5350, BufSize: 7500},
+	{Name: "720p", Width: 1280, Height: 720, VideoBitrate: 2800, MaxRate: 2996, BufSize: 4200},
+	{Name: "480p", Width: 854, Height: 480, VideoBitrate: 1400, MaxRate: 1498, BufSize: 2100},
+	{Name: "360p", Width: 640, Height: 360, VideoBitrate: 800, MaxRate: 856, BufSize: 1200},
+}
+
+// SelectLadder builds the renditions for a source of the given display size,
+// one set of rungs per codec in codecs (H.264 only when empty).
+// Rungs whose short side exceeds the source's are dropped so nothing is
+// upscaled; output dimensions follow the source aspect ratio (including
+// portrait) and bitrates are scaled down for frames with fewer pixels than
+// the 16:9 reference and for more efficient codecs. If requested is
+// non-empty only those rung names ("720p") are considered; when none of them
+// fits the source, the highest rung that does is used instead. Sources
+// smaller than the lowest rung get a single native-size rendition per codec.
+func SelectLadder(srcW, srcH int, requested []string, codecs []VideoCodec) []Rendition {
+	if srcW <= 0 || srcH <= 0 {
+		return nil
+	}
+	if len(codecs) == 0 {
+		codecs = []VideoCodec{H264}
+	}
+	want := map[string]bool{}
+	for _, n := range requested {
+		want[n] = true
+	}
+	short := min(srcW, srcH)
+
+	var base []Rendition
+	fits := -1 // highest rung the source can fill
+	for i, rung := range Ladder {
+		if rung.Height > short {
+			continue
+		}
+		if fits < 0 {
+			fits = i
+		}
+		if len(want) > 0 && !want[rung.Name] {
+			continue
+		}
+		base = append(base, fitRendition(rung, srcW, srcH, rung.Height))
+	}
+	if len(base) == 0 && fits >= 0 {
+		rung := Ladder[fits]
+		base = append(base, fitRendition(rung, srcW, srcH, rung.Height))
+	}
+	if len(base) == 0 {
+		lowest := Ladder[len(Ladder)-1]
+		native := even(short)
+		r := fitRendition(lowest, srcW, srcH, native)
+		r.Name = fmt.Sprintf("%dp", native)
+		base = append(base, r)
+	}
+
+	out := make([]Rendition, 0, len(base)*len(codecs))
+	for _, c := range codecs {
+		num, den := c.bitrateFactor()
+		for _, r := range base {
+			r.Codec = c
+			r.Name += c.suffix()
+			r.VideoBitrate = max(1, r.VideoBitrate*num/den)
+			r.MaxRate = max(1, r.MaxRate*num/den)
+			r.BufSize = max(1, r.BufSize*num/den)
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+// fitRendition sizes rung so its short side is shortSide and its long side
+// keeps the source aspect ratio.
+func fitRendition(rung Rendition, srcW, srcH, shortSide int) Rendition {
+	r := rung
+	if srcW >= srcH {
+		r.Height = shortSide
+		r.Width = even(srcW * shortSide / srcH)
+	} else {
+		r.Width = shortSide
+		r.Height = even(srcH * shortSide / srcW)
+	}
+
+	// Only narrower-than-16:9 frames (e.g. 4:3) get meaningfully fewer
+	// pixels; ignore rounding noise such as 852 vs 854 wide.
+	refPixels := rung.Width * rung.Height
+	if pixels := r.Width * r.Height; pixels*100 < refPixels*95 {
+		scale := func(kbps int) int { return max(1, kbps*pixels/refPixels) }
+		r.VideoBitrate = scale(r.VideoBitrate)
+		r.MaxRate = scale(r.MaxRate)
+		r.BufSize = scale(r.BufSize)
+	}
+	return r
+}
+
+// even rounds n down to the nearest even number (libx264 requires even sizes).
+func even(n int) int {
+	if n < 2 {
+		return 2
+	}
+	return n &^ 1
+}
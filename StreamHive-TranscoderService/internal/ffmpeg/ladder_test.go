@@ -0,0 +1,72 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectLadder(t *testing.T) {
+	type rung struct {
+		name          string
+		width, height int
+		kbps          int
+	}
+	tests := []struct {
+		name       string
+		srcW, srcH int
+		requested  []string
+		codecs     []VideoCodec
+		want       []rung
+	}{
+		{"unknown size", 0, 1080, nil, nil, nil},
+		{"1080p keeps every rung", 1920, 1080, nil, nil, []rung{
+			{"1080p", 1920, 1080, 5000},
+			{"720p", 1280, 720, 2800},
+			{"480p", 852, 480, 1400},
+			{"360p", 640, 360, 800},
+		}},
+		{"720p never upscales", 1280, 720, nil, nil, []rung{
+			{"720p", 1280, 720, 2800},
+			{"480p", 852, 480, 1400},
+			{"360p", 640, 360, 800},
+		}},
+		{"portrait swaps sides", 1080, 1920, nil, nil, []rung{
+			{"1080p", 1080, 1920, 5000},
+			{"720p", 720, 1280, 2800},
+			{"480p", 480, 852, 1400},
+			{"360p", 360, 640, 800},
+		}},
+		{"4:3 scales bitrate by pixels", 640, 480, nil, nil, []rung{
+			{"480p", 640, 480, 1049},
+			{"360p", 480, 360, 600},
+		}},
+		{"requested rungs only", 1920, 1080, []string{"720p", "360p"}, nil, []rung{
+			{"720p", 1280, 720, 2800},
+			{"360p", 640, 360, 800},
+		}},
+		{"requested rungs above the source fall back to the highest that fits", 1280, 720, []string{"1080p"}, nil, []rung{
+			{"720p", 1280, 720, 2800},
+		}},
+		{"small source gets native rung", 320, 181, nil, nil, []rung{
+			{"180p", 318, 180, 198},
+		}},
+		{"small source ignores requested rungs", 320, 181, []string{"1080p"}, nil, []rung{
+			{"180p", 318, 180, 198},
+		}},
+		{"one set per codec", 1280, 720, []string{"720p"}, []VideoCodec{H264, {Family: FamilyHEVC, Encoder: "libx265"}}, []rung{
+			{"720p", 1280, 720, 2800},
+			{"720p_hevc", 1280, 720, 1680},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []rung
+			for _, r := range SelectLadder(tt.srcW, tt.srcH, tt.requested, tt.codecs) {
+				got = append(got, rung{r.Name, r.Width, r.Height, r.VideoBitrate})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectLadder(%d, %d) = %v, want %v", tt.srcW, tt.srcH, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// LoudnormTarget is the EBU R128 normalization target.
+type LoudnormTarget struct {
+	Integrated float64 `json:"integratedLufs"` // LUFS, -70..-5
+	TruePeak   float64 `json:"truePeakDbtp"`   // dBTP, -9..0
+	LRA        float64 `json:"lraLu"`          // LU, 1..50
+}
+
+// Validate checks the target against the ranges the loudnorm filter accepts.
+func (t LoudnormTarget) Validate() error {
+	switch {
+	case t.Integrated < -70 || t.Integrated > -5:
+		return fmt.Errorf("integrated loudness target %.1f LUFS outside -70..-5", t.Integrated)
+	case t.TruePeak < -9 || t.TruePeak > 0:
+		return fmt.Errorf("true peak target %.1f dBTP outside -9..0", t.TruePeak)
+	case t.LRA < 1 || t.LRA > 50:
+		return fmt.Errorf("loudness range target %.1f LU outside 1..50", t.LRA)
+	}
+	return nil
+}
+
+// Loudness is what the first loudnorm pass measured on a source track.
+type Loudness struct {
+	Integrated   float64 `json:"integratedLufs"`
+	TruePeak     float64 `json:"truePeakDbtp"`
+	LRA          float64 `json:"lraLu"`
+	Threshold    float64 `json:"thresholdLufs"`
+	TargetOffset float64 `json:"targetOffsetLu"`
+}
+
+// MeasureLoudness runs the first loudnorm pass over audio track (N in
+// 0:a:N) of input. Only that stream is decoded.
+func MeasureLoudness(ctx context.Context, input string, track int, t LoudnormTarget) (Loudness, error) {
+	af := fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%.1f:print_format=json", t.Integrated, t.TruePeak, t.LRA)
+	args := append([]string{"-hide_banner", "-nostats"}, inputArgs(input)...)
+	args = append(args,
+		"-map", fmt.Sprintf("0:a:%d", track),
+		"-af", af,
+		"-f", "null", "-",
+	)
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return Loudness{}, fmt.Errorf("ffmpeg loudnorm: %w", err)
+	}
+	return parseLoudnorm(stderr.Bytes())
+}
+
+// parseLoudnorm reads the JSON block loudnorm prints at the end of stderr.
+// Silent tracks measure -inf and cannot be normalized.
+func parseLoudnorm(stderr []byte) (Loudness, error) {
+	var l Loudness
+	start := bytes.LastIndexByte(stderr, '{')
+	end := bytes.LastIndexByte(stderr, '}')
+	if start < 0 || end < start {
+		return l, fmt.Errorf("no loudnorm measurement in ffmpeg output")
+	}
+	var raw map[string]string
+	if err := json.Unmarshal(stderr[start:end+1], &raw); err != nil {
+		return l, fmt.Errorf("loudnorm json: %w", err)
+	}
+	fields := []struct {
+		key string
+		dst *float64
+	}{
+		{"input_i", &l.Integrated},
+		{"input_tp", &l.TruePeak},
+		{"input_lra", &l.LRA},
+		{"input_thresh", &l.Threshold},
+		{"target_offset", &l.TargetOffset},
+	}
+	for _, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(raw[f.key]), 64)
+		if err != nil {
+			return l, fmt.Errorf("loudnorm %s %q: %w", f.key, raw[f.key], err)
+		}
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return l, fmt.Errorf("loudnorm %s is %s; track is silent", f.key, raw[f.key])
+		}
+		*f.dst = v
+	}
+	return l, nil
+}
+
+// Filter is the second loudnorm pass for a track measured as m: linear
+// normalization to t, which loudnorm only falls back from (to dynamic
+// compression) when the target would push the true peak over its limit or
+// the source's range exceeds t.LRA.
+func (t LoudnormTarget) Filter(m Loudness) string {
+	return fmt.Sprintf("loudnorm=I=%.1f:TP=%.1f:LRA=%.1f:measured_I=%.2f:measured_TP=%.2f:measured_LRA=%.2f:measured_thresh=%.2f:offset=%.2f:linear=true",
+		t.Integrated, t.TruePeak, t.LRA, m.Integrated, m.TruePeak, m.LRA, m.Threshold, m.TargetOffset)
+}
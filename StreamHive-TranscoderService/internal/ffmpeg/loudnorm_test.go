@@ -0,0 +1,70 @@
+package ffmpeg
+
+import "testing"
+
+const loudnormBlock = `{
+	"input_i" : "-27.61",
+	"input_tp" : "-4.47",
+	"input_lra" : "18.06",
+	"input_thresh" : "-39.20",
+	"output_i" : "-16.58",
+	"output_tp" : "-1.50",
+	"output_lra" : "14.78",
+	"output_thresh" : "-27.71",
+	"normalization_type" : "dynamic",
+	"target_offset" : "0.58"
+}
+`
+
+func TestParseLoudnorm(t *testing.T) {
+	tests := []struct {
+		name    string
+		stderr  string
+		want    Loudness
+		wantErr bool
+	}{
+		{
+			name:   "measurement after log lines",
+			stderr: "Input #0, mov,mp4 {stream 0}\n[Parsed_loudnorm_0 @ 0x5581] \n" + loudnormBlock,
+			want:   Loudness{Integrated: -27.61, TruePeak: -4.47, LRA: 18.06, Threshold: -39.2, TargetOffset: 0.58},
+		},
+		{name: "silent track", stderr: `{"input_i" : "-inf", "input_tp" : "-inf", "input_lra" : "0.00", "input_thresh" : "-70.00", "target_offset" : "inf"}`, wantErr: true},
+		{name: "missing field", stderr: `{"input_i" : "-23.00"}`, wantErr: true},
+		{name: "no block", stderr: "Error while decoding stream #0:1\n", wantErr: true},
+		{name: "truncated block", stderr: `{"input_i" : "-23.00", }`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseLoudnorm([]byte(tt.stderr))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLoudnorm() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseLoudnorm() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoudnormTarget(t *testing.T) {
+	tests := []struct {
+		target  LoudnormTarget
+		wantErr bool
+	}{
+		{LoudnormTarget{Integrated: -16, TruePeak: -1.5, LRA: 11}, false},
+		{LoudnormTarget{Integrated: -80, TruePeak: -1.5, LRA: 11}, true},
+		{LoudnormTarget{Integrated: -16, TruePeak: 1, LRA: 11}, true},
+		{LoudnormTarget{Integrated: -16, TruePeak: -1.5, LRA: 0}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.target.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() error = %v, wantErr %v", tt.target, err, tt.wantErr)
+		}
+	}
+
+	m := Loudness{Integrated: -27.61, TruePeak: -4.47, LRA: 18.06, Threshold: -39.2, TargetOffset: 0.58}
+	want := "loudnorm=I=-16.0:TP=-1.5:LRA=11.0:measured_I=-27.61:measured_TP=-4.47:measured_LRA=18.06:measured_thresh=-39.20:offset=0.58:linear=true"
+	if got := (LoudnormTarget{Integrated: -16, TruePeak: -1.5, LRA: 11}).Filter(m); got != want {
+		t.Errorf("Filter() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,82 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Segment is one media segment listed in a media playlist.
+type Segment struct {
+	URI      string
+	Duration float64
+}
+
+// ParseMediaPlaylist returns the segments of an HLS media playlist in order.
+func ParseMediaPlaylist(playlist []byte) []Segment {
+	var out []Segment
+	var dur float64
+	pending := false
+	sc := bufio.NewScanner(bytes.NewReader(playlist))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			dur, _ = strconv.ParseFloat(v, 64)
+			pending = true
+		case line == "" || strings.HasPrefix(line, "#"):
+		case pending:
+			out = append(out, Segment{URI: line, Duration: dur})
+			pending = false
+		}
+	}
+	return out
+}
+
+// SegmentDurations returns just the durations of segs.
+func SegmentDurations(segs []Segment) []float64 {
+	out := make([]float64, len(segs))
+	for i, s := range segs {
+		out[i] = s.Duration
+	}
+	return out
+}
+
+// MeasureBandwidth computes the peak (highest single segment) and average
+// bitrate in bps of the rendition whose index.m3u8 lives in dir, from the
+// sizes of the segment files on disk. These are the BANDWIDTH and
+// AVERAGE-BANDWIDTH values of EXT-X-STREAM-INF.
+func MeasureBandwidth(dir string) (peak, avg int, err error) {
+	b, err := os.ReadFile(filepath.Join(dir, "index.m3u8"))
+	if err != nil {
+		return 0, 0, err
+	}
+	segs := ParseMediaPlaylist(b)
+	if len(segs) == 0 {
+		return 0, 0, fmt.Errorf("no segments in %s", dir)
+	}
+	var totalBits, totalDur float64
+	for _, s := range segs {
+		fi, err := os.Stat(filepath.Join(dir, filepath.FromSlash(s.URI)))
+		if err != nil {
+			return 0, 0, err
+		}
+		bits := float64(fi.Size() * 8)
+		totalBits += bits
+		totalDur += s.Duration
+		// very short trailing segments overstate the rate; ignore them for peak
+		if s.Duration >= 1 {
+			peak = max(peak, int(bits/s.Duration))
+		}
+	}
+	if totalDur <= 0 {
+		return 0, 0, fmt.Errorf("zero duration playlist in %s", dir)
+	}
+	avg = int(totalBits / totalDur)
+	return max(peak, avg), avg, nil
+}
@@ -0,0 +1,83 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMediaPlaylist(t *testing.T) {
+	tests := []struct {
+		name     string
+		playlist string
+		want     []Segment
+	}{
+		{"empty", "#EXTM3U\n#EXT-X-ENDLIST\n", nil},
+		{
+			name: "mpegts",
+			playlist: "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:4\n" +
+				"#EXTINF:4.000000,\nseg_00000.ts\n#EXTINF:1.5,\nseg_00001.ts\n#EXT-X-ENDLIST\n",
+			want: []Segment{{"seg_00000.ts", 4}, {"seg_00001.ts", 1.5}},
+		},
+		{
+			name: "fmp4 with map, keys and CRLF",
+			playlist: "#EXTM3U\r\n#EXT-X-MAP:URI=\"init.mp4\"\r\n#EXT-X-KEY:METHOD=AES-128,URI=\"../keys/00000.key\"\r\n" +
+				"#EXTINF:4.004,title\r\n\r\nseg_00000.m4s\r\n",
+			want: []Segment{{"seg_00000.m4s", 4.004}},
+		},
+		{"uri without extinf", "#EXTM3U\nstray.ts\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseMediaPlaylist([]byte(tt.playlist)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseMediaPlaylist() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMeasureBandwidth(t *testing.T) {
+	type seg struct {
+		dur   string
+		bytes int
+	}
+	tests := []struct {
+		name      string
+		segs      []seg
+		peak, avg int
+		wantErr   bool
+	}{
+		{"even segments", []seg{{"4", 500000}, {"4", 500000}}, 1000000, 1000000, false},
+		{"peak from largest", []seg{{"4", 1000000}, {"4", 500000}}, 2000000, 1500000, false},
+		{"short tail ignored for peak", []seg{{"4", 500000}, {"0.5", 100000}}, 1066666, 1066666, false},
+		{"no segments", nil, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var pl strings.Builder
+			pl.WriteString("#EXTM3U\n")
+			for i, s := range tt.segs {
+				name := fmt.Sprintf("seg_%05d.ts", i)
+				pl.WriteString("#EXTINF:" + s.dur + ",\n" + name + "\n")
+				if err := os.WriteFile(filepath.Join(dir, name), make([]byte, s.bytes), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.WriteFile(filepath.Join(dir, "index.m3u8"), []byte(pl.String()), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			peak, avg, err := MeasureBandwidth(dir)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("MeasureBandwidth() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if peak != tt.peak || avg != tt.avg {
+				t.Errorf("MeasureBandwidth() = %d, %d, want %d, %d", peak, avg, tt.peak, tt.avg)
+			}
+		})
+	}
+}
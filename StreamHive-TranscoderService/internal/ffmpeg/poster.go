@@ -0,0 +1,39 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// PosterWidth is the width poster candidates are scaled to.
+const PosterWidth = 1280
+
+// sceneThreshold is the select filter's scene score above which a frame
+// starts a new shot.
+const sceneThreshold = 0.3
+
+// BuildSceneCandidatesCommand writes up to n frames that start a new shot to
+// outDir/scene_NN.jpg. The first second is skipped since it is often a fade in.
+func BuildSceneCandidatesCommand(ctx context.Context, input, outDir string, n int) *exec.Cmd {
+	vf := fmt.Sprintf("select='gte(t,1)*gt(scene,%g)',scale='min(%d,iw)':-2", sceneThreshold, PosterWidth)
+	args := append([]string{"-y"}, inputArgs(input)...)
+	args = append(args,
+		"-vf", vf,
+		"-fps_mode", "vfr", "-frames:v", fmt.Sprint(n),
+		"-an", "-q:v", "2",
+		fmt.Sprintf("%s/scene_%%02d.jpg", outDir),
+	)
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
+
+// BuildFrameGrabCommand writes the frame at offset seconds to outPath.
+func BuildFrameGrabCommand(ctx context.Context, input, outPath string, offset float64) *exec.Cmd {
+	args := append([]string{"-y", "-ss", fmt.Sprintf("%.3f", offset)}, inputArgs(input)...)
+	args = append(args,
+		"-vf", fmt.Sprintf("scale='min(%d,iw)':-2", PosterWidth),
+		"-frames:v", "1", "-an", "-q:v", "2",
+		outPath,
+	)
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
@@ -0,0 +1,128 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Hover-preview clip formats.
+const (
+	PreviewMP4  = "mp4"
+	PreviewWebP = "webp"
+)
+
+// activityFPS is the rate frames are sampled at when measuring activity.
+const activityFPS = 2
+
+// ParsePreviewFormat accepts "mp4" and "webp"; empty means mp4.
+func ParsePreviewFormat(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", PreviewMP4:
+		return PreviewMP4, nil
+	case PreviewWebP:
+		return PreviewWebP, nil
+	}
+	return "", fmt.Errorf("unknown preview format %q", s)
+}
+
+// ActivitySample is the scene change score of one sampled frame.
+type ActivitySample struct {
+	Time  float64 // seconds
+	Score float64 // 0..1, difference to the previous sample
+}
+
+// MeasureActivity samples input at a low frame rate and returns how much
+// each sample differs from the one before it.
+func MeasureActivity(ctx context.Context, input string) ([]ActivitySample, error) {
+	vf := fmt.Sprintf("fps=%d,scale=160:-2,select='gte(scene,0)',metadata=print:key=lavfi.scene_score:file=-", activityFPS)
+	args := append([]string{"-nostats"}, inputArgs(input)...)
+	args = append(args, "-vf", vf, "-an", "-f", "null", "-")
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg activity: %w", err)
+	}
+	return parseActivity(out), nil
+}
+
+// parseActivity reads metadata=print output: a "frame:N pts:P pts_time:T"
+// line followed by "lavfi.scene_score=S".
+func parseActivity(out []byte) []ActivitySample {
+	var samples []ActivitySample
+	var t float64
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if i := strings.Index(line, "pts_time:"); i >= 0 {
+			if f := strings.Fields(line[i+len("pts_time:"):]); len(f) > 0 {
+				t, _ = strconv.ParseFloat(f[0], 64)
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "lavfi.scene_score="); ok {
+			s, err := strconv.ParseFloat(v, 64)
+			if err == nil {
+				samples = append(samples, ActivitySample{Time: t, Score: s})
+			}
+		}
+	}
+	return samples
+}
+
+// MostActiveWindow returns the start of the window seconds long clip with
+// the highest summed activity, within [0, duration-window]. Without samples
+// it falls back to a third of the way in, past typical intros.
+func MostActiveWindow(samples []ActivitySample, window, duration float64) float64 {
+	last := max(duration-window, 0)
+	if len(samples) == 0 {
+		return min(duration/3, last)
+	}
+	var best, sum float64
+	bestStart := -1.0
+	j := 0
+	for i, s := range samples {
+		if s.Time > last {
+			break
+		}
+		for j < len(samples) && samples[j].Time < s.Time+window {
+			sum += samples[j].Score
+			j++
+		}
+		if bestStart < 0 || sum > best {
+			best, bestStart = sum, s.Time
+		}
+		sum -= samples[i].Score
+	}
+	if bestStart < 0 {
+		return 0
+	}
+	return bestStart
+}
+
+// BuildPreviewCommand renders a silent clip of seconds length from start,
+// width pixels wide, as a small H.264 MP4 or a looping animated WebP.
+func BuildPreviewCommand(ctx context.Context, input, outPath string, start, seconds float64, width int, format string) *exec.Cmd {
+	args := []string{"-y",
+		"-ss", fmt.Sprintf("%.3f", start), "-t", fmt.Sprintf("%.3f", seconds),
+	}
+	args = append(args, inputArgs(input)...)
+	args = append(args, "-an")
+	if format == PreviewWebP {
+		args = append(args,
+			"-vf", fmt.Sprintf("fps=12,scale=%d:-2", width),
+			"-c:v", "libwebp", "-loop", "0", "-q:v", "60",
+		)
+	} else {
+		args = append(args,
+			"-vf", fmt.Sprintf("fps=15,scale=%d:-2", width),
+			"-c:v", "libx264", "-preset", "veryfast", "-crf", "28", "-pix_fmt", "yuv420p",
+			"-movflags", "+faststart",
+		)
+	}
+	return exec.CommandContext(ctx, "ffmpeg", append(args, outPath)...)
+}
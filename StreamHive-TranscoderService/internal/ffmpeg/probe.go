@@ -0,0 +1,360 @@
+package ffmpeg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// Available reports whether the ffmpeg and ffprobe binaries are on PATH.
+func Available() error {
+	for _, bin := range []string{"ffmpeg", "ffprobe"} {
+		if _, err := exec.LookPath(bin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ProbeResult is the subset of ffprobe output the pipeline cares about.
+// Bitrates are in bits per second.
+type ProbeResult struct {
+	Duration     float64
+	FileSize     int64
+	Width        int
+	Height       int
+	VideoCodec   string
+	VideoBitrate int
+	AudioCodec   string
+	AudioBitrate int
+	FrameRate    float64
+	// Rotation is the display rotation in degrees (0, 90, 180, 270) taken
+	// from the stream's rotate tag or display matrix side data.
+	Rotation int
+	// AudioTracks lists every audio stream, in source order.
+	AudioTracks []AudioTrack
+	// SubtitleTracks lists every subtitle stream, text or not, in source order.
+	SubtitleTracks []SubtitleTrack
+}
+
+// DisplaySize returns the frame size as it is presented to viewers, i.e.
+// with width and height swapped for sources rotated by 90 or 270 degrees.
+// ffmpeg autorotates on decode, so scale filters see these dimensions.
+func (p *ProbeResult) DisplaySize() (int, int) {
+	if p.Rotation == 90 || p.Rotation == 270 {
+		return p.Height, p.Width
+	}
+	return p.Width, p.Height
+}
+
+type probeOutput struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		BitRate      string `json:"bit_rate"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		Channels     int    `json:"channels"`
+		Tags         struct {
+			Rotate   string `json:"rotate"`
+			Language string `json:"language"`
+			Title    string `json:"title"`
+		} `json:"tags"`
+		Disposition struct {
+			Default int `json:"default"`
+			Forced  int `json:"forced"`
+		} `json:"disposition"`
+		SideDataList []struct {
+			SideDataType string  `json:"side_data_type"`
+			Rotation     float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		Duration string `json:"duration"`
+		Size     string `json:"size"`
+		BitRate  string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe runs ffprobe against input and extracts duration, size, the first
+// video/audio stream parameters and the lists of audio and subtitle tracks.
+func Probe(ctx context.Context, input string) (*ProbeResult, error) {
+	args := []string{"-v", "error", "-print_format", "json", "-show_format", "-show_streams"}
+	args = append(args, inputArgs(input)...)
+	cmd := exec.CommandContext(ctx, "ffprobe", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("ffprobe: %w: %s", err, redactURLs(strings.TrimSpace(stderr.String())))
+	}
+	return parseProbe(stdout.Bytes())
+}
+
+func parseProbe(b []byte) (*ProbeResult, error) {
+	var out probeOutput
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("ffprobe json: %w", err)
+	}
+
+	res := &ProbeResult{
+		Duration: parseFloat(out.Format.Duration),
+		FileSize: int64(parseFloat(out.Format.Size)),
+	}
+	haveVideo, haveAudio := false, false
+	for _, s := range out.Streams {
+		switch s.CodecType {
+		case "video":
+			if haveVideo {
+				continue
+			}
+			haveVideo = true
+			res.VideoCodec = s.CodecName
+			res.Width, res.Height = s.Width, s.Height
+			res.VideoBitrate = int(parseFloat(s.BitRate))
+			res.FrameRate = parseRate(s.AvgFrameRate)
+			if res.FrameRate == 0 {
+				res.FrameRate = parseRate(s.RFrameRate)
+			}
+			rot := parseFloat(s.Tags.Rotate)
+			for _, sd := range s.SideDataList {
+				if sd.SideDataType == "Display Matrix" {
+					rot = sd.Rotation
+				}
+			}
+			res.Rotation = normalizeRotation(int(rot))
+		case "audio":
+			res.AudioTracks = append(res.AudioTracks, AudioTrack{
+				Index:    len(res.AudioTracks),
+				Codec:    s.CodecName,
+				Language: s.Tags.Language,
+				Title:    s.Tags.Title,
+				Channels: s.Channels,
+				Default:  s.Disposition.Default == 1,
+			})
+			if haveAudio {
+				continue
+			}
+			haveAudio = true
+			res.AudioCodec = s.CodecName
+			res.AudioBitrate = int(parseFloat(s.BitRate))
+		case "subtitle":
+			res.SubtitleTracks = append(res.SubtitleTracks, SubtitleTrack{
+				Index:    len(res.SubtitleTracks),
+				Codec:    s.CodecName,
+				Language: s.Tags.Language,
+				Title:    s.Tags.Title,
+				Default:  s.Disposition.Default == 1,
+				Forced:   s.Disposition.Forced == 1,
+			})
+		}
+	}
+	if !haveVideo {
+		return nil, fmt.Errorf("no video stream found")
+	}
+
+	// Containers like MKV/WebM often carry no per-stream bitrate; derive the
+	// video bitrate from the overall container bitrate instead.
+	if res.VideoBitrate == 0 {
+		if total := int(parseFloat(out.Format.BitRate)); total > res.AudioBitrate {
+			res.VideoBitrate = total - res.AudioBitrate
+		}
+	}
+	return res, nil
+}
+
+// ProbeStartTime returns the presentation time (seconds) of the first frame
+// in path, e.g. an encoded rendition's media playlist. MPEG-TS output from
+// ffmpeg starts at 1.4s rather than 0.
+func ProbeStartTime(ctx context.Context, path string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=start_time",
+		"-of", "default=noprint_wrappers=1:nokey=1",
+		path,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("ffprobe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(stdout.String()), 64)
+	if err != nil {
+		return 0, fmt.Errorf("ffprobe start_time %q: %w", strings.TrimSpace(stdout.String()), err)
+	}
+	return v, nil
+}
+
+// ProbeCodecs returns the RFC 6381 codec strings ("avc1.640028",
+// "mp4a.40.2") of the first video and audio streams in path, e.g. an
+// encoded rendition or its fMP4 init segment. Either may be empty; video is
+// also empty when its level is unknown and cannot be derived, since a wrong
+// level makes players reject or misrank the variant.
+func ProbeCodecs(ctx context.Context, path string) (video, audio string, err error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_entries", "stream=codec_type,codec_name,profile,level,width,height,avg_frame_rate",
+		path,
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", "", fmt.Errorf("ffprobe: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	var out struct {
+		Streams []struct {
+			CodecType    string `json:"codec_type"`
+			CodecName    string `json:"codec_name"`
+			Profile      string `json:"profile"`
+			Level        int    `json:"level"`
+			Width        int    `json:"width"`
+			Height       int    `json:"height"`
+			AvgFrameRate string `json:"avg_frame_rate"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &out); err != nil {
+		return "", "", fmt.Errorf("ffprobe json: %w", err)
+	}
+	for _, s := range out.Streams {
+		switch {
+		case s.CodecType == "video" && video == "":
+			level := s.Level
+			if s.CodecName == "av1" && level < 0 {
+				// ffprobe reports -99 when the decoder does not expose
+				// seq_level_idx
+				level = AV1Level(s.Width, s.Height, parseRate(s.AvgFrameRate))
+			}
+			video = videoCodecString(s.CodecName, s.Profile, level)
+		case s.CodecType == "audio" && audio == "":
+			audio = audioCodecString(s.CodecName, s.Profile)
+		}
+	}
+	return video, audio, nil
+}
+
+// videoCodecString builds the RFC 6381 string for codec; it returns "" for
+// a known codec whose level is unknown (negative).
+func videoCodecString(codec, profile string, level int) string {
+	switch codec {
+	case "h264", "hevc", "av1":
+		if level < 0 {
+			return ""
+		}
+	}
+	switch codec {
+	case "h264":
+		// profile_idc + constraint flags as written by libx264
+		p := "6400"
+		switch profile {
+		case "Baseline", "Constrained Baseline":
+			p = "42E0"
+		case "Main":
+			p = "4D40"
+		}
+		return fmt.Sprintf("avc1.%s%02X", p, level)
+	case "hevc":
+		// general_profile_idc.compatibility_flags.tier+level_idc.constraints;
+		// ffprobe reports level_idc directly (120 = level 4.0)
+		if profile == "Main 10" {
+			return fmt.Sprintf("hvc1.2.4.L%d.B0", level)
+		}
+		return fmt.Sprintf("hvc1.1.6.L%d.B0", level)
+	case "av1":
+		// profile 0 (Main), seq_level_idx, Main tier, 8-bit (we encode yuv420p)
+		return fmt.Sprintf("av01.0.%02dM.08", level)
+	}
+	return codec
+}
+
+// av1Levels are the AV1 levels (Annex A) by seq_level_idx with their
+// maximum picture size, dimensions and display rate in samples per second.
+var av1Levels = []struct {
+	idx                   int
+	picSize, hSize, vSize int
+	displayRate           int64
+}{
+	{0, 147456, 2048, 1152, 4423680},        // 2.0
+	{1, 278784, 2816, 1584, 8363520},        // 2.1
+	{4, 665856, 4352, 2448, 19975680},       // 3.0
+	{5, 1065024, 5504, 3096, 31950720},      // 3.1
+	{8, 2359296, 6144, 3456, 70778880},      // 4.0
+	{9, 2359296, 6144, 3456, 141557760},     // 4.1
+	{12, 8912896, 8192, 4352, 267386880},    // 5.0
+	{13, 8912896, 8192, 4352, 534773760},    // 5.1
+	{14, 8912896, 8192, 4352, 1069547520},   // 5.2
+	{16, 35651584, 16384, 8704, 1069547520}, // 6.0
+	{17, 35651584, 16384, 8704, 2139095040}, // 6.1
+	{18, 35651584, 16384, 8704, 4278190080}, // 6.2
+}
+
+// AV1Level returns the lowest seq_level_idx whose limits fit a width x
+// height stream at fps, or -1 when the size is unknown or no level fits. An
+// unknown fps is taken as 60, since claiming too low a level is what breaks
+// playback. The ladder's Main tier bitrates stay below the level's maximum
+// bitrate.
+func AV1Level(width, height int, fps float64) int {
+	if width <= 0 || height <= 0 {
+		return -1
+	}
+	if fps <= 0 {
+		fps = 60
+	}
+	pic := width * height
+	rate := int64(float64(pic)*fps + 0.5)
+	for _, l := range av1Levels {
+		if pic <= l.picSize && width <= l.hSize && height <= l.vSize && rate <= l.displayRate {
+			return l.idx
+		}
+	}
+	return -1
+}
+
+func audioCodecString(codec, profile string) string {
+	switch codec {
+	case "aac":
+		if profile == "HE-AAC" {
+			return "mp4a.40.5"
+		}
+		return "mp4a.40.2"
+	case "mp3":
+		return "mp4a.40.34"
+	case "opus":
+		return "opus"
+	}
+	return codec
+}
+
+func normalizeRotation(deg int) int {
+	deg %= 360
+	if deg < 0 {
+		deg += 360
+	}
+	return deg
+}
+
+func parseFloat(s string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// parseRate parses ffprobe rationals such as "30000/1001".
+func parseRate(s string) float64 {
+	num, den, ok := strings.Cut(s, "/")
+	if !ok {
+		return parseFloat(s)
+	}
+	d := parseFloat(den)
+	if d == 0 {
+		return 0
+	}
+	return parseFloat(num) / d
+}
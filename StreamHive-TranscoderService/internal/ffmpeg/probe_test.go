@@ -0,0 +1,87 @@
+package ffmpeg
+
+import "testing"
+
+func TestVideoCodecString(t *testing.T) {
+	tests := []struct {
+		codec, profile string
+		level          int
+		want           string
+	}{
+		{"h264", "High", 40, "avc1.640028"},
+		{"h264", "Main", 31, "avc1.4D401F"},
+		{"h264", "Constrained Baseline", 30, "avc1.42E01E"},
+		{"h264", "High", -99, ""},
+		{"hevc", "Main", 120, "hvc1.1.6.L120.B0"},
+		{"hevc", "Main 10", 153, "hvc1.2.4.L153.B0"},
+		{"hevc", "Main", -1, ""},
+		{"av1", "Main", 8, "av01.0.08M.08"},
+		{"av1", "Main", 13, "av01.0.13M.08"},
+		{"av1", "Main", -1, ""},
+		{"vp9", "", -1, "vp9"},
+	}
+	for _, tt := range tests {
+		if got := videoCodecString(tt.codec, tt.profile, tt.level); got != tt.want {
+			t.Errorf("videoCodecString(%q, %q, %d) = %q, want %q", tt.codec, tt.profile, tt.level, got, tt.want)
+		}
+	}
+}
+
+func TestAV1Level(t *testing.T) {
+	tests := []struct {
+		width, height int
+		fps           float64
+		want          int
+	}{
+		{426, 240, 30, 0},
+		{640, 360, 30, 1},
+		{1280, 720, 30, 5},
+		{1280, 720, 60, 8},
+		{1920, 1080, 30, 8},
+		{1920, 1080, 0, 9}, // unknown rate taken as 60
+		{1080, 1920, 30, 8},
+		{3840, 2160, 60, 13},
+		{0, 1080, 30, -1},
+		{20000, 20000, 30, -1},
+	}
+	for _, tt := range tests {
+		if got := AV1Level(tt.width, tt.height, tt.fps); got != tt.want {
+			t.Errorf("AV1Level(%d, %d, %v) = %d, want %d", tt.width, tt.height, tt.fps, got, tt.want)
+		}
+	}
+}
+
+func TestAudioCodecString(t *testing.T) {
+	tests := []struct {
+		codec, profile string
+		want           string
+	}{
+		{"aac", "LC", "mp4a.40.2"},
+		{"aac", "HE-AAC", "mp4a.40.5"},
+		{"mp3", "", "mp4a.40.34"},
+		{"opus", "", "opus"},
+	}
+	for _, tt := range tests {
+		if got := audioCodecString(tt.codec, tt.profile); got != tt.want {
+			t.Errorf("audioCodecString(%q, %q) = %q, want %q", tt.codec, tt.profile, got, tt.want)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+	}{
+		{"30/1", 30},
+		{"30000/1001", 30000.0 / 1001},
+		{"25", 25},
+		{"0/0", 0},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		if got := parseRate(tt.in); got != tt.want {
+			t.Errorf("parseRate(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
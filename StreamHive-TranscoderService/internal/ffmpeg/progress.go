@@ -0,0 +1,109 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// progressArgs make ffmpeg write machine readable key=value progress blocks
+// to stdout instead of the interactive stats line.
+var progressArgs = []string{"-progress", "pipe:1", "-nostats"}
+
+// Progress is one block of ffmpeg -progress output.
+type Progress struct {
+	Frame   int
+	FPS     float64
+	OutTime time.Duration
+	// Speed is encoded media time per wall-clock time (1.0 = realtime).
+	Speed float64
+	Done  bool
+}
+
+// RunWithProgress runs a command built by this package, feeding each
+// progress block to fn. Stderr goes to the process stderr as before; on
+// failure the last stderr line is appended to the error.
+func RunWithProgress(cmd *exec.Cmd, fn func(Progress)) error {
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var tail tailWriter
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	cmd.Stderr = io.MultiWriter(cmd.Stderr, &tail)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	ParseProgress(out, fn)
+	if err := cmd.Wait(); err != nil {
+		if line := tail.lastLine(); line != "" {
+			return fmt.Errorf("%w: %s", err, redactURLs(line))
+		}
+		return err
+	}
+	return nil
+}
+
+// tailWriter keeps the last few KB written to it.
+type tailWriter struct {
+	buf []byte
+}
+
+const tailSize = 4096
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > tailSize {
+		w.buf = w.buf[len(w.buf)-tailSize:]
+	}
+	return len(p), nil
+}
+
+// lastLine returns the last meaningful line, skipping ffmpeg's generic
+// "Conversion failed!" trailer.
+func (w *tailWriter) lastLine() string {
+	lines := strings.Split(strings.TrimSpace(string(w.buf)), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if l := strings.TrimSpace(lines[i]); l != "" && l != "Conversion failed!" {
+			return l
+		}
+	}
+	return ""
+}
+
+// ParseProgress reads ffmpeg -progress output until EOF and calls fn at the
+// end of every block (each "progress=" line).
+func ParseProgress(r io.Reader, fn func(Progress)) {
+	var p Progress
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		k, v, ok := strings.Cut(strings.TrimSpace(sc.Text()), "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "frame":
+			p.Frame, _ = strconv.Atoi(v)
+		case "fps":
+			p.FPS, _ = strconv.ParseFloat(v, 64)
+		case "out_time_us", "out_time_ms": // both are microseconds
+			if us, err := strconv.ParseInt(v, 10, 64); err == nil && us >= 0 {
+				p.OutTime = time.Duration(us) * time.Microsecond
+			}
+		case "speed":
+			p.Speed, _ = strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "x"), 64)
+		case "progress":
+			p.Done = v == "end"
+			fn(p)
+		}
+	}
+	// drain so ffmpeg never blocks on a full pipe
+	_, _ = io.Copy(io.Discard, r)
+}
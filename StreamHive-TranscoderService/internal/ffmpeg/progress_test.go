@@ -0,0 +1,50 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want []Progress
+	}{
+		{
+			name: "blocks",
+			in: "frame=120\nfps=59.8\nout_time_us=4000000\nout_time=00:00:04.000000\nspeed=1.99x\nprogress=continue\n" +
+				"frame=240\nfps=60.0\nout_time_us=8000000\nspeed= 2x\nprogress=end\n",
+			want: []Progress{
+				{Frame: 120, FPS: 59.8, OutTime: 4 * time.Second, Speed: 1.99},
+				{Frame: 240, FPS: 60, OutTime: 8 * time.Second, Speed: 2, Done: true},
+			},
+		},
+		{
+			name: "out_time_ms is microseconds too",
+			in:   "out_time_ms=1500000\nprogress=continue\n",
+			want: []Progress{{OutTime: 1500 * time.Millisecond}},
+		},
+		{
+			name: "unparsable out_time keeps the previous one",
+			in:   "frame=10\nout_time_us=1000000\nprogress=continue\nframe=20\nout_time_us=N/A\nspeed=N/A\nprogress=continue\n",
+			want: []Progress{
+				{Frame: 10, OutTime: time.Second},
+				{Frame: 20, OutTime: time.Second},
+			},
+		},
+		{name: "incomplete block ignored", in: "frame=5\nfps=30\n", want: nil},
+		{name: "noise", in: "\n  garbage\nbitrate=N/A\n", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Progress
+			ParseProgress(strings.NewReader(tt.in), func(p Progress) { got = append(got, p) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProgress() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package ffmpeg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strings"
+)
+
+// SpriteTrackName is the WebVTT thumbnails track written next to the sprites.
+const SpriteTrackName = "thumbnails.vtt"
+
+// SpriteSpec describes the scrub-preview sprite sheets: one frame every
+// Interval seconds, scaled to Width x Height and tiled Columns x Rows per JPEG.
+type SpriteSpec struct {
+	Interval int // seconds between sampled frames
+	Columns  int
+	Rows     int
+	Width    int
+	Height   int // 0 derives it from the source aspect ratio
+}
+
+// ForSource returns s with Height set from the source display size,
+// rounded to an even number of pixels.
+func (s SpriteSpec) ForSource(srcW, srcH int) SpriteSpec {
+	if s.Height > 0 || srcW <= 0 || srcH <= 0 {
+		return s
+	}
+	h := int(math.Round(float64(s.Width)*float64(srcH)/float64(srcW)/2)) * 2
+	s.Height = max(h, 2)
+	return s
+}
+
+// SpriteFileName is the name of the n-th sprite sheet (1-based, as the
+// image2 muxer numbers them).
+func SpriteFileName(n int) string {
+	return fmt.Sprintf("sprite_%03d.jpg", n)
+}
+
+// BuildSpriteCommand samples input every s.Interval seconds and writes the
+// tiled sheets to outDir/sprite_NNN.jpg. The last sheet is padded with black.
+func BuildSpriteCommand(ctx context.Context, input, outDir string, s SpriteSpec) *exec.Cmd {
+	vf := fmt.Sprintf("fps=1/%d,scale=%d:%d,tile=%dx%d", s.Interval, s.Width, s.Height, s.Columns, s.Rows)
+	args := append([]string{"-y"}, inputArgs(input)...)
+	args = append(args,
+		"-vf", vf,
+		"-an", "-q:v", "5",
+		fmt.Sprintf("%s/sprite_%%03d.jpg", outDir),
+	)
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
+
+// BuildSpriteVTT renders the WebVTT thumbnails track for sheets sprite
+// sheets covering duration seconds. Each cue points at its tile with a
+// media fragment (sprite_001.jpg#xywh=x,y,w,h), relative to the track.
+func BuildSpriteVTT(duration float64, s SpriteSpec, sheets int) string {
+	perSheet := s.Columns * s.Rows
+	frames := min(int(math.Ceil(duration/float64(s.Interval))), sheets*perSheet)
+
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i := 0; i < frames; i++ {
+		start := float64(i * s.Interval)
+		end := math.Min(float64((i+1)*s.Interval), duration)
+		tile := i % perSheet
+		x, y := (tile%s.Columns)*s.Width, (tile/s.Columns)*s.Height
+		fmt.Fprintf(&b, "\n%s --> %s\n", vttTimestamp(start), vttTimestamp(end))
+		fmt.Fprintf(&b, "%s#xywh=%d,%d,%d,%d\n", SpriteFileName(i/perSheet+1), x, y, s.Width, s.Height)
+	}
+	return b.String()
+}
+
+// vttTimestamp formats seconds as a WebVTT cue timestamp (hh:mm:ss.ttt).
+func vttTimestamp(sec float64) string {
+	ms := int64(math.Round(sec * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,241 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// SubtitleGroupID is the EXT-X-MEDIA GROUP-ID every video rendition's
+// SUBTITLES attribute references.
+const SubtitleGroupID = "subs"
+
+// SubtitleDir holds one directory of WebVTT segments per subtitle track,
+// next to the video renditions.
+const SubtitleDir = "subtitles"
+
+// SubtitleSegmentSeconds matches the -hls_time of the media renditions.
+const SubtitleSegmentSeconds = 6
+
+// textSubtitleCodecs are the subtitle codecs ffmpeg can convert to WebVTT.
+// Bitmap subtitles (PGS, VobSub, DVB) would need OCR and are skipped.
+var textSubtitleCodecs = map[string]bool{
+	"subrip":   true,
+	"srt":      true,
+	"mov_text": true,
+	"ass":      true,
+	"ssa":      true,
+	"webvtt":   true,
+	"text":     true,
+}
+
+// SubtitleTrack is one subtitle stream of the source.
+type SubtitleTrack struct {
+	// Index is the stream's position among the source's subtitle streams,
+	// i.e. N in the 0:s:N map specifier.
+	Index    int
+	Codec    string
+	Language string // ISO 639-2 tag from the source, "" when untagged
+	Title    string
+	Default  bool
+	Forced   bool
+}
+
+// IsText reports whether the track can be converted to WebVTT.
+func (t SubtitleTrack) IsText() bool {
+	return textSubtitleCodecs[t.Codec]
+}
+
+// SubtitleRendition is one WebVTT caption track, stored under
+// subtitles/<name>/ and listed as EXT-X-MEDIA in the master.
+type SubtitleRendition struct {
+	Name  string // language tag, suffixed with the stream index when repeated
+	Track SubtitleTrack
+}
+
+// DisplayName is the NAME shown in player caption menus.
+func (s SubtitleRendition) DisplayName() string {
+	return trackLabel(s.Track.Title, s.Track.Language, fmt.Sprintf("Subtitles %d", s.Track.Index+1))
+}
+
+// SelectSubtitles returns one rendition per text subtitle track, in source
+// order. Tracks are named after their language ("en"); a repeated language
+// gets the stream index appended ("en_2"), untagged tracks use "und".
+func SelectSubtitles(tracks []SubtitleTrack) []SubtitleRendition {
+	var out []SubtitleRendition
+	seen := map[string]bool{}
+	for _, t := range tracks {
+		if !t.IsText() {
+			continue
+		}
+		name := LanguageTag(t.Language)
+		if name == "" {
+			name = "und"
+		}
+		if seen[name] {
+			name = fmt.Sprintf("%s_%d", name, t.Index)
+		}
+		seen[name] = true
+		out = append(out, SubtitleRendition{Name: name, Track: t})
+	}
+	return out
+}
+
+// BuildSubtitleExtractCommand converts every subtitle rendition to a single
+// WebVTT file, root/<name>.vtt, in one ffmpeg process. Video and audio are
+// not decoded.
+func BuildSubtitleExtractCommand(ctx context.Context, input, root string, subs []SubtitleRendition) *exec.Cmd {
+	args := append([]string{"-y"}, inputArgs(input)...)
+	for _, s := range subs {
+		args = append(args,
+			"-map", fmt.Sprintf("0:s:%d", s.Track.Index),
+			"-c:s", "webvtt",
+			"-f", "webvtt",
+			fmt.Sprintf("%s/%s.vtt", root, s.Name),
+		)
+	}
+	return exec.CommandContext(ctx, "ffmpeg", args...)
+}
+
+// VTTCue is one WebVTT cue.
+type VTTCue struct {
+	Start, End float64 // seconds
+	Settings   string  // cue settings after the end timestamp, e.g. "line:90%"
+	Text       string
+}
+
+// ParseVTT reads the cues of a WebVTT file. Cue identifiers, NOTE, STYLE
+// and REGION blocks are dropped; cue payloads are kept verbatim.
+func ParseVTT(data []byte) ([]VTTCue, error) {
+	var cues []VTTCue
+	var block []string
+	flush := func() error {
+		defer func() { block = block[:0] }()
+		for i, line := range block {
+			if !strings.Contains(line, "-->") {
+				continue
+			}
+			c, err := parseCueTiming(line)
+			if err != nil {
+				return err
+			}
+			c.Text = strings.Join(block[i+1:], "\n")
+			cues = append(cues, c)
+			return nil
+		}
+		return nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return cues, nil
+}
+
+func parseCueTiming(line string) (VTTCue, error) {
+	var c VTTCue
+	from, rest, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return c, fmt.Errorf("bad cue timing %q", line)
+	}
+	var err error
+	if c.Start, err = parseVTTTimestamp(strings.TrimSpace(from)); err != nil {
+		return c, err
+	}
+	if c.End, err = parseVTTTimestamp(fields[0]); err != nil {
+		return c, err
+	}
+	c.Settings = strings.Join(fields[1:], " ")
+	return c, nil
+}
+
+// parseVTTTimestamp accepts hh:mm:ss.ttt and mm:ss.ttt; a comma decimal
+// separator (SRT) is tolerated.
+func parseVTTTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("bad timestamp %q", s)
+	}
+	var sec float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad timestamp %q", s)
+		}
+		sec = sec*60 + v
+	}
+	return sec, nil
+}
+
+// SegmentVTT splits cues into WebVTT segments of segSeconds covering
+// duration. A cue spanning a boundary is repeated in every segment it
+// overlaps, as HLS requires. Each segment carries an X-TIMESTAMP-MAP tying
+// cue time 0 to the media's first presentation time, startTime seconds.
+func SegmentVTT(cues []VTTCue, duration float64, segSeconds int, startTime float64) [][]byte {
+	n := max(int(math.Ceil(duration/float64(segSeconds))), 1)
+	header := fmt.Sprintf("WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n", int64(math.Round(startTime*90000)))
+	segs := make([][]byte, n)
+	for i := range segs {
+		from, to := float64(i*segSeconds), float64((i+1)*segSeconds)
+		var b strings.Builder
+		b.WriteString(header)
+		for _, c := range cues {
+			if c.End <= from || (c.Start >= to && i < n-1) {
+				continue
+			}
+			fmt.Fprintf(&b, "\n%s --> %s", vttTimestamp(c.Start), vttTimestamp(c.End))
+			if c.Settings != "" {
+				b.WriteString(" " + c.Settings)
+			}
+			b.WriteString("\n" + c.Text + "\n")
+		}
+		segs[i] = []byte(b.String())
+	}
+	return segs
+}
+
+// SubtitleSegmentName is the name of the n-th WebVTT segment (0-based, like
+// the media segments).
+func SubtitleSegmentName(n int) string {
+	return fmt.Sprintf("seg_%05d.vtt", n)
+}
+
+// BuildSubtitlePlaylist renders the media playlist for segments WebVTT
+// segments of segSeconds covering duration.
+func BuildSubtitlePlaylist(segments int, duration float64, segSeconds int) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", segSeconds)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	for i := 0; i < segments; i++ {
+		d := math.Min(float64(segSeconds), duration-float64(i*segSeconds))
+		if d <= 0 {
+			d = float64(segSeconds)
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", d, SubtitleSegmentName(i))
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
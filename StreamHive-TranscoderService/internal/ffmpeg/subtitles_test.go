@@ -0,0 +1,146 @@
+package ffmpeg
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSelectSubtitles(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []SubtitleTrack
+		want   []string
+	}{
+		{"none", nil, nil},
+		{
+			name: "named by language",
+			tracks: []SubtitleTrack{
+				{Index: 0, Codec: "subrip", Language: "eng"},
+				{Index: 1, Codec: "mov_text", Language: "fre"},
+			},
+			want: []string{"en", "fr"},
+		},
+		{
+			name: "repeated language gets the stream index",
+			tracks: []SubtitleTrack{
+				{Index: 0, Codec: "subrip", Language: "eng"},
+				{Index: 1, Codec: "ass", Language: "eng", Title: "SDH"},
+			},
+			want: []string{"en", "en_1"},
+		},
+		{
+			name: "untagged tracks",
+			tracks: []SubtitleTrack{
+				{Index: 0, Codec: "webvtt"},
+				{Index: 1, Codec: "subrip", Language: "und"},
+			},
+			want: []string{"und", "und_1"},
+		},
+		{
+			name: "bitmap codecs skipped",
+			tracks: []SubtitleTrack{
+				{Index: 0, Codec: "hdmv_pgs_subtitle", Language: "eng"},
+				{Index: 1, Codec: "dvd_subtitle", Language: "eng"},
+				{Index: 2, Codec: "subrip", Language: "eng"},
+			},
+			want: []string{"en"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, s := range SelectSubtitles(tt.tracks) {
+				got = append(got, s.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SelectSubtitles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []VTTCue
+		wantErr bool
+	}{
+		{
+			name: "identifiers, notes and settings",
+			in:   "WEBVTT\r\n\r\nNOTE made by ffmpeg\r\n\r\n1\r\n00:00:01.000 --> 00:00:02.500 line:90%\r\nHello\r\n\r\n01:02.000 --> 01:03.000\r\nTwo\r\nlines\r\n",
+			want: []VTTCue{
+				{Start: 1, End: 2.5, Settings: "line:90%", Text: "Hello"},
+				{Start: 62, End: 63, Text: "Two\nlines"},
+			},
+		},
+		{
+			name: "srt comma separator tolerated",
+			in:   "WEBVTT\n\n00:00:01,250 --> 00:00:02,000\nx\n",
+			want: []VTTCue{{Start: 1.25, End: 2, Text: "x"}},
+		},
+		{name: "header only", in: "WEBVTT\n", want: nil},
+		{name: "bad timestamp", in: "WEBVTT\n\n00:00:aa.000 --> 00:00:02.000\nx\n", wantErr: true},
+		{name: "missing end", in: "WEBVTT\n\n00:00:01.000 -->\nx\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVTT([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseVTT() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVTT() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegmentVTT(t *testing.T) {
+	cues := []VTTCue{
+		{Start: 1, End: 2, Text: "first"},
+		{Start: 5, End: 7, Settings: "align:start", Text: "spans"},
+		{Start: 13, End: 14, Text: "late"},
+	}
+	segs := SegmentVTT(cues, 12, 6, 1.4)
+	tests := []struct {
+		seg     int
+		want    []string
+		notWant []string
+	}{
+		{0, []string{"X-TIMESTAMP-MAP=MPEGTS:126000,LOCAL:00:00:00.000", "00:00:01.000 --> 00:00:02.000\nfirst", "00:00:05.000 --> 00:00:07.000 align:start\nspans"}, []string{"late"}},
+		// cues past the duration land in the final segment
+		{1, []string{"spans", "late"}, []string{"first"}},
+	}
+	if len(segs) != len(tests) {
+		t.Fatalf("got %d segments, want %d", len(segs), len(tests))
+	}
+	for _, tt := range tests {
+		s := string(segs[tt.seg])
+		for _, w := range tt.want {
+			if !strings.Contains(s, w) {
+				t.Errorf("segment %d lacks %q:\n%s", tt.seg, w, s)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(s, w) {
+				t.Errorf("segment %d contains %q:\n%s", tt.seg, w, s)
+			}
+		}
+	}
+}
+
+func TestBuildSubtitlePlaylist(t *testing.T) {
+	got := BuildSubtitlePlaylist(3, 14.5, 6)
+	for _, want := range []string{
+		"#EXT-X-TARGETDURATION:6\n",
+		"#EXTINF:6.000,\nseg_00000.vtt\n",
+		"#EXTINF:6.000,\nseg_00001.vtt\n",
+		"#EXTINF:2.500,\nseg_00002.vtt\n#EXT-X-ENDLIST\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("playlist lacks %q:\n%s", want, got)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 	"time"
 
 	amqp "github.com/rabbitmq/amqp091-go"
@@ -20,6 +21,8 @@ type Consumer struct {
 	exchange         string
 	uploadRoutingKey string
 	queueName        string
+	retry            RetryPolicy
+	onParked         func(body []byte, err error)
 }
 
 func GetEnvInt(name string, def int) int {
@@ -48,6 +51,7 @@ func NewConsumerFromEnv(log *zap.SugaredLogger) (*Consumer, error) {
 		exchange:         getEnv("AMQP_EXCHANGE", "streamhive"),
 		uploadRoutingKey: getEnv("AMQP_UPLOAD_ROUTING_KEY", "video.uploaded"),
 		queueName:        getEnv("AMQP_QUEUE", "transcoder.video.uploaded"),
+		retry:            RetryPolicyFromEnv(),
 	}
 
 	retries := GetEnvInt("AMQP_CONNECT_RETRIES", 30)
@@ -79,12 +83,8 @@ func NewConsumerFromEnv(log *zap.SugaredLogger) (*Consumer, error) {
 	if err := ch.ExchangeDeclare(c.exchange, "topic", true, false, false, false, nil); err != nil {
 		return nil, fmt.Errorf("exchange declare: %w", err)
 	}
-	q, err := ch.QueueDeclare(c.queueName, true, false, false, false, nil)
-	if err != nil {
-		return nil, fmt.Errorf("queue declare: %w", err)
-	}
-	if err := ch.QueueBind(q.Name, c.uploadRoutingKey, c.exchange, false, nil); err != nil {
-		return nil, fmt.Errorf("queue bind: %w", err)
+	if err := DeclareWithRetry(conn, c.exchange, c.queueName, c.uploadRoutingKey, c.retry); err != nil {
+		return nil, err
 	}
 	return c, nil
 }
@@ -98,17 +98,36 @@ func (c *Consumer) Close() {
 // Conn returns the underlying AMQP connection for creating publishers.
 func (c *Consumer) Conn() *amqp.Connection { return c.conn }
 
+// Connected reports whether the AMQP connection is open.
+func (c *Consumer) Connected() bool { return c.conn != nil && !c.conn.IsClosed() }
+
 // Exchange returns the configured exchange name.
 func (c *Consumer) Exchange() string { return c.exchange }
 
-// Consume starts N independent consumers (one channel per worker) and calls handler per message.
-func (c *Consumer) Consume(ctx context.Context, workers int, handler func([]byte) error) error {
+// OnParked registers fn to be called when a message has used up its
+// attempts (or failed permanently) and was moved to the DLQ.
+func (c *Consumer) OnParked(fn func(body []byte, err error)) { c.onParked = fn }
+
+// Consume starts N independent consumers (one channel per worker) and calls
+// handler per message. When ctx is done the workers stop taking deliveries
+// and Consume drains: handlers keep running, with a context of their own,
+// for up to grace. Handlers still running after that are cancelled and
+// their messages requeued without counting an attempt; the job's checkpoint
+// lets the next delivery resume it. Consume returns once every worker has
+// settled its message.
+func (c *Consumer) Consume(ctx context.Context, workers int, grace time.Duration, handler func(context.Context, []byte) error) error {
 	if workers < 1 {
 		workers = 1
 	}
+	jobCtx, cancelJobs := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelJobs()
+
+	var wg sync.WaitGroup
 	errCh := make(chan error, workers)
 	for i := 0; i < workers; i++ {
+		wg.Add(1)
 		go func(idx int) {
+			defer wg.Done()
 			ch, err := c.conn.Channel()
 			if err != nil {
 				errCh <- fmt.Errorf("worker %d channel: %w", idx, err)
@@ -128,15 +147,22 @@ func (c *Consumer) Consume(ctx context.Context, workers int, handler func([]byte
 			for {
 				select {
 				case <-ctx.Done():
+					c.stopConsuming(ch, consumerTag, deliveries)
 					return
 				case d, ok := <-deliveries:
 					if !ok {
 						return
 					}
+					if ctx.Err() != nil {
+						// raced with shutdown; leave it to another replica
+						_ = d.Nack(false, true)
+						c.stopConsuming(ch, consumerTag, deliveries)
+						return
+					}
+					observeQueueLatency(d)
 					start := time.Now()
-					if err := handler(d.Body); err != nil {
-						c.log.Errorw("handler error", "err", err)
-						_ = d.Nack(false, false) // send to DLQ if configured
+					if err := handler(jobCtx, d.Body); err != nil {
+						c.settleFailed(jobCtx, ch, d, err)
 						continue
 					}
 					_ = d.Ack(false)
@@ -145,11 +171,108 @@ func (c *Consumer) Consume(ctx context.Context, workers int, handler func([]byte
 			}
 		}(i)
 	}
+	workersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(workersDone)
+	}()
 
 	select {
-	case <-ctx.Done():
-		return nil
 	case err := <-errCh:
 		return err
+	case <-workersDone:
+		return fmt.Errorf("amqp channels closed")
+	case <-ctx.Done():
+	}
+
+	c.log.Infow("draining, waiting for running jobs", "grace", grace.String())
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-workersDone:
+		c.log.Infow("drained")
+	case <-timer.C:
+		c.log.Warnw("drain grace period over, requeueing running jobs")
+		cancelJobs()
+		<-workersDone
+	}
+	return nil
+}
+
+// stopConsuming cancels a worker's consumer and requeues deliveries the
+// broker had already sent it.
+func (c *Consumer) stopConsuming(ch *amqp.Channel, consumerTag string, deliveries <-chan amqp.Delivery) {
+	if err := ch.Cancel(consumerTag, false); err != nil {
+		return
+	}
+	for d := range deliveries {
+		_ = d.Nack(false, true)
+	}
+}
+
+// Subscribe calls handler for every message published under routingKey. The
+// messages go to a server-named queue owned by this process, so every
+// replica sees every event; they are not retried, and a handler error is
+// only logged.
+func (c *Consumer) Subscribe(ctx context.Context, routingKey string, handler func([]byte) error) error {
+	ch, err := c.conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel: %w", err)
+	}
+	q, err := ch.QueueDeclare("", false, true, true, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("queue declare: %w", err)
+	}
+	if err := ch.QueueBind(q.Name, routingKey, c.exchange, false, nil); err != nil {
+		ch.Close()
+		return fmt.Errorf("queue bind: %w", err)
+	}
+	deliveries, err := ch.Consume(q.Name, "", true, true, false, false, nil)
+	if err != nil {
+		ch.Close()
+		return fmt.Errorf("consume: %w", err)
+	}
+	go func() {
+		defer ch.Close()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case d, ok := <-deliveries:
+				if !ok {
+					c.log.Warnw("subscription closed", "routingKey", routingKey)
+					return
+				}
+				if err := handler(d.Body); err != nil {
+					c.log.Warnw("subscription handler error", "routingKey", routingKey, "err", err)
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// settleFailed retries or parks a delivery whose handler failed. Failures
+// caused by the drain cancelling the job are requeued without counting as an
+// attempt.
+func (c *Consumer) settleFailed(jobCtx context.Context, ch *amqp.Channel, d amqp.Delivery, herr error) {
+	if jobCtx.Err() != nil {
+		c.log.Warnw("handler interrupted by shutdown, requeueing", "err", herr)
+		_ = d.Nack(false, true)
+		return
+	}
+	attempt := Attempts(d.Headers) + 1
+	parked, err := retryOrPark(jobCtx, ch, c.queueName, d, c.retry, herr)
+	if err != nil {
+		c.log.Errorw("settle failed message", "err", err, "handlerErr", herr, "attempt", attempt)
+	}
+	if !parked {
+		c.log.Warnw("handler error, retrying", "err", herr, "attempt", attempt, "maxAttempts", c.retry.MaxAttempts, "delay", c.retry.Delay(attempt).String())
+		return
+	}
+	c.log.Errorw("handler error, parked in dlq", "err", herr, "attempt", attempt, "dlq", DLQName(c.queueName))
+	if c.onParked != nil {
+		c.onParked(d.Body, herr)
 	}
 }
@@ -0,0 +1,32 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+var queueLatency = promauto.NewHistogram(prometheus.HistogramOpts{
+	Name:    "transcoder_queue_latency_seconds",
+	Help:    "Time from publishing an upload event to its first delivery to a worker.",
+	Buckets: prometheus.ExponentialBuckets(0.1, 2, 18),
+})
+
+// observeQueueLatency records how long a first delivery waited in the queue.
+// Retries are skipped since their wait is the retry delay. Publishers that
+// do not set the AMQP timestamp are not measured.
+func observeQueueLatency(d amqp.Delivery) {
+	if d.Timestamp.IsZero() || Attempts(d.Headers) > 0 {
+		return
+	}
+	published := d.Timestamp
+	// UploadService (amqplib) stamps milliseconds where AMQP has seconds
+	if published.After(time.Now().Add(24 * time.Hour)) {
+		published = time.UnixMilli(published.Unix())
+	}
+	if wait := time.Since(published); wait >= 0 {
+		queueLatency.Observe(wait.Seconds())
+	}
+}
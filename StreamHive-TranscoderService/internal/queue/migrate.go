@@ -0,0 +1,177 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// holdingQueueName is where a work queue's messages wait while the queue is
+// recreated with new arguments.
+func holdingQueueName(queue string) string { return queue + ".migrating" }
+
+// migrationLockName is the exclusive queue held by the instance migrating
+// queue.
+func migrationLockName(queue string) string { return queue + ".migration-lock" }
+
+// isPreconditionFailed reports whether err is the channel exception RabbitMQ
+// raises when a queue is redeclared with different arguments.
+func isPreconditionFailed(err error) bool {
+	var aerr *amqp.Error
+	return errors.As(err, &aerr) && aerr.Code == amqp.PreconditionFailed
+}
+
+// migrateQueue recreates queue, bound to routingKey on exchange, with args.
+// RabbitMQ cannot change the arguments of an existing queue, so the holding
+// queue is bound in its place, the ready messages are moved there, and queue
+// is deleted, redeclared and refilled. Only one instance migrates at a time;
+// while an older version still consumes queue nothing is changed and an
+// error asks for it to be stopped. A message a retry queue dead-letters to
+// queue in the instant it does not exist is lost.
+func migrateQueue(conn *amqp.Connection, exchange, queue, routingKey string, args amqp.Table) error {
+	ch, err := migrationChannel(conn, queue)
+	if err != nil {
+		return err
+	}
+	defer endMigration(ch, queue)
+
+	q, err := ch.QueueDeclarePassive(queue, true, false, false, false, nil)
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", queue, err)
+	}
+	if q.Consumers > 0 {
+		return fmt.Errorf("%s has %d consumer(s) of an older version without dead-letter arguments; stop them so the queue can be recreated", queue, q.Consumers)
+	}
+
+	hold := holdingQueueName(queue)
+	if _, err := ch.QueueDeclare(hold, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare %s: %w", hold, err)
+	}
+	if err := ch.QueueBind(hold, routingKey, exchange, false, nil); err != nil {
+		return fmt.Errorf("bind %s: %w", hold, err)
+	}
+	if err := ch.QueueUnbind(queue, routingKey, exchange, nil); err != nil {
+		return fmt.Errorf("unbind %s: %w", queue, err)
+	}
+	if err := moveMessages(ch, queue, hold); err != nil {
+		return err
+	}
+	if _, err := ch.QueueDelete(queue, true, true, false); err != nil {
+		return fmt.Errorf("delete %s: %w", queue, err)
+	}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		return fmt.Errorf("redeclare %s: %w", queue, err)
+	}
+	if err := ch.QueueBind(queue, routingKey, exchange, false, nil); err != nil {
+		return fmt.Errorf("bind %s: %w", queue, err)
+	}
+	return drainHoldingQueue(ch, exchange, queue, routingKey)
+}
+
+// resumeMigration finishes a migration that stopped after queue was
+// recreated, moving what is left in the holding queue back into it.
+func resumeMigration(conn *amqp.Connection, exchange, queue, routingKey string) error {
+	// a passive declare of a missing queue closes the channel, so probe on
+	// one of its own
+	probe, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel: %w", err)
+	}
+	_, err = probe.QueueDeclarePassive(holdingQueueName(queue), true, false, false, false, nil)
+	probe.Close()
+	var aerr *amqp.Error
+	if errors.As(err, &aerr) && aerr.Code == amqp.NotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("inspect %s: %w", holdingQueueName(queue), err)
+	}
+
+	ch, err := migrationChannel(conn, queue)
+	if err != nil {
+		return err
+	}
+	defer endMigration(ch, queue)
+	return drainHoldingQueue(ch, exchange, queue, routingKey)
+}
+
+// migrationChannel opens a channel in confirm mode holding the migration
+// lock of queue. The lock is an exclusive queue, so the broker also drops it
+// with the connection of an instance that dies mid-migration.
+func migrationChannel(conn *amqp.Connection, queue string) (*amqp.Channel, error) {
+	ch, err := conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("channel: %w", err)
+	}
+	if err := ch.Confirm(false); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("confirm mode: %w", err)
+	}
+	if _, err := ch.QueueDeclare(migrationLockName(queue), false, true, true, false, nil); err != nil {
+		ch.Close()
+		return nil, fmt.Errorf("%s is being migrated by another instance: %w", queue, err)
+	}
+	return ch, nil
+}
+
+// endMigration releases the migration lock of queue and closes ch.
+func endMigration(ch *amqp.Channel, queue string) {
+	_, _ = ch.QueueDelete(migrationLockName(queue), false, false, false)
+	ch.Close()
+}
+
+// drainHoldingQueue unbinds the holding queue of queue, moves its messages
+// into queue and deletes it.
+func drainHoldingQueue(ch *amqp.Channel, exchange, queue, routingKey string) error {
+	hold := holdingQueueName(queue)
+	if err := ch.QueueUnbind(hold, routingKey, exchange, nil); err != nil {
+		return fmt.Errorf("unbind %s: %w", hold, err)
+	}
+	if err := moveMessages(ch, hold, queue); err != nil {
+		return err
+	}
+	if _, err := ch.QueueDelete(hold, false, true, false); err != nil {
+		return fmt.Errorf("delete %s: %w", hold, err)
+	}
+	return nil
+}
+
+// moveMessages moves the ready messages of from to to through the default
+// exchange. Each is acked only once the broker has confirmed its copy, so a
+// failure leaves it in from. ch must be in confirm mode.
+func moveMessages(ch *amqp.Channel, from, to string) error {
+	for {
+		d, ok, err := ch.Get(from, false)
+		if err != nil {
+			return fmt.Errorf("get from %s: %w", from, err)
+		}
+		if !ok {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conf, err := ch.PublishWithDeferredConfirmWithContext(ctx, "", to, false, false, amqp.Publishing{
+			Headers:      d.Headers,
+			ContentType:  d.ContentType,
+			DeliveryMode: amqp.Persistent,
+			MessageId:    d.MessageId,
+			Timestamp:    d.Timestamp,
+			Body:         d.Body,
+		})
+		if err == nil {
+			var acked bool
+			if acked, err = conf.WaitContext(ctx); err == nil && !acked {
+				err = errors.New("not confirmed by the broker")
+			}
+		}
+		cancel()
+		if err != nil {
+			return errors.Join(fmt.Errorf("move to %s: %w", to, err), d.Nack(false, true))
+		}
+		if err := d.Ack(false); err != nil {
+			return err
+		}
+	}
+}
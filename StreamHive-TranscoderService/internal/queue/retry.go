@@ -0,0 +1,211 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers carried on retried and parked messages.
+const (
+	// HeaderAttempts counts failed deliveries so far.
+	HeaderAttempts = "x-attempts"
+	// HeaderLastError holds the handler error of the last failed delivery.
+	HeaderLastError = "x-last-error"
+	// HeaderOriginalQueue names the work queue a parked message came from.
+	HeaderOriginalQueue = "x-original-queue"
+	// HeaderParkedAt is the RFC 3339 time a message was parked.
+	HeaderParkedAt = "x-parked-at"
+)
+
+// RetryPolicy controls how failed messages are redelivered before they are
+// parked in the queue's DLQ.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of deliveries, including the first.
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+// RetryPolicyFromEnv reads AMQP_MAX_ATTEMPTS (default 5), AMQP_RETRY_BASE_MS
+// (default 10000) and AMQP_RETRY_MAX_MS (default 600000).
+func RetryPolicyFromEnv() RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts: GetEnvInt("AMQP_MAX_ATTEMPTS", 5),
+		BaseDelay:   time.Duration(GetEnvInt("AMQP_RETRY_BASE_MS", 10000)) * time.Millisecond,
+		MaxDelay:    time.Duration(GetEnvInt("AMQP_RETRY_MAX_MS", 600000)) * time.Millisecond,
+	}
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.BaseDelay <= 0 {
+		p.BaseDelay = time.Second
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// Delay is the wait before retry n (1-based): BaseDelay doubled per retry,
+// capped at MaxDelay.
+func (p RetryPolicy) Delay(n int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < n && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.MaxDelay)
+}
+
+// DLQName is the parking queue for queue.
+func DLQName(queue string) string { return queue + ".dlq" }
+
+// DeadLetterExchange is the exchange the broker dead-letters rejected
+// messages to.
+func DeadLetterExchange(exchange string) string { return exchange + ".dlx" }
+
+// retryQueueName is named after its delay so changing the policy declares
+// new queues instead of conflicting with the x-message-ttl of existing ones.
+func retryQueueName(queue string, d time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", queue, d.Milliseconds())
+}
+
+// DeclareWithRetry declares queue bound to routingKey on exchange, plus its
+// retry queues and DLQ. Messages the consumer rejects are dead-lettered by
+// the broker to the DLQ; retry queues hold a message for their TTL and then
+// dead-letter it back to queue through the default exchange. A queue an
+// older version declared without the dead-letter arguments is migrated
+// first (see migrateQueue).
+func DeclareWithRetry(conn *amqp.Connection, exchange, queue, routingKey string, p RetryPolicy) error {
+	ch, err := conn.Channel()
+	if err != nil {
+		return fmt.Errorf("channel: %w", err)
+	}
+	defer func() { ch.Close() }()
+
+	dlx := DeadLetterExchange(exchange)
+	dlq := DLQName(queue)
+	if err := ch.ExchangeDeclare(dlx, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("dlx declare: %w", err)
+	}
+	if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("dlq declare: %w", err)
+	}
+	if err := ch.QueueBind(dlq, dlq, dlx, false, nil); err != nil {
+		return fmt.Errorf("dlq bind: %w", err)
+	}
+
+	args := amqp.Table{"x-dead-letter-exchange": dlx, "x-dead-letter-routing-key": dlq}
+	if _, err := ch.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		if !isPreconditionFailed(err) {
+			return fmt.Errorf("queue declare: %w", err)
+		}
+		if err := migrateQueue(conn, exchange, queue, routingKey, args); err != nil {
+			return fmt.Errorf("migrate %s to dead-letter arguments: %w", queue, err)
+		}
+		// the refused declaration closed the channel
+		if ch, err = conn.Channel(); err != nil {
+			return fmt.Errorf("channel: %w", err)
+		}
+	} else if err := resumeMigration(conn, exchange, queue, routingKey); err != nil {
+		return fmt.Errorf("resume migration of %s: %w", queue, err)
+	}
+	if err := ch.QueueBind(queue, routingKey, exchange, false, nil); err != nil {
+		return fmt.Errorf("queue bind: %w", err)
+	}
+
+	seen := map[time.Duration]bool{}
+	for n := 1; n < p.MaxAttempts; n++ {
+		d := p.Delay(n)
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		rargs := amqp.Table{
+			"x-message-ttl":             int32(d.Milliseconds()),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}
+		if _, err := ch.QueueDeclare(retryQueueName(queue, d), true, false, false, false, rargs); err != nil {
+			return fmt.Errorf("retry queue declare: %w", err)
+		}
+	}
+	return nil
+}
+
+// Attempts returns the failed-delivery count recorded in headers.
+func Attempts(h amqp.Table) int {
+	switch v := h[HeaderAttempts].(type) {
+	case int:
+		return v
+	case int8:
+		return int(v)
+	case int16:
+		return int(v)
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	}
+	return 0
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one retrying cannot fix; the message is parked at once.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// retryOrPark settles a delivery whose handler returned herr: it is
+// republished to the retry queue for its attempt, or parked in the DLQ once
+// attempts are used up. It reports whether the message was parked.
+func retryOrPark(ctx context.Context, ch *amqp.Channel, queue string, d amqp.Delivery, p RetryPolicy, herr error) (bool, error) {
+	attempts := Attempts(d.Headers) + 1
+	headers := amqp.Table{}
+	for k, v := range d.Headers {
+		headers[k] = v
+	}
+	headers[HeaderAttempts] = int32(attempts)
+	headers[HeaderLastError] = herr.Error()
+
+	parked := attempts >= p.MaxAttempts || IsPermanent(herr)
+	target := retryQueueName(queue, p.Delay(attempts))
+	if parked {
+		target = DLQName(queue)
+		headers[HeaderOriginalQueue] = queue
+		headers[HeaderParkedAt] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	err := ch.PublishWithContext(ctx, "", target, false, false, amqp.Publishing{
+		Headers:      headers,
+		ContentType:  d.ContentType,
+		DeliveryMode: amqp.Persistent,
+		MessageId:    d.MessageId,
+		Timestamp:    d.Timestamp,
+		Body:         d.Body,
+	})
+	if err != nil {
+		if parked {
+			// the broker still dead-letters it to the DLQ, minus our headers
+			return true, errors.Join(err, d.Nack(false, false))
+		}
+		return false, errors.Join(err, d.Nack(false, true))
+	}
+	return parked, d.Ack(false)
+}
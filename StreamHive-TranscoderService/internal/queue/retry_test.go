@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.Delay(tt.n); got != tt.want {
+			t.Errorf("Delay(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestAttempts(t *testing.T) {
+	tests := []struct {
+		name string
+		h    amqp.Table
+		want int
+	}{
+		{"first delivery", nil, 0},
+		{"int32", amqp.Table{HeaderAttempts: int32(3)}, 3},
+		{"int64", amqp.Table{HeaderAttempts: int64(2)}, 2},
+		{"wrong type", amqp.Table{HeaderAttempts: "3"}, 0},
+	}
+	for _, tt := range tests {
+		if got := Attempts(tt.h); got != tt.want {
+			t.Errorf("%s: Attempts() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	base := errors.New("invalid json")
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"plain", base, false},
+		{"permanent", Permanent(base), true},
+		{"wrapped permanent", fmt.Errorf("handle: %w", Permanent(base)), true},
+		{"nil", Permanent(nil), false},
+	}
+	for _, tt := range tests {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("%s: IsPermanent() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsPreconditionFailed(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"inequivalent arg", &amqp.Error{Code: amqp.PreconditionFailed, Reason: "PRECONDITION_FAILED - inequivalent arg 'x-dead-letter-exchange'"}, true},
+		{"wrapped", fmt.Errorf("queue declare: %w", &amqp.Error{Code: amqp.PreconditionFailed}), true},
+		{"not found", &amqp.Error{Code: amqp.NotFound}, false},
+		{"closed", amqp.ErrClosed, false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isPreconditionFailed(tt.err); got != tt.want {
+			t.Errorf("%s: isPreconditionFailed() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/streamhive/blobstore"
+)
+
+// Client reads raw uploads and writes transcoder output through the blob
+// store selected by STORAGE_BACKEND.
+type Client struct {
+	store           blobstore.Store
+	rawBucket       string // Bucket for downloads (original videos)
+	processedBucket string // Bucket for uploads (HLS, thumbnails)
+	keysBucket      string // HLS content keys, never publicly readable
+	upload          uploadConfig
+}
+
+func NewClientFromEnv(ctx context.Context) (*Client, error) {
+	rawBucket := os.Getenv("MINIO_RAW_BUCKET")
+	if rawBucket == "" {
+		rawBucket = "raw-videos"
+	}
+
+	processedBucket := os.Getenv("MINIO_PROCESSED_BUCKET")
+	if processedBucket == "" {
+		processedBucket = "processed-videos" // A sensible default
+	}
+
+	keysBucket := os.Getenv("MINIO_KEYS_BUCKET")
+	if keysBucket == "" {
+		keysBucket = "hls-keys"
+	}
+
+	upload, err := uploadConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := blobstore.FromEnv(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{store: store, rawBucket: rawBucket, processedBucket: processedBucket, keysBucket: keysBucket, upload: upload}, nil
+}
+
+func (c *Client) DownloadTo(ctx context.Context, blobPath, localPath string) error {
+	if err := os.MkdirAll(filepath.Dir(localPath), 0o755); err != nil {
+		return err
+	}
+	return c.download(ctx, c.rawBucket, blobPath, localPath)
+}
+
+// RawSize returns the size of an uploaded video.
+func (c *Client) RawSize(ctx context.Context, blobPath string) (int64, error) {
+	return c.store.Size(ctx, c.rawBucket, blobPath)
+}
+
+// PresignRaw returns a URL that reads an uploaded video for ttl, for
+// ffmpeg to stream instead of staging the file.
+func (c *Client) PresignRaw(ctx context.Context, blobPath string, ttl time.Duration) (string, error) {
+	return c.store.Presign(ctx, c.rawBucket, blobPath, ttl)
+}
+
+// download streams an object into a local file.
+func (c *Client) download(ctx context.Context, bucket, blobPath, localPath string) error {
+	rc, err := c.store.Open(ctx, bucket, blobPath)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	f, err := os.Create(filepath.Clean(localPath))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, rc); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func detectContentType(path string) string {
+	low := strings.ToLower(path)
+	if strings.HasSuffix(low, ".m3u8") {
+		return "application/vnd.apple.mpegurl"
+	}
+	if strings.HasSuffix(low, ".ts") {
+		return "video/MP2T"
+	}
+	if strings.HasSuffix(low, ".m4s") {
+		return "video/iso.segment"
+	}
+	if strings.HasSuffix(low, ".vtt") {
+		return "text/vtt"
+	}
+	if strings.HasSuffix(low, ".mp4") {
+		return "video/mp4"
+	}
+	if strings.HasSuffix(low, ".jpg") || strings.HasSuffix(low, ".jpeg") {
+		return "image/jpeg"
+	}
+	if strings.HasSuffix(low, ".png") {
+		return "image/png"
+	}
+	if ct := mime.TypeByExtension(filepath.Ext(low)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+func (c *Client) DeleteBlob(ctx context.Context, blobPath string) error {
+	return c.store.Delete(ctx, c.processedBucket, blobPath)
+}
+
+func (c *Client) DeleteBlobsWithPrefix(ctx context.Context, prefix string) error {
+	return c.store.DeletePrefix(ctx, c.processedBucket, prefix)
+}
+
+func (c *Client) BlobExists(ctx context.Context, blobPath string) (bool, error) {
+	return c.store.Exists(ctx, c.processedBucket, blobPath)
+}
+
+// Ping checks that the blob store is reachable with the configured
+// credentials.
+func (c *Client) Ping(ctx context.Context) error {
+	_, err := c.store.Exists(ctx, c.processedBucket, ".healthz")
+	return err
+}
+
+// ErrBlobNotFound is returned by ReadBlob and ReadKey when the object does
+// not exist.
+var ErrBlobNotFound = blobstore.ErrNotFound
+
+// ReadBlob returns the content of a small object in the processed bucket.
+func (c *Client) ReadBlob(ctx context.Context, blobPath string) ([]byte, error) {
+	return c.store.Get(ctx, c.processedBucket, blobPath)
+}
+
+// WriteBlob stores data as an object in the processed bucket.
+func (c *Client) WriteBlob(ctx context.Context, blobPath string, data []byte, contentType string) error {
+	return c.store.Put(ctx, c.processedBucket, blobPath, bytes.NewReader(data), contentType)
+}
+
+// ReadKey returns an HLS content key from the keys bucket.
+func (c *Client) ReadKey(ctx context.Context, keyPath string) ([]byte, error) {
+	return c.store.Get(ctx, c.keysBucket, keyPath)
+}
+
+// WriteKey stores an HLS content key in the keys bucket.
+func (c *Client) WriteKey(ctx context.Context, keyPath string, key []byte) error {
+	return c.store.Put(ctx, c.keysBucket, keyPath, bytes.NewReader(key), "application/octet-stream")
+}
+
+// DeleteKeysWithPrefix removes the HLS content keys under prefix.
+func (c *Client) DeleteKeysWithPrefix(ctx context.Context, prefix string) error {
+	return c.store.DeletePrefix(ctx, c.keysBucket, prefix)
+}
+
+// DownloadPrefix copies every processed-bucket object under prefix into
+// localRoot, keeping the relative layout.
+func (c *Client) DownloadPrefix(ctx context.Context, prefix, localRoot string) error {
+	prefix = strings.TrimSuffix(prefix, "/") + "/"
+	keys, err := c.store.List(ctx, c.processedBucket, prefix)
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		local := filepath.Join(localRoot, filepath.FromSlash(strings.TrimPrefix(key, prefix)))
+		if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+			return err
+		}
+		if err := c.download(ctx, c.processedBucket, key, local); err != nil {
+			return fmt.Errorf("download %s: %w", key, err)
+		}
+	}
+	return nil
+}
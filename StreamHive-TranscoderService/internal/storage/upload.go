@@ -0,0 +1,217 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	uploadedBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transcoder_upload_bytes_total",
+		Help: "Bytes of output files uploaded to the processed bucket.",
+	})
+	uploadedObjects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transcoder_upload_objects_total",
+		Help: "Objects uploaded to the processed bucket.",
+	})
+	uploadRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transcoder_upload_retries_total",
+		Help: "Object uploads retried after a failed attempt.",
+	})
+	uploadFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transcoder_upload_failures_total",
+		Help: "Object uploads that failed after all attempts.",
+	})
+)
+
+// uploadConfig bounds output uploads.
+type uploadConfig struct {
+	concurrency int           // files uploaded at once by UploadDir
+	attempts    int           // tries per object
+	budget      time.Duration // deadline for one UploadDir; 0 = none
+}
+
+// uploadConfigFromEnv reads UPLOAD_CONCURRENCY (default 8), UPLOAD_ATTEMPTS
+// (default 3) and UPLOAD_TIMEOUT_SECONDS (default 1800, 0 = no limit).
+func uploadConfigFromEnv() (uploadConfig, error) {
+	cfg := uploadConfig{concurrency: 8, attempts: 3, budget: 30 * time.Minute}
+	for _, e := range []struct {
+		name string
+		min  int
+		set  func(int)
+	}{
+		{"UPLOAD_CONCURRENCY", 1, func(n int) { cfg.concurrency = n }},
+		{"UPLOAD_ATTEMPTS", 1, func(n int) { cfg.attempts = n }},
+		{"UPLOAD_TIMEOUT_SECONDS", 0, func(n int) { cfg.budget = time.Duration(n) * time.Second }},
+	} {
+		v := os.Getenv(e.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < e.min {
+			return cfg, fmt.Errorf("invalid %s %q: want an integer >= %d", e.name, v, e.min)
+		}
+		e.set(n)
+	}
+	return cfg, nil
+}
+
+// uploadRetryDelay is the wait before retry n (1-based): 500ms doubled per
+// retry, capped at 8s.
+func uploadRetryDelay(n int) time.Duration {
+	d := 500 * time.Millisecond << (n - 1)
+	if d <= 0 || d > 8*time.Second {
+		return 8 * time.Second
+	}
+	return d
+}
+
+// UploadFile uploads a local file to the processed bucket. A failed attempt
+// is retried with backoff, up to UPLOAD_ATTEMPTS tries in total.
+func (c *Client) UploadFile(ctx context.Context, localPath, blobPath string, contentType string) error {
+	for attempt := 1; ; attempt++ {
+		n, err := c.putFile(ctx, localPath, blobPath, contentType)
+		if err == nil {
+			uploadedBytes.Add(float64(n))
+			uploadedObjects.Inc()
+			return nil
+		}
+		if attempt >= c.upload.attempts || ctx.Err() != nil || errors.Is(err, fs.ErrNotExist) {
+			uploadFailures.Inc()
+			return err
+		}
+		uploadRetries.Inc()
+		select {
+		case <-time.After(uploadRetryDelay(attempt)):
+		case <-ctx.Done():
+			uploadFailures.Inc()
+			return err
+		}
+	}
+}
+
+// putFile makes one upload attempt and returns the bytes sent. The file is
+// reopened each time since a failed request may have consumed or closed it.
+func (c *Client) putFile(ctx context.Context, localPath, blobPath, contentType string) (int64, error) {
+	f, err := os.Open(filepath.Clean(localPath))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if err := c.store.Put(ctx, c.processedBucket, blobPath, f, contentType); err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// isPlaylist reports whether path is a playlist or manifest that lists
+// other files of the directory.
+func isPlaylist(path string) bool {
+	low := strings.ToLower(path)
+	return strings.HasSuffix(low, ".m3u8") || strings.HasSuffix(low, ".mpd")
+}
+
+// UploadDir uploads every file under localRoot to blobPrefix, keeping the
+// relative layout. Files are uploaded by up to UPLOAD_CONCURRENCY workers.
+// Playlists go last, once every segment is stored, so an interrupted upload
+// never leaves a playlist referencing missing segments. The directory must
+// be uploaded within UPLOAD_TIMEOUT_SECONDS.
+func (c *Client) UploadDir(ctx context.Context, localRoot, blobPrefix string) error {
+	var media, playlists []string
+	err := filepath.WalkDir(localRoot, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if isPlaylist(path) {
+			playlists = append(playlists, path)
+		} else {
+			media = append(media, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	uctx := ctx
+	if c.upload.budget > 0 {
+		var cancel context.CancelFunc
+		uctx, cancel = context.WithTimeout(ctx, c.upload.budget)
+		defer cancel()
+	}
+	err = c.uploadFiles(uctx, localRoot, blobPrefix, media)
+	if err == nil {
+		err = c.uploadFiles(uctx, localRoot, blobPrefix, playlists)
+	}
+	if err != nil && ctx.Err() == nil && errors.Is(uctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("upload budget of %s exceeded for %s: %w", c.upload.budget, blobPrefix, err)
+	}
+	return err
+}
+
+// uploadFiles uploads paths concurrently and returns the first error; the
+// remaining uploads are cancelled once one fails.
+func (c *Client) uploadFiles(ctx context.Context, localRoot, blobPrefix string, paths []string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	work := make(chan string)
+	for i := 0; i < min(c.upload.concurrency, len(paths)); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range work {
+				rel, err := filepath.Rel(localRoot, path)
+				if err == nil {
+					blobName := filepath.ToSlash(filepath.Join(blobPrefix, rel))
+					err = c.UploadFile(ctx, path, blobName, detectContentType(path))
+				}
+				if err != nil {
+					once.Do(func() {
+						firstErr = fmt.Errorf("upload %s: %w", filepath.ToSlash(rel), err)
+						cancel()
+					})
+				}
+			}
+		}()
+	}
+feed:
+	for _, p := range paths {
+		select {
+		case work <- p:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if firstErr != nil {
+		return firstErr
+	}
+	return ctx.Err()
+}
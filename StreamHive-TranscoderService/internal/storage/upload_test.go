@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/streamhive/blobstore"
+)
+
+// fakeStore records the order of Puts and fails the first failures[key]
+// attempts for a key. Only Put is implemented.
+type fakeStore struct {
+	blobstore.Store
+
+	mu       sync.Mutex
+	puts     []string // keys of successful Puts, in order
+	attempts map[string]int
+	failures map[string]int
+	block    bool // Put waits for its context to end
+}
+
+func (s *fakeStore) Put(ctx context.Context, bucket, key string, body io.Reader, contentType string) error {
+	if s.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[key]++
+	if s.attempts[key] <= s.failures[key] {
+		return errors.New("503 slow down")
+	}
+	s.puts = append(s.puts, key)
+	return nil
+}
+
+func newUploadTest(t *testing.T, cfg uploadConfig, failures map[string]int) (*Client, *fakeStore, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for _, name := range []string{
+		"master.m3u8", "manifest.mpd",
+		"720p/index.m3u8", "720p/init.mp4", "720p/seg_00000.m4s", "720p/seg_00001.m4s",
+		"360p/index.m3u8", "360p/init.mp4", "360p/seg_00000.m4s", "360p/seg_00001.m4s",
+	} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := &fakeStore{attempts: map[string]int{}, failures: failures}
+	return &Client{store: fs, processedBucket: "processed", upload: cfg}, fs, dir
+}
+
+func TestUploadDirPlaylistsLast(t *testing.T) {
+	c, fs, dir := newUploadTest(t, uploadConfig{concurrency: 4, attempts: 1}, nil)
+	if err := c.UploadDir(context.Background(), dir, "u1/v1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(fs.puts) != 10 {
+		t.Fatalf("uploaded %d objects, want 10: %v", len(fs.puts), fs.puts)
+	}
+	firstPlaylist := len(fs.puts)
+	for i, k := range fs.puts {
+		if !strings.HasPrefix(k, "u1/v1/") {
+			t.Errorf("key %q lacks the prefix", k)
+		}
+		if isPlaylist(k) {
+			firstPlaylist = min(firstPlaylist, i)
+		} else if i > firstPlaylist {
+			t.Errorf("%s uploaded after playlist %s: %v", k, fs.puts[firstPlaylist], fs.puts)
+		}
+	}
+}
+
+func TestUploadDirRetries(t *testing.T) {
+	tests := []struct {
+		name      string
+		attempts  int
+		failures  map[string]int
+		wantErr   bool
+		wantCalls map[string]int
+	}{
+		{
+			name:      "transient failure retried",
+			attempts:  3,
+			failures:  map[string]int{"u1/v1/720p/seg_00001.m4s": 1},
+			wantCalls: map[string]int{"u1/v1/720p/seg_00001.m4s": 2, "u1/v1/720p/seg_00000.m4s": 1},
+		},
+		{
+			name:      "segment out of attempts keeps playlists back",
+			attempts:  2,
+			failures:  map[string]int{"u1/v1/360p/seg_00000.m4s": 5},
+			wantErr:   true,
+			wantCalls: map[string]int{"u1/v1/360p/seg_00000.m4s": 2, "u1/v1/master.m3u8": 0, "u1/v1/360p/index.m3u8": 0},
+		},
+		{
+			name:      "playlist retried",
+			attempts:  2,
+			failures:  map[string]int{"u1/v1/master.m3u8": 1},
+			wantCalls: map[string]int{"u1/v1/master.m3u8": 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, fs, dir := newUploadTest(t, uploadConfig{concurrency: 2, attempts: tt.attempts}, tt.failures)
+			err := c.UploadDir(context.Background(), dir, "u1/v1")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("UploadDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for k, want := range tt.wantCalls {
+				if got := fs.attempts[k]; got != want {
+					t.Errorf("%s: %d attempts, want %d", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadDirBudget(t *testing.T) {
+	c, fs, dir := newUploadTest(t, uploadConfig{concurrency: 2, attempts: 3, budget: 50 * time.Millisecond}, nil)
+	fs.block = true
+	start := time.Now()
+	err := c.UploadDir(context.Background(), dir, "u1/v1")
+	if err == nil || !strings.Contains(err.Error(), "upload budget of 50ms exceeded") || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("UploadDir() error = %v, want the budget exceeded", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("UploadDir() took %s after a 50ms budget", d)
+	}
+}
+
+func TestUploadRetryDelay(t *testing.T) {
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, 500 * time.Millisecond},
+		{2, time.Second},
+		{5, 8 * time.Second},
+		{6, 8 * time.Second},
+		{70, 8 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := uploadRetryDelay(tt.n); got != tt.want {
+			t.Errorf("uploadRetryDelay(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// audioVariant is an encoded audio rendition plus what was measured from
+// its output.
+type audioVariant struct {
+	ffmpeg.AudioRendition
+	codecs       string // RFC 6381
+	bandwidth    int    // peak bps
+	avgBandwidth int    // average bps
+}
+
+// encodeAudio encodes the audio renditions into outRoot/<name>/ in one ffmpeg
+// process and returns them with their measured stats. onDone runs for each
+// rendition once encoded, as for encodeLadder.
+func (t *Transcoder) encodeAudio(ctx context.Context, input, outRoot string, audio []ffmpeg.AudioRendition, seg ffmpeg.SegmentType, onDone func(audioVariant) error) ([]audioVariant, error) {
+	names := make([]string, 0, len(audio))
+	for _, a := range audio {
+		if err := os.MkdirAll(filepath.Join(outRoot, a.Name), 0o755); err != nil {
+			return nil, err
+		}
+		names = append(names, a.Name)
+	}
+	cmd := ffmpeg.BuildAudioHLSCommand(ctx, input, outRoot, audio, seg)
+	start := time.Now()
+	// audio is a small fraction of the job; progress is reported for video only
+	if err := ffmpeg.RunWithProgress(cmd, func(ffmpeg.Progress) {}); err != nil {
+		return nil, fmt.Errorf("ffmpeg %v: %w", names, err)
+	}
+	elapsed := time.Since(start)
+
+	variants := make([]audioVariant, len(audio))
+	for i, a := range audio {
+		variants[i] = t.measureAudio(ctx, filepath.Join(outRoot, a.Name), a)
+		t.log.Infow("audio rendition done", "res", a.Name, "language", a.Track.Language, "default", a.Default, "codecs", variants[i].codecs, "avgBandwidth", variants[i].avgBandwidth, "ms", elapsed.Milliseconds())
+		if err := onDone(variants[i]); err != nil {
+			return nil, err
+		}
+	}
+	return variants, nil
+}
+
+// measureAudio probes the encoded audio rendition in dir. Failures fall back
+// to the nominal bitrate and the AAC-LC codec string.
+func (t *Transcoder) measureAudio(ctx context.Context, dir string, a ffmpeg.AudioRendition) audioVariant {
+	v := audioVariant{AudioRendition: a, codecs: ffmpeg.AudioCodecs, bandwidth: a.Bandwidth(), avgBandwidth: a.Bandwidth()}
+	if peak, avg, err := ffmpeg.MeasureBandwidth(dir); err != nil {
+		t.log.Warnw("measure bandwidth failed, using nominal", "res", a.Name, "err", err)
+	} else {
+		v.bandwidth, v.avgBandwidth = peak, avg
+	}
+	if _, acodec, err := ffmpeg.ProbeCodecs(ctx, filepath.Join(dir, "index.m3u8")); err == nil && acodec != "" {
+		v.codecs = acodec
+	}
+	return v
+}
+
+// LoudnessReport is the loudnorm measurement of the default audio track,
+// published on video.transcoded for diagnostics.
+type LoudnessReport struct {
+	Track    string                `json:"track"`
+	Measured ffmpeg.Loudness       `json:"measured"`
+	Target   ffmpeg.LoudnormTarget `json:"target"`
+}
+
+// normalizeAudio runs the first loudnorm pass over each pending track,
+// checkpoints the measurement and returns the tracks with the second pass
+// set as their filter. A track that cannot be measured (silence, decode
+// errors) is encoded as is.
+func (t *Transcoder) normalizeAudio(ctx context.Context, evt *UploadEvent, input string, audio []ffmpeg.AudioRendition, job *jobState) []ffmpeg.AudioRendition {
+	out := make([]ffmpeg.AudioRendition, len(audio))
+	measured := map[string]ffmpeg.Loudness{}
+	for i, a := range audio {
+		out[i] = a
+		start := time.Now()
+		m, err := ffmpeg.MeasureLoudness(ctx, input, a.Track.Index, t.opts.LoudnormTarget)
+		if err != nil {
+			t.log.Warnw("loudness measurement failed, encoding without normalization", "uploadId", evt.UploadID, "res", a.Name, "err", err)
+			continue
+		}
+		out[i].Filter = t.opts.LoudnormTarget.Filter(m)
+		measured[a.Name] = m
+		t.log.Infow("loudness measured", "uploadId", evt.UploadID, "res", a.Name, "integrated", m.Integrated, "truePeak", m.TruePeak, "lra", m.LRA, "targetOffset", m.TargetOffset, "ms", time.Since(start).Milliseconds())
+	}
+	if len(measured) > 0 {
+		_ = job.update(ctx, func(jm *jobManifest) {
+			if jm.Loudness == nil {
+				jm.Loudness = map[string]ffmpeg.Loudness{}
+			}
+			for name, m := range measured {
+				jm.Loudness[name] = m
+			}
+		})
+	}
+	return out
+}
+
+// loudnessReport returns the measurement of the default audio rendition, or
+// nil when normalization is off or it was not measured.
+func (t *Transcoder) loudnessReport(job *jobState, audio []ffmpeg.AudioRendition) *LoudnessReport {
+	if !t.opts.Loudnorm {
+		return nil
+	}
+	job.mu.Lock()
+	defer job.mu.Unlock()
+	for _, a := range audio {
+		if !a.Default {
+			continue
+		}
+		if m, ok := job.m.Loudness[a.Name]; ok {
+			return &LoudnessReport{Track: a.Name, Measured: m, Target: t.opts.LoudnormTarget}
+		}
+	}
+	return nil
+}
+
+// mergeAudio returns the resumed and newly encoded audio variants in source order.
+func mergeAudio(audio []ffmpeg.AudioRendition, lists ...[]audioVariant) []audioVariant {
+	byName := map[string]audioVariant{}
+	for _, l := range lists {
+		for _, v := range l {
+			byName[v.Name] = v
+		}
+	}
+	out := make([]audioVariant, 0, len(audio))
+	for _, a := range audio {
+		if v, ok := byName[a.Name]; ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
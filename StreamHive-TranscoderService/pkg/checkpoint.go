@@ -0,0 +1,203 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+	"github.com/streamhive/transcoder/internal/storage"
+)
+
+// jobManifestName is the checkpoint object stored under the job's HLS
+// prefix, so deleting the video's output also drops its checkpoint.
+const jobManifestName = ".job.json"
+
+// jobManifestVersion is bumped when the layout of the output changes so
+// that checkpointed renditions can no longer be reused. Version 2 split
+// audio out of the video renditions.
+const jobManifestVersion = 2
+
+// jobManifest records what a transcode job has finished, so a redelivered
+// or duplicate video.uploaded only redoes missing work.
+type jobManifest struct {
+	Version          int                            `json:"version"`
+	UploadID         string                         `json:"uploadId"`
+	RawVideoPath     string                         `json:"rawVideoPath"`
+	SegmentType      ffmpeg.SegmentType             `json:"segmentType"`
+	Encrypted        bool                           `json:"encrypted,omitempty"`
+	Renditions       map[string]renditionCheckpoint `json:"renditions"`
+	Audio            map[string]renditionCheckpoint `json:"audio"`
+	Loudness         map[string]ffmpeg.Loudness     `json:"loudness,omitempty"` // by audio rendition
+	DASH             bool                           `json:"dash,omitempty"`
+	Subtitles        bool                           `json:"subtitles,omitempty"`
+	PosterCandidates []string                       `json:"posterCandidates,omitempty"` // best first
+	SpriteTrack      string                         `json:"spriteTrackUrl,omitempty"`
+	PreviewURL       string                         `json:"previewUrl,omitempty"`
+	PublishedAt      *time.Time                     `json:"publishedAt,omitempty"`
+	UpdatedAt        time.Time                      `json:"updatedAt"`
+}
+
+// renditionCheckpoint is a rendition that was encoded and fully uploaded,
+// with the measurements the master playlist needs.
+type renditionCheckpoint struct {
+	Codecs       string    `json:"codecs,omitempty"`
+	Bandwidth    int       `json:"bandwidth"`
+	AvgBandwidth int       `json:"avgBandwidth"`
+	UploadedAt   time.Time `json:"uploadedAt"`
+}
+
+// jobState is the loaded checkpoint of one job. Renditions may finish
+// concurrently, so access goes through the mutex.
+type jobState struct {
+	t    *Transcoder
+	key  string
+	base string
+
+	mu sync.Mutex
+	m  jobManifest
+	// saveMu keeps concurrent saves in order so an older snapshot never
+	// overwrites a newer one
+	saveMu sync.Mutex
+}
+
+// loadJob reads the checkpoint under base. A missing checkpoint, an
+// unpublished one written by an older version, or one for a different source,
+// segment type or encryption starts a fresh job.
+func (t *Transcoder) loadJob(ctx context.Context, evt *UploadEvent, base string, seg ffmpeg.SegmentType, encrypted bool) (*jobState, error) {
+	j := &jobState{t: t, key: base + "/" + jobManifestName, base: base}
+	fresh := jobManifest{
+		Version:      jobManifestVersion,
+		UploadID:     evt.UploadID,
+		RawVideoPath: evt.RawVideoPath,
+		SegmentType:  seg,
+		Encrypted:    encrypted,
+		Renditions:   map[string]renditionCheckpoint{},
+		Audio:        map[string]renditionCheckpoint{},
+	}
+
+	data, err := t.store.ReadBlob(ctx, j.key)
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		j.m = fresh
+		return j, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read job manifest: %w", err)
+	}
+	if err := json.Unmarshal(data, &j.m); err != nil {
+		t.log.Warnw("corrupt job manifest, starting over", "uploadId", evt.UploadID, "err", err)
+		j.m = fresh
+		return j, nil
+	}
+	// an older published job is still done; only its partial work is stale
+	if j.m.Version != jobManifestVersion && j.m.PublishedAt == nil {
+		t.log.Infow("job manifest is from an older version, starting over", "uploadId", evt.UploadID, "version", j.m.Version)
+		j.m = fresh
+	} else if j.m.UploadID != evt.UploadID || j.m.RawVideoPath != evt.RawVideoPath || j.m.SegmentType != seg || j.m.Encrypted != encrypted {
+		t.log.Infow("job manifest is for a different source, segment type or encryption, starting over", "uploadId", evt.UploadID)
+		j.m = fresh
+	}
+	if j.m.Renditions == nil {
+		j.m.Renditions = map[string]renditionCheckpoint{}
+	}
+	if j.m.Audio == nil {
+		j.m.Audio = map[string]renditionCheckpoint{}
+	}
+	return j, nil
+}
+
+func (j *jobState) published() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.m.PublishedAt != nil
+}
+
+// split divides ladder into renditions whose uploaded output is still in
+// storage (returned as variants) and those that must be encoded.
+func (j *jobState) split(ctx context.Context, ladder []ffmpeg.Rendition) (done []variant, pending []ffmpeg.Rendition) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, r := range ladder {
+		cp, ok := j.checkpointedLocked(ctx, j.m.Renditions, r.Name)
+		if !ok {
+			pending = append(pending, r)
+			continue
+		}
+		done = append(done, variant{Rendition: r, codecs: cp.Codecs, bandwidth: cp.Bandwidth, avgBandwidth: cp.AvgBandwidth})
+	}
+	return done, pending
+}
+
+// splitAudio is split for the audio renditions.
+func (j *jobState) splitAudio(ctx context.Context, audio []ffmpeg.AudioRendition) (done []audioVariant, pending []ffmpeg.AudioRendition) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, a := range audio {
+		cp, ok := j.checkpointedLocked(ctx, j.m.Audio, a.Name)
+		if !ok {
+			pending = append(pending, a)
+			continue
+		}
+		done = append(done, audioVariant{AudioRendition: a, codecs: cp.Codecs, bandwidth: cp.Bandwidth, avgBandwidth: cp.AvgBandwidth})
+	}
+	return done, pending
+}
+
+// checkpointedLocked returns the checkpoint of rendition name in m if its
+// playlist is still in storage, dropping it otherwise. j.mu must be held.
+func (j *jobState) checkpointedLocked(ctx context.Context, m map[string]renditionCheckpoint, name string) (renditionCheckpoint, bool) {
+	cp, ok := m[name]
+	if !ok {
+		return cp, false
+	}
+	exists, err := j.t.store.BlobExists(ctx, fmt.Sprintf("%s/%s/index.m3u8", j.base, name))
+	if err != nil || !exists {
+		j.t.log.Warnw("checkpointed rendition missing from storage, re-encoding", "res", name, "err", err)
+		delete(m, name)
+		return cp, false
+	}
+	return cp, true
+}
+
+// markRendition checkpoints an uploaded rendition.
+func (j *jobState) markRendition(ctx context.Context, v variant) error {
+	j.mu.Lock()
+	j.m.Renditions[v.Name] = renditionCheckpoint{Codecs: v.codecs, Bandwidth: v.bandwidth, AvgBandwidth: v.avgBandwidth, UploadedAt: time.Now().UTC()}
+	j.mu.Unlock()
+	return j.save(ctx)
+}
+
+// markAudio checkpoints an uploaded audio rendition.
+func (j *jobState) markAudio(ctx context.Context, v audioVariant) error {
+	j.mu.Lock()
+	j.m.Audio[v.Name] = renditionCheckpoint{Codecs: v.codecs, Bandwidth: v.bandwidth, AvgBandwidth: v.avgBandwidth, UploadedAt: time.Now().UTC()}
+	j.mu.Unlock()
+	return j.save(ctx)
+}
+
+// update applies fn to the manifest and saves it.
+func (j *jobState) update(ctx context.Context, fn func(m *jobManifest)) error {
+	j.mu.Lock()
+	fn(&j.m)
+	j.mu.Unlock()
+	return j.save(ctx)
+}
+
+func (j *jobState) save(ctx context.Context) error {
+	j.saveMu.Lock()
+	defer j.saveMu.Unlock()
+	j.mu.Lock()
+	j.m.UpdatedAt = time.Now().UTC()
+	data, err := json.Marshal(j.m)
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := j.t.store.WriteBlob(ctx, j.key, data, "application/json"); err != nil {
+		return fmt.Errorf("write job manifest: %w", err)
+	}
+	return nil
+}
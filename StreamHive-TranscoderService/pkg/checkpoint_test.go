@@ -0,0 +1,170 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+	"github.com/streamhive/transcoder/internal/storage"
+)
+
+const checkpointBase = "hls/u1/up1"
+
+// newCheckpointTranscoder returns a Transcoder writing to a local blob store
+// in a temporary directory.
+func newCheckpointTranscoder(t *testing.T) *Transcoder {
+	t.Helper()
+	t.Setenv("STORAGE_BACKEND", "local")
+	t.Setenv("LOCAL_STORAGE_ROOT", t.TempDir())
+	store, err := storage.NewClientFromEnv(context.Background())
+	if err != nil {
+		t.Fatalf("NewClientFromEnv: %v", err)
+	}
+	return &Transcoder{log: zap.NewNop().Sugar(), store: store, opts: Options{WorkDir: t.TempDir()}, jobs: newJobRegistry(10)}
+}
+
+func checkpointEvent() *UploadEvent {
+	return &UploadEvent{UploadID: "up1", UserID: "u1", RawVideoPath: "u1/up1/source.mp4"}
+}
+
+func writeManifest(t *testing.T, tr *Transcoder, data []byte) {
+	t.Helper()
+	if err := tr.store.WriteBlob(context.Background(), checkpointBase+"/"+jobManifestName, data, "application/json"); err != nil {
+		t.Fatalf("WriteBlob: %v", err)
+	}
+}
+
+func TestJobResumeSkipsUploadedRenditions(t *testing.T) {
+	ctx := context.Background()
+	tr := newCheckpointTranscoder(t)
+	ladder := []ffmpeg.Rendition{{Name: "1080p"}, {Name: "720p"}, {Name: "480p"}}
+
+	job, err := tr.loadJob(ctx, checkpointEvent(), checkpointBase, ffmpeg.SegmentTS, false)
+	if err != nil {
+		t.Fatalf("loadJob: %v", err)
+	}
+	if done, pending := job.split(ctx, ladder); len(done) != 0 || len(pending) != 3 {
+		t.Fatalf("fresh job: %d done, %d pending, want 0 and 3", len(done), len(pending))
+	}
+	// 720p is uploaded; 480p was checkpointed but its output is gone since
+	if err := tr.store.WriteBlob(ctx, checkpointBase+"/720p/index.m3u8", []byte("#EXTM3U\n"), "application/vnd.apple.mpegurl"); err != nil {
+		t.Fatalf("WriteBlob: %v", err)
+	}
+	if err := job.markRendition(ctx, variant{Rendition: ladder[1], codecs: "avc1.64001f", bandwidth: 3000000, avgBandwidth: 2500000}); err != nil {
+		t.Fatalf("markRendition: %v", err)
+	}
+	if err := job.markRendition(ctx, variant{Rendition: ladder[2], bandwidth: 1500000}); err != nil {
+		t.Fatalf("markRendition: %v", err)
+	}
+
+	// the redelivered message loads the checkpoint afresh
+	job, err = tr.loadJob(ctx, checkpointEvent(), checkpointBase, ffmpeg.SegmentTS, false)
+	if err != nil {
+		t.Fatalf("loadJob: %v", err)
+	}
+	done, pending := job.split(ctx, ladder)
+	if len(done) != 1 || done[0].Name != "720p" {
+		t.Fatalf("done = %+v, want only 720p", done)
+	}
+	if done[0].codecs != "avc1.64001f" || done[0].bandwidth != 3000000 || done[0].avgBandwidth != 2500000 {
+		t.Errorf("720p resumed as %+v, want its checkpointed measurements", done[0])
+	}
+	var names []string
+	for _, r := range pending {
+		names = append(names, r.Name)
+	}
+	if len(names) != 2 || names[0] != "1080p" || names[1] != "480p" {
+		t.Errorf("pending = %v, want [1080p 480p]", names)
+	}
+}
+
+func TestDuplicateOfPublishedJobIsSkipped(t *testing.T) {
+	ctx := context.Background()
+	tr := newCheckpointTranscoder(t)
+
+	job, err := tr.loadJob(ctx, checkpointEvent(), checkpointBase, ffmpeg.SegmentTS, false)
+	if err != nil {
+		t.Fatalf("loadJob: %v", err)
+	}
+	now := time.Now().UTC()
+	if err := job.update(ctx, func(m *jobManifest) { m.PublishedAt = &now }); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	// the source does not exist and there is no publisher, so anything past
+	// the published check would fail
+	for i := 0; i < 2; i++ {
+		if err := tr.process(ctx, checkpointEvent(), nil); err != nil {
+			t.Fatalf("process duplicate %d: %v", i+1, err)
+		}
+	}
+	entries, err := os.ReadDir(tr.opts.WorkDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("duplicate created %d work dir entries, want none", len(entries))
+	}
+}
+
+func TestLoadJobIgnoresStaleManifest(t *testing.T) {
+	published := time.Now().UTC()
+	valid := jobManifest{
+		Version:      jobManifestVersion,
+		UploadID:     "up1",
+		RawVideoPath: "u1/up1/source.mp4",
+		SegmentType:  ffmpeg.SegmentTS,
+		Renditions:   map[string]renditionCheckpoint{"720p": {Bandwidth: 3000000}},
+	}
+	tests := []struct {
+		name      string
+		edit      func(m *jobManifest)
+		raw       []byte // written instead of the edited manifest
+		encrypted bool
+		wantKept  bool
+	}{
+		{name: "current", wantKept: true},
+		{name: "truncated", raw: []byte(`{"version":2,"uploadId":"up1","renditions":{"720p":{"bandw`)},
+		{name: "not json", raw: []byte("not json")},
+		{name: "older version", edit: func(m *jobManifest) { m.Version = jobManifestVersion - 1 }},
+		{name: "older version but published", edit: func(m *jobManifest) { m.Version, m.PublishedAt = jobManifestVersion-1, &published }, wantKept: true},
+		{name: "other upload", edit: func(m *jobManifest) { m.UploadID = "up2" }},
+		{name: "other source", edit: func(m *jobManifest) { m.RawVideoPath = "u1/up1/reupload.mp4" }},
+		{name: "other segment type", edit: func(m *jobManifest) { m.SegmentType = ffmpeg.SegmentFMP4 }},
+		{name: "now encrypted", encrypted: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := newCheckpointTranscoder(t)
+			data := tt.raw
+			if data == nil {
+				m := valid
+				if tt.edit != nil {
+					tt.edit(&m)
+				}
+				var err error
+				if data, err = json.Marshal(m); err != nil {
+					t.Fatal(err)
+				}
+			}
+			writeManifest(t, tr, data)
+
+			job, err := tr.loadJob(context.Background(), checkpointEvent(), checkpointBase, ffmpeg.SegmentTS, tt.encrypted)
+			if err != nil {
+				t.Fatalf("loadJob: %v", err)
+			}
+			_, kept := job.m.Renditions["720p"]
+			if kept != tt.wantKept {
+				t.Errorf("checkpointed 720p kept = %v, want %v", kept, tt.wantKept)
+			}
+			if !kept && (job.m.Version != jobManifestVersion || job.m.UploadID != "up1" || job.m.Encrypted != tt.encrypted || job.m.PublishedAt != nil) {
+				t.Errorf("fresh manifest = %+v, want one for this job", job.m)
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// Encode modes for the rendition ladder.
+const (
+	// EncodeSingle runs one ffmpeg process that decodes the source once and
+	// writes every rendition.
+	EncodeSingle = "single"
+	// EncodeParallel runs one ffmpeg process per rendition, concurrently.
+	EncodeParallel = "parallel"
+	// EncodeSequential runs one ffmpeg process per rendition, one at a time.
+	EncodeSequential = "sequential"
+)
+
+func parseEncodeMode(s string) (string, error) {
+	switch s {
+	case "":
+		return EncodeSingle, nil
+	case EncodeSingle, EncodeParallel, EncodeSequential:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown ENCODE_MODE %q", s)
+}
+
+// encodeLadder encodes all renditions into outRoot/<name>/ using the
+// configured mode and returns them with their measured stats, in ladder order.
+// onDone runs for each rendition as soon as it is encoded; an error from it
+// fails the ladder like an encode error.
+func (t *Transcoder) encodeLadder(ctx context.Context, input, outRoot string, ladder []ffmpeg.Rendition, seg ffmpeg.SegmentType, prog *progressReporter, onDone func(variant) error) ([]variant, error) {
+	for _, r := range ladder {
+		if err := os.MkdirAll(filepath.Join(outRoot, r.Name), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	switch t.opts.EncodeMode {
+	case EncodeSequential:
+		return t.encodeEach(ctx, input, outRoot, ladder, seg, 1, prog, onDone)
+	case EncodeParallel:
+		n := t.opts.EncodeParallelism
+		if n <= 0 {
+			n = len(ladder)
+		}
+		return t.encodeEach(ctx, input, outRoot, ladder, seg, n, prog, onDone)
+	}
+	return t.encodeSingle(ctx, input, outRoot, ladder, seg, prog, onDone)
+}
+
+// encodeSingle encodes the whole ladder in one ffmpeg process. Renditions
+// finish together, so each "rendition done" log carries the shared time.
+func (t *Transcoder) encodeSingle(ctx context.Context, input, outRoot string, ladder []ffmpeg.Rendition, seg ffmpeg.SegmentType, prog *progressReporter, onDone func(variant) error) ([]variant, error) {
+	cmd := ffmpeg.BuildMultiHLSCommand(ctx, input, outRoot, ladder, seg)
+	names := renditionNames(ladder)
+	start := time.Now()
+	if err := ffmpeg.RunWithProgress(cmd, func(p ffmpeg.Progress) { prog.update(names, p) }); err != nil {
+		return nil, fmt.Errorf("ffmpeg %v: %w", names, err)
+	}
+	elapsed := time.Since(start)
+
+	variants := make([]variant, len(ladder))
+	for i, r := range ladder {
+		variants[i] = t.measureVariant(ctx, filepath.Join(outRoot, r.Name), r)
+		t.logRenditionDone(variants[i], EncodeSingle, elapsed)
+		if err := onDone(variants[i]); err != nil {
+			return nil, err
+		}
+	}
+	return variants, nil
+}
+
+// encodeEach runs one ffmpeg process per rendition with at most parallelism
+// running at once. The first failure cancels the remaining encodes.
+func (t *Transcoder) encodeEach(ctx context.Context, input, outRoot string, ladder []ffmpeg.Rendition, seg ffmpeg.SegmentType, parallelism int, prog *progressReporter, onDone func(variant) error) ([]variant, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	mode := EncodeParallel
+	if parallelism == 1 {
+		mode = EncodeSequential
+	}
+
+	variants := make([]variant, len(ladder))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+	for i, r := range ladder {
+		sem <- struct{}{}
+		if ctx.Err() != nil {
+			<-sem
+			break
+		}
+		wg.Add(1)
+		go func(i int, r ffmpeg.Rendition) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			dir := filepath.Join(outRoot, r.Name)
+			cmd := ffmpeg.BuildHLSCommand(ctx, input, dir, r, seg)
+			names := []string{r.Name}
+			start := time.Now()
+			if err := ffmpeg.RunWithProgress(cmd, func(p ffmpeg.Progress) { prog.update(names, p) }); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("ffmpeg %s: %w", r.Name, err)
+					cancel()
+				})
+				return
+			}
+			elapsed := time.Since(start)
+			variants[i] = t.measureVariant(ctx, dir, r)
+			t.logRenditionDone(variants[i], mode, elapsed)
+			if err := onDone(variants[i]); err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(i, r)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return variants, nil
+}
+
+func (t *Transcoder) logRenditionDone(v variant, mode string, elapsed time.Duration) {
+	renditionEncodeSeconds.WithLabelValues(v.Name, v.Codec.Family, mode).Observe(elapsed.Seconds())
+	t.log.Infow("rendition done", "res", v.Name, "codec", v.Codec.Family, "size", v.Resolution(), "codecs", v.codecs, "avgBandwidth", v.avgBandwidth, "mode", mode, "ms", elapsed.Milliseconds())
+}
@@ -0,0 +1,111 @@
+package pkg
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+	"github.com/streamhive/transcoder/internal/storage"
+)
+
+const (
+	// EncryptNone uploads clear segments.
+	EncryptNone = "none"
+	// EncryptPrivate encrypts the segments of private videos.
+	EncryptPrivate = "private"
+	// EncryptAll encrypts the segments of every video.
+	EncryptAll = "all"
+)
+
+func parseEncryptionMode(s string) (string, error) {
+	switch s {
+	case "":
+		return EncryptNone, nil
+	case EncryptNone, EncryptPrivate, EncryptAll:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown HLS_ENCRYPTION %q", s)
+}
+
+// encrypts reports whether evt's segments are encrypted under the
+// configured mode.
+func (t *Transcoder) encrypts(evt *UploadEvent) bool {
+	switch t.opts.Encryption {
+	case EncryptAll:
+		return true
+	case EncryptPrivate:
+		return evt.IsPrivate
+	}
+	return false
+}
+
+// keyring holds the AES-128 content keys of one video. Keys live in the keys
+// bucket under <userId>/<uploadId>/, never next to the segments, and are
+// created on first use; a resumed job reads back the keys its uploaded
+// renditions were encrypted with.
+type keyring struct {
+	store  *storage.Client
+	prefix string
+
+	mu   sync.Mutex
+	keys map[int][]byte
+}
+
+func newKeyring(store *storage.Client, evt *UploadEvent) *keyring {
+	return &keyring{store: store, prefix: evt.UserID + "/" + evt.UploadID, keys: map[int][]byte{}}
+}
+
+// key returns content key n, creating and storing it if it does not exist.
+// Renditions encrypt concurrently in parallel mode, so the lookup and the
+// creation happen under one lock.
+func (k *keyring) key(ctx context.Context, n int) ([]byte, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if key, ok := k.keys[n]; ok {
+		return key, nil
+	}
+	path := k.prefix + "/" + ffmpeg.KeyName(n)
+	key, err := k.store.ReadKey(ctx, path)
+	switch {
+	case errors.Is(err, storage.ErrBlobNotFound):
+		key = make([]byte, 16)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+		if err := k.store.WriteKey(ctx, path, key); err != nil {
+			return nil, fmt.Errorf("store key: %w", err)
+		}
+	case err != nil:
+		return nil, fmt.Errorf("read key: %w", err)
+	case len(key) != 16:
+		return nil, fmt.Errorf("key %s is %d bytes", path, len(key))
+	}
+	k.keys[n] = key
+	return key, nil
+}
+
+// uploadRendition uploads the rendition encoded into outRoot/<name>/ to
+// base/<name>/. With keys set the segments are encrypted into a copy under
+// work first; the clear output stays on disk for the steps that read it
+// back (caption timing).
+func (t *Transcoder) uploadRendition(ctx context.Context, keys *keyring, work, outRoot, base, name string) error {
+	dir := filepath.Join(outRoot, name)
+	if keys != nil {
+		enc := filepath.Join(work, "encrypted", name)
+		err := ffmpeg.EncryptRendition(dir, enc, t.opts.KeyRotationSegments, func(n int) ([]byte, error) {
+			return keys.key(ctx, n)
+		})
+		if err != nil {
+			return fmt.Errorf("encrypt %s: %w", name, err)
+		}
+		dir = enc
+	}
+	if err := t.store.UploadDir(ctx, dir, base+"/"+name); err != nil {
+		return fmt.Errorf("upload %s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Error classes reported on video.transcode_failed events.
+const (
+	ErrClassDownload = "download"
+	ErrClassProbe    = "probe"
+	ErrClassFFmpeg   = "ffmpeg"
+	ErrClassUpload   = "upload"
+	ErrClassInternal = "internal"
+)
+
+// maxFailureMessage bounds the message stored on the catalog row.
+const maxFailureMessage = 500
+
+// JobError is a transcode failure tagged with the pipeline step that failed.
+type JobError struct {
+	Class string
+	Err   error
+}
+
+func (e *JobError) Error() string { return fmt.Sprintf("%s: %v", e.Class, e.Err) }
+func (e *JobError) Unwrap() error { return e.Err }
+
+// jobErr tags err with class unless it already carries one.
+func jobErr(class string, err error) error {
+	var je *JobError
+	if err == nil || errors.As(err, &je) {
+		return err
+	}
+	return &JobError{Class: class, Err: err}
+}
+
+// errorClass returns the class of err, ErrClassInternal when untagged.
+func errorClass(err error) string {
+	var je *JobError
+	if errors.As(err, &je) {
+		return je.Class
+	}
+	return ErrClassInternal
+}
+
+// ReportFailure publishes video.transcode_failed for an upload event whose
+// retries are exhausted. It is best-effort: events without an uploadId are
+// only logged. Jobs interrupted by shutdown are not failures.
+func (t *Transcoder) ReportFailure(ctx context.Context, body []byte, err error) {
+	if t.failed == nil || ctx.Err() != nil {
+		return
+	}
+	var evt UploadEvent
+	if jerr := json.Unmarshal(body, &evt); jerr != nil || evt.UploadID == "" {
+		t.log.Warnw("cannot report failure for unparseable event", "err", err)
+		return
+	}
+	msg := err.Error()
+	var je *JobError
+	if errors.As(err, &je) {
+		msg = je.Err.Error()
+	}
+	if len(msg) > maxFailureMessage {
+		msg = strings.ToValidUTF8(msg[:maxFailureMessage], "")
+	}
+	out := map[string]any{
+		"uploadId":   evt.UploadID,
+		"userId":     evt.UserID,
+		"errorClass": errorClass(err),
+		"error":      msg,
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+	}
+	if perr := t.failed.PublishJSON(ctx, out); perr != nil {
+		t.log.Errorw("publish transcode_failed", "uploadId", evt.UploadID, "err", perr)
+		return
+	}
+	t.log.Infow("published transcode_failed", "uploadId", evt.UploadID, "class", out["errorClass"])
+}
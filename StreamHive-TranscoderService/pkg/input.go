@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+const (
+	// InputDownload stages the raw video in the work directory first.
+	InputDownload = "download"
+	// InputStream has ffmpeg read the raw video from a presigned URL.
+	InputStream = "stream"
+)
+
+func parseInputMode(s string) (string, error) {
+	switch s {
+	case "":
+		return InputDownload, nil
+	case InputDownload, InputStream:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown INPUT_MODE %q", s)
+}
+
+// ErrInsufficientDisk is returned when the work directory cannot hold a job.
+var ErrInsufficientDisk = errors.New("insufficient disk space")
+
+// openInput returns what ffmpeg reads the raw video from and its size. In
+// stream mode that is a presigned URL; otherwise, or when the store cannot
+// presign, the video is downloaded into work. Either way the renditions are
+// staged in work, so the disk preflight runs before anything is fetched.
+func (t *Transcoder) openInput(ctx context.Context, evt *UploadEvent, work string) (string, int64, error) {
+	size, err := t.store.RawSize(ctx, evt.RawVideoPath)
+	if err != nil {
+		return "", 0, jobErr(ErrClassDownload, fmt.Errorf("stat source: %w", err))
+	}
+	if t.opts.InputMode == InputStream {
+		url, err := t.store.PresignRaw(ctx, evt.RawVideoPath, t.opts.InputURLTTL)
+		if err == nil {
+			if err := t.checkDisk(work, 0); err != nil {
+				return "", 0, jobErr(ErrClassDownload, err)
+			}
+			t.log.Infow("streaming input", "uploadId", evt.UploadID, "size", size, "remote", ffmpeg.IsRemote(url))
+			return url, size, nil
+		}
+		t.log.Warnw("cannot presign source, downloading it instead", "uploadId", evt.UploadID, "err", err)
+	}
+	if err := t.checkDisk(work, size); err != nil {
+		return "", 0, jobErr(ErrClassDownload, err)
+	}
+	inputPath := filepath.Join(work, "input.mp4")
+	if err := t.store.DownloadTo(ctx, evt.RawVideoPath, inputPath); err != nil {
+		return "", 0, jobErr(ErrClassDownload, err)
+	}
+	return inputPath, size, nil
+}
+
+// checkDisk fails fast when dir has less free space than staged bytes plus
+// the DiskReserve headroom for the encoded output. Filesystems whose free
+// space cannot be read are not checked.
+func (t *Transcoder) checkDisk(dir string, staged int64) error {
+	free, err := diskFree(dir)
+	if err != nil {
+		t.log.Warnw("cannot read free disk space, skipping preflight", "dir", dir, "err", err)
+		return nil
+	}
+	need := staged + t.opts.DiskReserve
+	if free < need {
+		return fmt.Errorf("%w in %s: %d MiB free, need %d MiB (%d MiB source + %d MiB reserve)",
+			ErrInsufficientDisk, dir, free>>20, need>>20, staged>>20, t.opts.DiskReserve>>20)
+	}
+	return nil
+}
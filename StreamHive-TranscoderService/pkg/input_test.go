@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckDisk(t *testing.T) {
+	dir := t.TempDir()
+	free, err := diskFree(dir)
+	if err != nil {
+		t.Skipf("free disk space not readable here: %v", err)
+	}
+	tests := []struct {
+		name    string
+		dir     string
+		staged  int64
+		reserve int64
+		wantErr bool
+	}{
+		{"fits", dir, 0, 0, false},
+		{"source too large", dir, free + 1<<30, 0, true},
+		{"reserve too large", dir, 0, free + 1<<30, true},
+		{"source plus reserve too large", dir, free / 2, free/2 + 1<<30, true},
+		{"unreadable dir skips the check", filepath.Join(dir, "missing"), math.MaxInt64 / 2, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &Transcoder{log: zap.NewNop().Sugar(), opts: Options{DiskReserve: tt.reserve}}
+			err := tr.checkDisk(tt.dir, tt.staged)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkDisk() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInsufficientDisk) {
+				t.Errorf("checkDisk() error = %v, want ErrInsufficientDisk", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,274 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrVideoDeleted is the cancellation cause of a job whose video was deleted.
+var ErrVideoDeleted = errors.New("video deleted")
+
+// ErrJobCancelled is the cancellation cause of a job cancelled through the
+// job API.
+var ErrJobCancelled = errors.New("cancelled by operator")
+
+// deletedTTL is how long a deleted upload is remembered, so a video.uploaded
+// still waiting in a retry queue is dropped instead of transcoded.
+const deletedTTL = 6 * time.Hour
+
+// JobRunning is the state of an in-flight job; finished jobs carry their
+// result (success, failed, cancelled).
+const JobRunning = "running"
+
+// JobInfo describes a running or finished job on the job API. Progress
+// fields are the last known values.
+type JobInfo struct {
+	UploadID   string             `json:"uploadId"`
+	UserID     string             `json:"userId"`
+	State      string             `json:"state"`
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt *time.Time         `json:"finishedAt,omitempty"`
+	Stage      string             `json:"stage,omitempty"`
+	Percent    float64            `json:"percent"`
+	Renditions map[string]float64 `json:"renditions,omitempty"` // percent per rendition
+	FPS        float64            `json:"fps,omitempty"`
+	Speed      float64            `json:"speed,omitempty"`
+	ETASeconds int                `json:"etaSeconds,omitempty"`
+	// LastError is the job's error once it failed, or the previous attempt's
+	// while a retry runs.
+	LastError  string `json:"lastError,omitempty"`
+	ErrorClass string `json:"errorClass,omitempty"`
+}
+
+// runningJob is one in-flight Handle call.
+type runningJob struct {
+	evt      *UploadEvent
+	started  time.Time
+	cancel   context.CancelCauseFunc
+	prog     *progressReporter // nil until the pipeline starts reporting
+	lastErr  string
+	errClass string
+}
+
+// jobRegistry tracks in-flight jobs by upload ID so they can be inspected and
+// cancelled, and keeps the last historySize finished ones.
+type jobRegistry struct {
+	mu          sync.Mutex
+	running     map[string][]*runningJob // a redelivery can overlap the original
+	deleted     map[string]time.Time
+	history     []JobInfo // oldest first
+	historySize int
+}
+
+func newJobRegistry(historySize int) *jobRegistry {
+	return &jobRegistry{running: map[string][]*runningJob{}, deleted: map[string]time.Time{}, historySize: historySize}
+}
+
+// start registers a job for evt and returns its context, already cancelled
+// when the upload was deleted. finish must be called when the job ends.
+func (r *jobRegistry) start(ctx context.Context, evt *UploadEvent) (context.Context, *runningJob) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	j := &runningJob{evt: evt, started: time.Now(), cancel: cancel}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.deleted[evt.UploadID]; ok {
+		cancel(ErrVideoDeleted)
+	}
+	// a retry shows why the previous attempt failed
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if h := r.history[i]; h.UploadID == evt.UploadID {
+			j.lastErr, j.errClass = h.LastError, h.ErrorClass
+			break
+		}
+	}
+	r.running[evt.UploadID] = append(r.running[evt.UploadID], j)
+	return ctx, j
+}
+
+// track attaches the job's progress reporter.
+func (r *jobRegistry) track(j *runningJob, prog *progressReporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	j.prog = prog
+}
+
+// finish unregisters j and moves it to the history with its result and
+// error.
+func (r *jobRegistry) finish(j *runningJob, result string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	id := j.evt.UploadID
+	jobs := r.running[id]
+	for i, other := range jobs {
+		if other == j {
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			break
+		}
+	}
+	if len(jobs) == 0 {
+		delete(r.running, id)
+	} else {
+		r.running[id] = jobs
+	}
+	j.cancel(nil)
+
+	info := j.infoLocked()
+	info.State = result
+	now := time.Now()
+	info.FinishedAt = &now
+	info.LastError, info.ErrorClass = "", ""
+	if err != nil {
+		info.LastError, info.ErrorClass = err.Error(), errorClass(err)
+	}
+	if r.historySize <= 0 {
+		return
+	}
+	r.history = append(r.history, info)
+	if n := len(r.history) - r.historySize; n > 0 {
+		r.history = append(r.history[:0:0], r.history[n:]...)
+	}
+}
+
+// infoLocked snapshots a running job; r.mu must be held.
+func (j *runningJob) infoLocked() JobInfo {
+	info := JobInfo{
+		UploadID:   j.evt.UploadID,
+		UserID:     j.evt.UserID,
+		State:      JobRunning,
+		StartedAt:  j.started,
+		LastError:  j.lastErr,
+		ErrorClass: j.errClass,
+	}
+	if j.prog != nil {
+		j.prog.snapshot(&info)
+	}
+	return info
+}
+
+// list returns the running jobs, oldest first, and the history, newest
+// first.
+func (r *jobRegistry) list() (running, recent []JobInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	running = []JobInfo{}
+	for _, jobs := range r.running {
+		for _, j := range jobs {
+			running = append(running, j.infoLocked())
+		}
+	}
+	sort.Slice(running, func(a, b int) bool { return running[a].StartedAt.Before(running[b].StartedAt) })
+	recent = make([]JobInfo, 0, len(r.history))
+	for i := len(r.history) - 1; i >= 0; i-- {
+		recent = append(recent, r.history[i])
+	}
+	return running, recent
+}
+
+// get returns the newest running job for uploadID, or else its most recent
+// finished one.
+func (r *jobRegistry) get(uploadID string) (JobInfo, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if jobs := r.running[uploadID]; len(jobs) > 0 {
+		return jobs[len(jobs)-1].infoLocked(), true
+	}
+	for i := len(r.history) - 1; i >= 0; i-- {
+		if r.history[i].UploadID == uploadID {
+			return r.history[i], true
+		}
+	}
+	return JobInfo{}, false
+}
+
+// cancel cancels the jobs running for uploadID with cause and returns how
+// many there were.
+func (r *jobRegistry) cancel(uploadID string, cause error) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, j := range r.running[uploadID] {
+		j.cancel(cause)
+	}
+	return len(r.running[uploadID])
+}
+
+// cancelDeleted cancels the jobs running for uploadID and remembers it as
+// deleted. It returns the number of jobs cancelled.
+func (r *jobRegistry) cancelDeleted(uploadID string) int {
+	r.mu.Lock()
+	now := time.Now()
+	for id, at := range r.deleted {
+		if now.Sub(at) > deletedTTL {
+			delete(r.deleted, id)
+		}
+	}
+	r.deleted[uploadID] = now
+	r.mu.Unlock()
+	return r.cancel(uploadID, ErrVideoDeleted)
+}
+
+// Jobs returns the running jobs, oldest first, and the recently finished
+// ones, newest first.
+func (t *Transcoder) Jobs() (running, recent []JobInfo) { return t.jobs.list() }
+
+// Job returns the running job for uploadID, or else its most recent
+// finished one.
+func (t *Transcoder) Job(uploadID string) (JobInfo, bool) { return t.jobs.get(uploadID) }
+
+// CancelJob cancels the job running for uploadID. The message is parked in
+// the DLQ, so the job can be replayed later, and transcode_failed is
+// published. It reports whether a job was running.
+func (t *Transcoder) CancelJob(uploadID string) bool {
+	n := t.jobs.cancel(uploadID, ErrJobCancelled)
+	if n > 0 {
+		t.log.Infow("cancelling job on request", "uploadId", uploadID, "jobs", n)
+	}
+	return n > 0
+}
+
+// DeletedEvent is the video.deleted event published by the catalog.
+type DeletedEvent struct {
+	UploadID string `json:"uploadId"`
+	UserID   string `json:"userId"`
+}
+
+// HandleDeleted cancels the job running for a deleted video. The job removes
+// whatever it already uploaded once it has stopped.
+func (t *Transcoder) HandleDeleted(body []byte) error {
+	var evt DeletedEvent
+	if err := json.Unmarshal(body, &evt); err != nil {
+		return fmt.Errorf("json: %w", err)
+	}
+	if evt.UploadID == "" {
+		return fmt.Errorf("missing uploadId")
+	}
+	if n := t.jobs.cancelDeleted(evt.UploadID); n > 0 {
+		t.log.Infow("video deleted, cancelling job", "uploadId", evt.UploadID, "jobs", n)
+	}
+	return nil
+}
+
+// discardOutput removes everything a cancelled job wrote for its video. The
+// catalog may have cleaned up before the job stopped uploading.
+func (t *Transcoder) discardOutput(ctx context.Context, evt *UploadEvent) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Minute)
+	defer cancel()
+	for _, prefix := range []string{
+		fmt.Sprintf("hls/%s/%s/", evt.UserID, evt.UploadID),
+		fmt.Sprintf("thumbnails/%s/%s/", evt.UserID, evt.UploadID),
+	} {
+		if err := t.store.DeleteBlobsWithPrefix(ctx, prefix); err != nil {
+			t.log.Warnw("could not remove output of cancelled job", "uploadId", evt.UploadID, "prefix", prefix, "err", err)
+		}
+	}
+	// content keys of an encrypted job, see keyring
+	keys := fmt.Sprintf("%s/%s/", evt.UserID, evt.UploadID)
+	if err := t.store.DeleteKeysWithPrefix(ctx, keys); err != nil {
+		t.log.Warnw("could not remove content keys of cancelled job", "uploadId", evt.UploadID, "prefix", keys, "err", err)
+	}
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestJobRegistryHistory(t *testing.T) {
+	tests := []struct {
+		name        string
+		historySize int
+		finished    int
+		want        []string // recent upload IDs, newest first
+	}{
+		{"under the limit", 3, 2, []string{"u1", "u0"}},
+		{"oldest dropped", 3, 5, []string{"u4", "u3", "u2"}},
+		{"disabled", 0, 2, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newJobRegistry(tt.historySize)
+			for i := 0; i < tt.finished; i++ {
+				_, j := r.start(context.Background(), &UploadEvent{UploadID: fmt.Sprintf("u%d", i)})
+				r.finish(j, "success", nil)
+			}
+			running, recent := r.list()
+			if len(running) != 0 {
+				t.Errorf("%d jobs still running", len(running))
+			}
+			got := []string{}
+			for _, j := range recent {
+				got = append(got, j.UploadID)
+				if j.FinishedAt == nil || j.State != "success" {
+					t.Errorf("history entry %+v not finished", j)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("recent = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobRegistryGet(t *testing.T) {
+	r := newJobRegistry(10)
+	evt := &UploadEvent{UploadID: "u1", UserID: "alice"}
+	_, first := r.start(context.Background(), evt)
+	r.finish(first, "failed", jobErr(ErrClassUpload, errors.New("503")))
+
+	// the retry is reported while it runs and shows the previous error
+	_, retry := r.start(context.Background(), evt)
+	got, ok := r.get("u1")
+	if !ok || got.State != JobRunning || got.LastError == "" || got.ErrorClass != ErrClassUpload {
+		t.Errorf("get() during retry = %+v, %v", got, ok)
+	}
+	r.finish(retry, "success", nil)
+	got, ok = r.get("u1")
+	if !ok || got.State != "success" || got.LastError != "" {
+		t.Errorf("get() after retry = %+v, %v", got, ok)
+	}
+	if _, ok := r.get("u2"); ok {
+		t.Error("get() found an unknown upload")
+	}
+}
+
+func TestJobRegistryCancel(t *testing.T) {
+	r := newJobRegistry(10)
+	evt := &UploadEvent{UploadID: "u1"}
+	ctx, j := r.start(context.Background(), evt)
+	redelivered, j2 := r.start(context.Background(), evt)
+
+	if n := r.cancel("u2", ErrJobCancelled); n != 0 {
+		t.Errorf("cancel(unknown) = %d, want 0", n)
+	}
+	if n := r.cancel("u1", ErrJobCancelled); n != 2 {
+		t.Errorf("cancel() = %d, want 2", n)
+	}
+	for _, c := range []context.Context{ctx, redelivered} {
+		if !errors.Is(context.Cause(c), ErrJobCancelled) {
+			t.Errorf("job context cause = %v, want ErrJobCancelled", context.Cause(c))
+		}
+	}
+	r.finish(j, "cancelled", ErrJobCancelled)
+	r.finish(j2, "cancelled", ErrJobCancelled)
+	if n := r.cancel("u1", ErrJobCancelled); n != 0 {
+		t.Errorf("cancel() after finish = %d, want 0", n)
+	}
+
+	// a deleted upload's later deliveries start cancelled
+	r.cancelDeleted("u3")
+	ctx, j = r.start(context.Background(), &UploadEvent{UploadID: "u3"})
+	if !errors.Is(context.Cause(ctx), ErrVideoDeleted) {
+		t.Errorf("job of a deleted upload has cause %v, want ErrVideoDeleted", context.Cause(ctx))
+	}
+	r.finish(j, "cancelled", context.Cause(ctx))
+}
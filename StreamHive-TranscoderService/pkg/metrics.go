@@ -0,0 +1,76 @@
+package pkg
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Job results on transcoder_jobs_total.
+const (
+	resultSuccess   = "success"
+	resultFailed    = "failed"
+	resultCancelled = "cancelled"
+)
+
+// jobBuckets span a few seconds to a few hours.
+var jobBuckets = prometheus.ExponentialBuckets(5, 2, 12)
+
+var (
+	jobsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "transcoder_jobs_total",
+		Help: "Upload events handled, by result (success, failed, cancelled).",
+	}, []string{"result"})
+	jobFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "transcoder_job_failures_total",
+		Help: "Failed job attempts by error class; retried attempts count each time.",
+	}, []string{"class"})
+	jobsInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "transcoder_jobs_in_flight",
+		Help: "Jobs currently being handled.",
+	})
+	jobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcoder_job_duration_seconds",
+		Help:    "Wall time of a job attempt, by result.",
+		Buckets: jobBuckets,
+	}, []string{"result"})
+	stageDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcoder_stage_duration_seconds",
+		Help:    "Wall time of each pipeline stage of a job.",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 16),
+	}, []string{"stage"})
+	renditionEncodeSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "transcoder_rendition_encode_seconds",
+		Help:    "ffmpeg time to encode one rendition; in single mode renditions share one process and time.",
+		Buckets: jobBuckets,
+	}, []string{"rendition", "codec", "mode"})
+	encodeSpeed = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "transcoder_encode_speed_ratio",
+		Help:    "Seconds of source encoded per second of wall time for a job's video ladder (1 = realtime).",
+		Buckets: []float64{0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 3, 5, 10, 20},
+	})
+	inputBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "transcoder_input_bytes_total",
+		Help: "Bytes of source video read, by size of the raw upload.",
+	})
+)
+
+// Pipeline stages on transcoder_stage_duration_seconds.
+const (
+	metricStageInput     = "input"
+	metricStageProbe     = "probe"
+	metricStageVideo     = "video"
+	metricStageAudio     = "audio"
+	metricStageSubtitles = "subtitles"
+	metricStageDASH      = "dash"
+	metricStagePosters   = "posters"
+	metricStageSprites   = "sprites"
+	metricStagePreview   = "preview"
+	metricStagePublish   = "publish"
+)
+
+// observeStage records the time since start for stage.
+func observeStage(stage string, start time.Time) {
+	stageDuration.WithLabelValues(stage).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+	"github.com/streamhive/transcoder/internal/queue"
+)
+
+// Options tunes the transcode pipeline; OptionsFromEnv supplies the defaults.
+type Options struct {
+	// SegmentType selects MPEG-TS or fMP4/CMAF HLS segments.
+	SegmentType ffmpeg.SegmentType
+	// DASH also writes an MPD manifest next to the HLS master playlist.
+	DASH bool
+	// Codecs lists the video codec families each ladder is encoded in.
+	Codecs []ffmpeg.VideoCodec
+	// EncodeMode is EncodeSingle, EncodeParallel or EncodeSequential.
+	EncodeMode string
+	// EncodeParallelism caps concurrent ffmpeg processes in parallel mode;
+	// 0 runs every rendition at once.
+	EncodeParallelism int
+	// ProgressInterval throttles video.transcoding.progress events.
+	ProgressInterval time.Duration
+	// PosterCandidates is how many poster frames are extracted and scored.
+	PosterCandidates int
+	// PreviewSeconds is the hover-preview clip length; 0 disables it.
+	PreviewSeconds int
+	// PreviewFormat is ffmpeg.PreviewMP4 or ffmpeg.PreviewWebP.
+	PreviewFormat string
+	// PreviewWidth is the hover-preview width in pixels.
+	PreviewWidth int
+	// Sprites configures the scrub-preview sprite sheets; an Interval of 0
+	// disables them.
+	Sprites ffmpeg.SpriteSpec
+	// Loudnorm normalizes every audio track to LoudnormTarget with a
+	// two-pass loudnorm before it is encoded.
+	Loudnorm       bool
+	LoudnormTarget ffmpeg.LoudnormTarget
+	// Encryption is EncryptNone, EncryptPrivate or EncryptAll.
+	Encryption string
+	// KeyRotationSegments switches to a new content key every N segments;
+	// 0 uses one key per video.
+	KeyRotationSegments int
+	// InputMode is InputDownload or InputStream.
+	InputMode string
+	// InputURLTTL is how long the presigned source URL of a streamed job
+	// stays valid; it must outlast the whole job.
+	InputURLTTL time.Duration
+	// WorkDir holds each job's staged source and encoded output.
+	WorkDir string
+	// DiskReserve is the free space in bytes a job needs in WorkDir on top
+	// of a staged source.
+	DiskReserve int64
+	// JobHistory is how many finished jobs the job API remembers.
+	JobHistory int
+}
+
+// OptionsFromEnv reads pipeline options from the environment:
+//
+//	HLS_SEGMENT_TYPE  mpegts (default) | fmp4
+//	DASH_ENABLED      true (default) | false
+//	VIDEO_CODECS      comma separated h264 (default), hevc, av1
+//	AV1_ENCODER       libsvtav1 (default) | libaom-av1
+//	ENCODE_MODE       single (default) | parallel | sequential
+//	ENCODE_PARALLELISM  max concurrent renditions in parallel mode (0 = all)
+//	PROGRESS_INTERVAL_SECONDS  min seconds between progress events (default 5)
+//	POSTER_CANDIDATES  poster frames to extract and score (default 5)
+//	PREVIEW_SECONDS   hover-preview clip length (default 4, 0 = off)
+//	PREVIEW_FORMAT    mp4 (default) | webp
+//	PREVIEW_WIDTH     hover-preview width in pixels (default 320)
+//	SPRITE_INTERVAL_SECONDS  seconds between scrub-preview frames (default 10, 0 = off)
+//	SPRITE_COLUMNS, SPRITE_ROWS  tiles per sprite sheet (default 5x5)
+//	SPRITE_WIDTH  tile width in pixels (default 160)
+//	LOUDNORM_ENABLED  true | false (default) two-pass EBU R128 normalization
+//	LOUDNORM_TARGET_LUFS  integrated loudness target (default -23)
+//	LOUDNORM_TRUE_PEAK_DBTP  true peak ceiling (default -1)
+//	LOUDNORM_LRA_LU  loudness range target (default 11)
+//	HLS_ENCRYPTION    none (default) | private | all  AES-128 segment encryption
+//	HLS_KEY_ROTATION_SEGMENTS  segments per content key (default 0 = one key)
+//	INPUT_MODE        download (default) | stream  ffmpeg reads a presigned URL
+//	INPUT_URL_TTL_MINUTES  validity of the presigned source URL (default 720)
+//	WORK_DIR          job working directory (default $TMPDIR)
+//	DISK_RESERVE_MB   free space required besides a staged source (default 2048)
+//	JOB_HISTORY_SIZE  finished jobs kept for the job API (default 100)
+//
+// HEVC and AV1 need fMP4 segments, so they require HLS_SEGMENT_TYPE=fmp4.
+func OptionsFromEnv() (Options, error) {
+	var o Options
+	st, err := ffmpeg.ParseSegmentType(os.Getenv("HLS_SEGMENT_TYPE"))
+	if err != nil {
+		return o, err
+	}
+	o.SegmentType = st
+	if o.DASH, err = envBool("DASH_ENABLED", true); err != nil {
+		return o, err
+	}
+	if o.Codecs, err = ffmpeg.ParseVideoCodecs(os.Getenv("VIDEO_CODECS"), os.Getenv("AV1_ENCODER")); err != nil {
+		return o, err
+	}
+	if o.EncodeMode, err = parseEncodeMode(os.Getenv("ENCODE_MODE")); err != nil {
+		return o, err
+	}
+	o.EncodeParallelism = queue.GetEnvInt("ENCODE_PARALLELISM", 0)
+	o.ProgressInterval = time.Duration(queue.GetEnvInt("PROGRESS_INTERVAL_SECONDS", 5)) * time.Second
+	o.PosterCandidates = queue.GetEnvInt("POSTER_CANDIDATES", 5)
+	o.PreviewSeconds = queue.GetEnvInt("PREVIEW_SECONDS", 4)
+	o.PreviewWidth = queue.GetEnvInt("PREVIEW_WIDTH", 320)
+	if o.PreviewFormat, err = ffmpeg.ParsePreviewFormat(os.Getenv("PREVIEW_FORMAT")); err != nil {
+		return o, err
+	}
+	o.Sprites = ffmpeg.SpriteSpec{
+		Interval: queue.GetEnvInt("SPRITE_INTERVAL_SECONDS", 10),
+		Columns:  queue.GetEnvInt("SPRITE_COLUMNS", 5),
+		Rows:     queue.GetEnvInt("SPRITE_ROWS", 5),
+		Width:    queue.GetEnvInt("SPRITE_WIDTH", 160),
+	}
+	if o.Sprites.Interval > 0 && (o.Sprites.Columns <= 0 || o.Sprites.Rows <= 0 || o.Sprites.Width <= 0) {
+		return o, fmt.Errorf("SPRITE_COLUMNS, SPRITE_ROWS and SPRITE_WIDTH must be positive")
+	}
+	if o.Loudnorm, err = envBool("LOUDNORM_ENABLED", false); err != nil {
+		return o, err
+	}
+	if o.LoudnormTarget.Integrated, err = envFloat("LOUDNORM_TARGET_LUFS", -23); err != nil {
+		return o, err
+	}
+	if o.LoudnormTarget.TruePeak, err = envFloat("LOUDNORM_TRUE_PEAK_DBTP", -1); err != nil {
+		return o, err
+	}
+	if o.LoudnormTarget.LRA, err = envFloat("LOUDNORM_LRA_LU", 11); err != nil {
+		return o, err
+	}
+	if o.Loudnorm {
+		if err := o.LoudnormTarget.Validate(); err != nil {
+			return o, err
+		}
+	}
+	if o.Encryption, err = parseEncryptionMode(os.Getenv("HLS_ENCRYPTION")); err != nil {
+		return o, err
+	}
+	if o.KeyRotationSegments = queue.GetEnvInt("HLS_KEY_ROTATION_SEGMENTS", 0); o.KeyRotationSegments < 0 {
+		return o, fmt.Errorf("HLS_KEY_ROTATION_SEGMENTS must not be negative")
+	}
+	if o.InputMode, err = parseInputMode(os.Getenv("INPUT_MODE")); err != nil {
+		return o, err
+	}
+	if o.InputURLTTL = time.Duration(queue.GetEnvInt("INPUT_URL_TTL_MINUTES", 720)) * time.Minute; o.InputURLTTL <= 0 {
+		return o, fmt.Errorf("INPUT_URL_TTL_MINUTES must be positive")
+	}
+	if o.WorkDir = os.Getenv("WORK_DIR"); o.WorkDir == "" {
+		o.WorkDir = os.TempDir()
+	}
+	if o.DiskReserve = int64(queue.GetEnvInt("DISK_RESERVE_MB", 2048)) << 20; o.DiskReserve < 0 {
+		return o, fmt.Errorf("DISK_RESERVE_MB must not be negative")
+	}
+	if o.JobHistory = queue.GetEnvInt("JOB_HISTORY_SIZE", 100); o.JobHistory < 0 {
+		return o, fmt.Errorf("JOB_HISTORY_SIZE must not be negative")
+	}
+	for _, c := range o.Codecs {
+		if c.Family != ffmpeg.FamilyH264 && o.SegmentType != ffmpeg.SegmentFMP4 {
+			return o, fmt.Errorf("VIDEO_CODECS %s requires HLS_SEGMENT_TYPE=fmp4", c.Family)
+		}
+	}
+	return o, nil
+}
+
+func envBool(name string, def bool) (bool, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("%s: %w", name, err)
+	}
+	return b, nil
+}
+
+func envFloat(name string, def float64) (float64, error) {
+	v := os.Getenv(name)
+	if v == "" {
+		return def, nil
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return def, fmt.Errorf("%s: %w", name, err)
+	}
+	return f, nil
+}
@@ -3,9 +3,9 @@ package pkg
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
@@ -33,14 +33,31 @@ type UploadEvent struct {
 	Resolutions   []string `json:"resolutions"`
 }
 
+// VideoMetadata mirrors the catalog's metadata block on video.transcoded events.
+type VideoMetadata struct {
+	Duration     float64 `json:"duration"`
+	FileSize     int64   `json:"fileSize"`
+	Width        int     `json:"width"`
+	Height       int     `json:"height"`
+	VideoCodec   string  `json:"videoCodec"`
+	VideoBitrate int     `json:"videoBitrate"`
+	AudioCodec   string  `json:"audioCodec"`
+	AudioBitrate int     `json:"audioBitrate"`
+	FrameRate    float64 `json:"frameRate"`
+}
+
 type Transcoder struct {
-	log *zap.SugaredLogger
-	s3  *storage.S3Client
-	pub *queue.Publisher
+	log      *zap.SugaredLogger
+	store    *storage.Client
+	pub      *queue.Publisher
+	progress *queue.Publisher // video.transcoding.progress; nil disables
+	failed   *queue.Publisher // video.transcode_failed; nil disables
+	opts     Options
+	jobs     *jobRegistry
 }
 
-func NewTranscoder(log *zap.SugaredLogger, s3c *storage.S3Client, pub *queue.Publisher) *Transcoder {
-	return &Transcoder{log: log, s3: s3c, pub: pub}
+func NewTranscoder(log *zap.SugaredLogger, store *storage.Client, pub, progress, failed *queue.Publisher, opts Options) *Transcoder {
+	return &Transcoder{log: log, store: store, pub: pub, progress: progress, failed: failed, opts: opts, jobs: newJobRegistry(opts.JobHistory)}
 }
 
 // buildAzureURL constructs the full Azure Blob Storage URL for a given blob path
@@ -85,22 +102,106 @@ func getSecret(filePath, envVar string) string {
 func (t *Transcoder) Handle(ctx context.Context, body []byte) error {
 	var evt UploadEvent
 	if err := json.Unmarshal(body, &evt); err != nil {
-		return fmt.Errorf("json: %w", err)
+		return queue.Permanent(fmt.Errorf("json: %w", err))
 	}
 	if evt.UploadID == "" || evt.UserID == "" || evt.RawVideoPath == "" {
-		return fmt.Errorf("missing required fields")
+		return queue.Permanent(fmt.Errorf("missing required fields"))
+	}
+
+	// A video deleted while it transcodes cancels the job; the message is
+	// settled without a retry or a transcode_failed event. A job cancelled
+	// through the job API is parked in the DLQ instead.
+	ctx, job := t.jobs.start(ctx, &evt)
+	jobsInFlight.Inc()
+	defer jobsInFlight.Dec()
+	start := time.Now()
+	prog := t.newProgressReporter(ctx, &evt)
+	t.jobs.track(job, prog)
+	err := t.process(ctx, &evt, prog)
+	result, recorded := resultSuccess, err
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, ErrVideoDeleted):
+		result, recorded, err = resultCancelled, cause, nil
+		t.log.Infow("job cancelled, video was deleted", "uploadId", evt.UploadID)
+		t.discardOutput(ctx, &evt)
+	case err != nil && errors.Is(cause, ErrJobCancelled):
+		result = resultCancelled
+		err = queue.Permanent(jobErr(ErrClassInternal, cause))
+		recorded = err
+		t.log.Infow("job cancelled on request", "uploadId", evt.UploadID)
+	case err != nil:
+		result = resultFailed
+		jobFailures.WithLabelValues(errorClass(err)).Inc()
+	}
+	t.jobs.finish(job, result, recorded)
+	jobsTotal.WithLabelValues(result).Inc()
+	jobDuration.WithLabelValues(result).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// process runs the transcode for one upload. Errors are tagged with the
+// pipeline step (JobError) for the video.transcode_failed event. Progress is
+// checkpointed in the job manifest, so a redelivered event only encodes the
+// renditions that are not in storage yet and a duplicate of a published job
+// is a no-op.
+func (t *Transcoder) process(ctx context.Context, evt *UploadEvent, prog *progressReporter) error {
+	segmentType := t.opts.SegmentType
+	if segmentType == "" {
+		segmentType = ffmpeg.SegmentTS
+	}
+	base := fmt.Sprintf("hls/%s/%s", evt.UserID, evt.UploadID)
+	var keys *keyring
+	if t.encrypts(evt) {
+		keys = newKeyring(t.store, evt)
+	}
+	job, err := t.loadJob(ctx, evt, base, segmentType, keys != nil)
+	if err != nil {
+		return err
+	}
+	if job.published() {
+		t.log.Infow("job already published, skipping duplicate", "uploadId", evt.UploadID)
+		return nil
 	}
 
-	work := filepath.Join(os.TempDir(), fmt.Sprintf("transcoder-%s", evt.UploadID))
+	work := filepath.Join(t.opts.WorkDir, fmt.Sprintf("transcoder-%s", evt.UploadID))
 	if err := os.MkdirAll(work, 0o755); err != nil {
 		return err
 	}
 	defer os.RemoveAll(work)
 
-	inputPath := filepath.Join(work, "input.mp4")
-	if err := t.s3.DownloadTo(ctx, evt.RawVideoPath, inputPath); err != nil {
-		return fmt.Errorf("download: %w", err)
+	prog.setStage(StageDownloading, nil)
+
+	stageStart := time.Now()
+	inputPath, inputSize, err := t.openInput(ctx, evt, work)
+	if err != nil {
+		return err
 	}
+	observeStage(metricStageInput, stageStart)
+	inputBytes.Add(float64(inputSize))
+
+	stageStart = time.Now()
+	probe, err := ffmpeg.Probe(ctx, inputPath)
+	if err != nil {
+		return jobErr(ErrClassProbe, err)
+	}
+	observeStage(metricStageProbe, stageStart)
+	srcW, srcH := probe.DisplaySize()
+	meta := VideoMetadata{
+		Duration:     probe.Duration,
+		FileSize:     probe.FileSize,
+		Width:        srcW,
+		Height:       srcH,
+		VideoCodec:   probe.VideoCodec,
+		VideoBitrate: probe.VideoBitrate,
+		AudioCodec:   probe.AudioCodec,
+		AudioBitrate: probe.AudioBitrate,
+		FrameRate:    probe.FrameRate,
+	}
+	if meta.FileSize == 0 {
+		meta.FileSize = inputSize
+	}
+	prog.duration = meta.Duration
+	t.log.Infow("probed input", "uploadId", evt.UploadID, "duration", meta.Duration, "width", meta.Width, "height", meta.Height, "vcodec", meta.VideoCodec, "acodec", meta.AudioCodec)
 
 	// Generate variants
 	outRoot := filepath.Join(work, "hls")
@@ -108,85 +209,442 @@ func (t *Transcoder) Handle(ctx context.Context, body []byte) error {
 		return err
 	}
 
-	ladder := evt.Resolutions
+	ladder := ffmpeg.SelectLadder(srcW, srcH, evt.Resolutions, t.opts.Codecs)
 	if len(ladder) == 0 {
-		ladder = []string{"1080p", "720p", "480p", "360p"}
+		return jobErr(ErrClassProbe, queue.Permanent(fmt.Errorf("no renditions for %dx%d source (requested %v)", srcW, srcH, evt.Resolutions)))
 	}
+	done, pending := job.split(ctx, ladder)
+	audio := ffmpeg.SelectAudio(probe.AudioTracks)
+	audioDone, audioPending := job.splitAudio(ctx, audio)
+	t.log.Infow("ladder selected", "uploadId", evt.UploadID, "source", fmt.Sprintf("%dx%d", srcW, srcH), "renditions", renditionNames(ladder), "audioTracks", len(audio), "resumed", renditionNames(variantRenditions(done)), "segmentType", segmentType, "encodeMode", t.opts.EncodeMode)
 
-	for _, res := range ladder {
-		resDir := filepath.Join(outRoot, res)
-		if err := os.MkdirAll(resDir, 0o755); err != nil {
-			return err
+	// Each rendition is uploaded and checkpointed as soon as it is encoded,
+	// so a crash later in the ladder keeps it.
+	encoded := []variant{}
+	if len(pending) > 0 {
+		prog.setStage(StageEncoding, pending)
+		stageStart = time.Now()
+		encoded, err = t.encodeLadder(ctx, inputPath, outRoot, pending, segmentType, prog, func(v variant) error {
+			if err := t.uploadRendition(ctx, keys, work, outRoot, base, v.Name); err != nil {
+				return jobErr(ErrClassUpload, err)
+			}
+			return jobErr(ErrClassUpload, job.markRendition(ctx, v))
+		})
+		if err != nil {
+			return jobErr(ErrClassFFmpeg, err)
+		}
+		observeStage(metricStageVideo, stageStart)
+		if elapsed := time.Since(stageStart).Seconds(); meta.Duration > 0 && elapsed > 0 {
+			encodeSpeed.Observe(meta.Duration / elapsed)
 		}
+	}
+	variants := mergeVariants(ladder, done, encoded)
 
-		cmd := ffmpeg.BuildHLSCommand(ctx, inputPath, resDir, res)
-		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
-		start := time.Now()
-		if err := cmd.Run(); err != nil {
-			return fmt.Errorf("ffmpeg %s: %w", res, err)
+	// Every source audio track is its own rendition, shared by all video
+	// renditions through the master's audio group.
+	audioEncoded := []audioVariant{}
+	if len(audioPending) > 0 {
+		prog.setStage(StageAudio, nil)
+		stageStart = time.Now()
+		if t.opts.Loudnorm {
+			audioPending = t.normalizeAudio(ctx, evt, inputPath, audioPending, job)
+		}
+		audioEncoded, err = t.encodeAudio(ctx, inputPath, outRoot, audioPending, segmentType, func(v audioVariant) error {
+			if err := t.uploadRendition(ctx, keys, work, outRoot, base, v.Name); err != nil {
+				return jobErr(ErrClassUpload, err)
+			}
+			return jobErr(ErrClassUpload, job.markAudio(ctx, v))
+		})
+		if err != nil {
+			return jobErr(ErrClassFFmpeg, err)
 		}
-		t.log.Infow("rendition done", "res", res, "ms", time.Since(start).Milliseconds())
+		observeStage(metricStageAudio, stageStart)
+	}
+	audioVariants := mergeAudio(audio, audioDone, audioEncoded)
+
+	// Text subtitle tracks become segmented WebVTT; like the poster, a
+	// failure only loses the captions
+	subs := ffmpeg.SelectSubtitles(probe.SubtitleTracks)
+	if skipped := len(probe.SubtitleTracks) - len(subs); skipped > 0 {
+		t.log.Infow("skipping bitmap subtitle tracks", "uploadId", evt.UploadID, "tracks", skipped)
+	}
+	if len(subs) > 0 && !job.m.Subtitles {
+		prog.setStage(StageSubtitles, nil)
+		stageStart = time.Now()
+		start := t.mediaStartTime(ctx, outRoot, variants, segmentType)
+		if err := t.writeSubtitles(ctx, evt, inputPath, outRoot, base, subs, meta.Duration, start); err != nil {
+			t.log.Warnw("subtitle extraction failed, publishing without captions", "uploadId", evt.UploadID, "err", err)
+			subs = nil
+		} else {
+			_ = job.update(ctx, func(m *jobManifest) { m.Subtitles = true })
+		}
+		observeStage(metricStageSubtitles, stageStart)
 	}
 
 	// Write master playlist to outRoot
 	masterPath := filepath.Join(outRoot, "master.m3u8")
-	if err := os.WriteFile(masterPath, []byte(buildMaster(evt.UserID, evt.UploadID, ladder)), 0o644); err != nil {
+	if err := os.WriteFile(masterPath, []byte(buildMaster(variants, audioVariants, subs, meta.FrameRate)), 0o644); err != nil {
 		return err
 	}
 
-	// Upload entire HLS folder (playlists + segments)
-	base := fmt.Sprintf("hls/%s/%s", evt.UserID, evt.UploadID)
-	if err := t.s3.UploadDir(ctx, outRoot, base); err != nil {
-		return fmt.Errorf("upload hls: %w", err)
+	// DASH is rebuilt whenever a rendition was (re)encoded; resumed
+	// renditions are fetched back from storage for it. DASH players cannot
+	// play AES-128 HLS segments and clear DASH output would defeat the
+	// encryption, so encrypted videos are HLS only.
+	dashWritten := job.m.DASH && len(pending) == 0 && len(audioPending) == 0
+	var dashRebuilt bool
+	if t.opts.DASH && keys != nil {
+		t.log.Infow("segments are encrypted, skipping DASH", "uploadId", evt.UploadID)
+	} else if t.opts.DASH && !dashWritten {
+		prog.setStage(StagePackaging, nil)
+		stageStart = time.Now()
+		resumed := renditionNames(variantRenditions(done))
+		for _, a := range audioDone {
+			resumed = append(resumed, a.Name)
+		}
+		err := t.restoreRenditions(ctx, base, outRoot, resumed, segmentType)
+		if err == nil {
+			err = t.writeDASH(ctx, outRoot, variants, audioVariants, segmentType, meta.FrameRate)
+		}
+		if err != nil {
+			t.log.Warnw("dash manifest failed, publishing HLS only", "uploadId", evt.UploadID, "err", err)
+		} else {
+			dashWritten, dashRebuilt = true, true
+		}
+		observeStage(metricStageDASH, stageStart)
+	}
+
+	prog.setStage(StageUploading, nil)
+
+	// Renditions are already uploaded; add the playlists and DASH output
+	if err := t.store.UploadFile(ctx, masterPath, base+"/master.m3u8", "application/vnd.apple.mpegurl"); err != nil {
+		return jobErr(ErrClassUpload, fmt.Errorf("upload master: %w", err))
 	}
+	if dashRebuilt {
+		if err := t.uploadDASH(ctx, outRoot, base, segmentType); err != nil {
+			return jobErr(ErrClassUpload, err)
+		}
+		if err := job.update(ctx, func(m *jobManifest) { m.DASH = true }); err != nil {
+			return jobErr(ErrClassUpload, err)
+		}
+	}
+
+	prog.setStage(StageThumbnails, nil)
 
-	// Thumbnail
-	thumbPath := filepath.Join(work, "thumb.jpg")
-	thumbCmd := exec.CommandContext(ctx, "ffmpeg", "-y", "-ss", "1", "-i", inputPath, "-frames:v", "1", thumbPath)
+	// Poster candidates; a failure only loses the thumbnail
+	posters := job.m.PosterCandidates
+	if len(posters) == 0 {
+		stageStart = time.Now()
+		if urls, err := t.writePosters(ctx, evt, inputPath, work, meta.Duration); err != nil {
+			t.log.Warnw("poster extraction failed, publishing without thumbnail", "uploadId", evt.UploadID, "err", err)
+		} else {
+			posters = urls
+			_ = job.update(ctx, func(m *jobManifest) { m.PosterCandidates = urls })
+		}
+		observeStage(metricStagePosters, stageStart)
+	}
 	var thumbnailURL string
-	if err := thumbCmd.Run(); err == nil {
-		thumbBlobPath := fmt.Sprintf("thumbnails/%s/%s.jpg", evt.UserID, evt.UploadID)
-		if err := t.s3.UploadFile(ctx, thumbPath, thumbBlobPath, "image/jpeg"); err == nil {
-			thumbnailURL = t.buildAzureURL(thumbBlobPath)
+	if len(posters) > 0 {
+		thumbnailURL = posters[0]
+	}
+
+	// Scrub-preview sprites; like the poster, a failure only loses the feature
+	spriteTrackURL := job.m.SpriteTrack
+	if spriteTrackURL == "" && t.opts.Sprites.Interval > 0 && meta.Duration > 0 {
+		stageStart = time.Now()
+		if u, err := t.writeSprites(ctx, evt, inputPath, work, meta.Duration, srcW, srcH); err != nil {
+			t.log.Warnw("sprite generation failed, publishing without thumbnails track", "uploadId", evt.UploadID, "err", err)
+		} else {
+			spriteTrackURL = u
+			_ = job.update(ctx, func(m *jobManifest) { m.SpriteTrack = u })
+		}
+		observeStage(metricStageSprites, stageStart)
+	}
+
+	// Hover-preview clip for the browse grid; optional like the sprites
+	previewURL := job.m.PreviewURL
+	if previewURL == "" && t.opts.PreviewSeconds > 0 && meta.Duration > 0 {
+		stageStart = time.Now()
+		if u, err := t.writePreview(ctx, evt, inputPath, work, meta.Duration); err != nil {
+			t.log.Warnw("preview generation failed, publishing without preview", "uploadId", evt.UploadID, "err", err)
+		} else {
+			previewURL = u
+			_ = job.update(ctx, func(m *jobManifest) { m.PreviewURL = u })
+		}
+		observeStage(metricStagePreview, stageStart)
+	}
+
+	hlsInfo := map[string]any{
+		"masterUrl":   t.buildAzureURL(fmt.Sprintf("%s/%s", base, "master.m3u8")),
+		"segmentType": segmentType,
+	}
+	if keys != nil {
+		hlsInfo["encryption"] = "AES-128"
+	}
+	manifests := map[string]any{"hls": hlsInfo}
+	if dashWritten {
+		manifests["dash"] = map[string]any{
+			"mpdUrl": t.buildAzureURL(fmt.Sprintf("%s/%s", base, ffmpeg.DASHManifestName)),
 		}
 	}
 
 	// Publish transcoded with rich metadata so catalog can fill missing fields
 	out := map[string]any{
-		"uploadId":         evt.UploadID,
-		"userId":           evt.UserID,
-		"title":            evt.Title,
-		"description":      evt.Description,
-		"tags":             evt.Tags,
-		"category":         evt.Category,
-		"isPrivate":        evt.IsPrivate,
-		"originalFilename": evt.OriginalName,
-		"rawVideoPath":     evt.RawVideoPath,
-		"hls": map[string]any{
-			"masterUrl": t.buildAzureURL(fmt.Sprintf("%s/%s", base, "master.m3u8")),
-		},
-		"thumbnailUrl": thumbnailURL,
-		"ready":        true,
-	}
-	return t.pub.PublishJSON(ctx, out)
-}
-
-func buildMaster(userId, uploadId string, ladder []string) string {
-	// Bandwidth should match actual encoded bitrates (video + audio)
-	bw := map[string]int{
-		"1080p": 5192000, // 5000k video + 192k audio
-		"720p":  2928000, // 2800k video + 128k audio
-		"480p":  1496000, // 1400k video + 96k audio
-		"360p":  864000,  // 800k video + 64k audio
-	}
-	resMap := map[string]string{"1080p": "1920x1080", "720p": "1280x720", "480p": "854x480", "360p": "640x360"}
-	if len(ladder) == 0 {
-		ladder = []string{"1080p", "720p", "480p", "360p"}
+		"uploadId":          evt.UploadID,
+		"userId":            evt.UserID,
+		"title":             evt.Title,
+		"description":       evt.Description,
+		"tags":              evt.Tags,
+		"category":          evt.Category,
+		"isPrivate":         evt.IsPrivate,
+		"originalFilename":  evt.OriginalName,
+		"rawVideoPath":      evt.RawVideoPath,
+		"hls":               hlsInfo, // legacy consumers; same as manifests.hls
+		"manifests":         manifests,
+		"thumbnailUrl":      thumbnailURL,
+		"posterCandidates":  posters,
+		"thumbnailTrackUrl": spriteTrackURL,
+		"previewUrl":        previewURL,
+		"captions":          t.captions(base, subs),
+		"loudness":          t.loudnessReport(job, audio),
+		"metadata":          meta,
+		"ready":             true,
 	}
-	s := "#EXTM3U\n"
+	stageStart = time.Now()
+	if err := t.pub.PublishJSON(ctx, out); err != nil {
+		return err
+	}
+	observeStage(metricStagePublish, stageStart)
+	// A failure here only risks one duplicate video.transcoded on redelivery,
+	// which the catalog applies idempotently.
+	now := time.Now().UTC()
+	if err := job.update(ctx, func(m *jobManifest) { m.PublishedAt = &now }); err != nil {
+		t.log.Warnw("could not mark job published", "uploadId", evt.UploadID, "err", err)
+	}
+	return nil
+}
+
+// mergeVariants returns the resumed and newly encoded variants in ladder order.
+func mergeVariants(ladder []ffmpeg.Rendition, lists ...[]variant) []variant {
+	byName := map[string]variant{}
+	for _, l := range lists {
+		for _, v := range l {
+			byName[v.Name] = v
+		}
+	}
+	out := make([]variant, 0, len(ladder))
 	for _, r := range ladder {
-		s += fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d,RESOLUTION=%s\n", bw[r], resMap[r])
-		s += fmt.Sprintf("%s/index.m3u8\n", r)
+		if v, ok := byName[r.Name]; ok {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func variantRenditions(vs []variant) []ffmpeg.Rendition {
+	out := make([]ffmpeg.Rendition, len(vs))
+	for i, v := range vs {
+		out[i] = v.Rendition
+	}
+	return out
+}
+
+// restoreRenditions fetches what writeDASH reads for the named renditions
+// encoded by an earlier attempt: the media playlist for fMP4, every segment
+// for MPEG-TS.
+func (t *Transcoder) restoreRenditions(ctx context.Context, base, outRoot string, names []string, seg ffmpeg.SegmentType) error {
+	for _, name := range names {
+		dir := filepath.Join(outRoot, name)
+		if seg != ffmpeg.SegmentFMP4 {
+			if err := t.store.DownloadPrefix(ctx, base+"/"+name, dir); err != nil {
+				return err
+			}
+			continue
+		}
+		data, err := t.store.ReadBlob(ctx, fmt.Sprintf("%s/%s/index.m3u8", base, name))
+		if err != nil {
+			return fmt.Errorf("restore %s playlist: %w", name, err)
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, "index.m3u8"), data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// uploadDASH uploads manifest.mpd and, for MPEG-TS output, the remuxed
+// segments in dash/.
+func (t *Transcoder) uploadDASH(ctx context.Context, outRoot, base string, seg ffmpeg.SegmentType) error {
+	if seg != ffmpeg.SegmentFMP4 {
+		if err := t.store.UploadDir(ctx, filepath.Join(outRoot, ffmpeg.DASHSegmentDir), base+"/"+ffmpeg.DASHSegmentDir); err != nil {
+			return fmt.Errorf("upload dash segments: %w", err)
+		}
+	}
+	if err := t.store.UploadFile(ctx, filepath.Join(outRoot, ffmpeg.DASHManifestName), base+"/"+ffmpeg.DASHManifestName, "application/dash+xml"); err != nil {
+		return fmt.Errorf("upload dash manifest: %w", err)
+	}
+	return nil
+}
+
+// variant is an encoded rendition plus what was measured from its output.
+type variant struct {
+	ffmpeg.Rendition
+	codecs       string // RFC 6381, video only; empty if unknown
+	bandwidth    int    // peak bps
+	avgBandwidth int    // average bps
+}
+
+// measureVariant probes the encoded rendition in dir for its codec string and
+// actual bitrates. Failures fall back to the nominal ladder values.
+func (t *Transcoder) measureVariant(ctx context.Context, dir string, r ffmpeg.Rendition) variant {
+	v := variant{Rendition: r, bandwidth: r.PeakBandwidth(), avgBandwidth: r.Bandwidth()}
+	if peak, avg, err := ffmpeg.MeasureBandwidth(dir); err != nil {
+		t.log.Warnw("measure bandwidth failed, using nominal", "res", r.Name, "err", err)
+	} else {
+		v.bandwidth, v.avgBandwidth = peak, avg
+	}
+	vcodec, acodec, err := ffmpeg.ProbeCodecs(ctx, filepath.Join(dir, "index.m3u8"))
+	if err != nil {
+		t.log.Warnw("probe codecs failed, omitting CODECS", "res", r.Name, "err", err)
+		return v
+	}
+	if vcodec == "" {
+		t.log.Warnw("video level unknown, omitting CODECS", "res", r.Name)
+		return v
+	}
+	v.codecs = vcodec
+	if acodec != "" {
+		v.codecs += "," + acodec
+	}
+	return v
+}
+
+// buildMaster lists only the renditions that were actually produced, with
+// CODECS so players can pick the most efficient codec they support. Audio
+// renditions form one EXT-X-MEDIA group that every variant references; a
+// variant's BANDWIDTH includes the largest audio rendition, as the spec asks.
+// Caption tracks form a SUBTITLES group the same way.
+func buildMaster(variants []variant, audio []audioVariant, subs []ffmpeg.SubtitleRendition, frameRate float64) string {
+	s := "#EXTM3U\n"
+	s += "#EXT-X-INDEPENDENT-SEGMENTS\n"
+	var audioPeak, audioAvg int
+	var audioCodecs string
+	for _, a := range audio {
+		attrs := fmt.Sprintf("TYPE=AUDIO,GROUP-ID=\"%s\",NAME=\"%s\"", ffmpeg.AudioGroupID, a.DisplayName())
+		if tag := ffmpeg.LanguageTag(a.Track.Language); tag != "" {
+			attrs += fmt.Sprintf(",LANGUAGE=\"%s\"", tag)
+		}
+		attrs += fmt.Sprintf(",DEFAULT=%s,AUTOSELECT=YES,CHANNELS=\"%d\",URI=\"%s/index.m3u8\"", yesNo(a.Default), a.Channels, a.Name)
+		s += "#EXT-X-MEDIA:" + attrs + "\n"
+		audioPeak = max(audioPeak, a.bandwidth)
+		audioAvg = max(audioAvg, a.avgBandwidth)
+		if audioCodecs == "" {
+			audioCodecs = a.codecs
+		}
+	}
+	for _, sub := range subs {
+		attrs := fmt.Sprintf("TYPE=SUBTITLES,GROUP-ID=\"%s\",NAME=\"%s\"", ffmpeg.SubtitleGroupID, sub.DisplayName())
+		if tag := ffmpeg.LanguageTag(sub.Track.Language); tag != "" {
+			attrs += fmt.Sprintf(",LANGUAGE=\"%s\"", tag)
+		}
+		attrs += fmt.Sprintf(",DEFAULT=%s,AUTOSELECT=YES,FORCED=%s", yesNo(sub.Track.Default), yesNo(sub.Track.Forced))
+		attrs += fmt.Sprintf(",URI=\"%s/%s/index.m3u8\"", ffmpeg.SubtitleDir, sub.Name)
+		s += "#EXT-X-MEDIA:" + attrs + "\n"
+	}
+	for _, v := range variants {
+		attrs := fmt.Sprintf("BANDWIDTH=%d,AVERAGE-BANDWIDTH=%d,RESOLUTION=%s", v.bandwidth+audioPeak, v.avgBandwidth+audioAvg, v.Resolution())
+		if v.codecs != "" {
+			codecs := v.codecs
+			if audioCodecs != "" {
+				codecs += "," + audioCodecs
+			}
+			attrs += fmt.Sprintf(",CODECS=\"%s\"", codecs)
+		}
+		if frameRate > 0 {
+			attrs += fmt.Sprintf(",FRAME-RATE=%.3f", frameRate)
+		}
+		if len(audio) > 0 {
+			attrs += fmt.Sprintf(",AUDIO=\"%s\"", ffmpeg.AudioGroupID)
+		}
+		if len(subs) > 0 {
+			attrs += fmt.Sprintf(",SUBTITLES=\"%s\"", ffmpeg.SubtitleGroupID)
+		}
+		s += "#EXT-X-STREAM-INF:" + attrs + "\n"
+		s += fmt.Sprintf("%s/index.m3u8\n", v.Name)
 	}
 	return s
 }
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
+
+// writeDASH writes manifest.mpd into outRoot. fMP4 HLS output is referenced
+// in place; MPEG-TS output is remuxed (no re-encode) into outRoot/dash.
+func (t *Transcoder) writeDASH(ctx context.Context, outRoot string, variants []variant, audio []audioVariant, seg ffmpeg.SegmentType, frameRate float64) error {
+	if seg != ffmpeg.SegmentFMP4 {
+		if err := os.MkdirAll(filepath.Join(outRoot, ffmpeg.DASHSegmentDir), 0o755); err != nil {
+			return err
+		}
+		ladder := make([]ffmpeg.Rendition, len(variants))
+		for i, v := range variants {
+			ladder[i] = v.Rendition
+		}
+		tracks := make([]ffmpeg.AudioRendition, len(audio))
+		for i, a := range audio {
+			tracks[i] = a.AudioRendition
+		}
+		cmd := ffmpeg.BuildDASHRemuxCommand(ctx, outRoot, ladder, tracks)
+		cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("ffmpeg dash remux: %w", err)
+		}
+		return nil
+	}
+
+	reps := make([]ffmpeg.DASHRepresentation, 0, len(variants))
+	for _, v := range variants {
+		if v.codecs == "" {
+			return fmt.Errorf("codecs unknown for %s", v.Name)
+		}
+		playlist, err := os.ReadFile(filepath.Join(outRoot, v.Name, "index.m3u8"))
+		if err != nil {
+			return err
+		}
+		reps = append(reps, ffmpeg.DASHRepresentation{
+			Rendition:        v.Rendition,
+			Codecs:           v.codecs,
+			Bandwidth:        v.avgBandwidth,
+			SegmentDurations: ffmpeg.SegmentDurations(ffmpeg.ParseMediaPlaylist(playlist)),
+		})
+	}
+	audioReps := make([]ffmpeg.DASHAudioRepresentation, 0, len(audio))
+	for _, a := range audio {
+		playlist, err := os.ReadFile(filepath.Join(outRoot, a.Name, "index.m3u8"))
+		if err != nil {
+			return err
+		}
+		audioReps = append(audioReps, ffmpeg.DASHAudioRepresentation{
+			Rendition:        a.AudioRendition,
+			Codecs:           a.codecs,
+			Bandwidth:        a.avgBandwidth,
+			SegmentDurations: ffmpeg.SegmentDurations(ffmpeg.ParseMediaPlaylist(playlist)),
+		})
+	}
+	mpd := ffmpeg.BuildCMAFManifest(reps, audioReps, frameRate)
+	return os.WriteFile(filepath.Join(outRoot, ffmpeg.DASHManifestName), []byte(mpd), 0o644)
+}
+
+func renditionNames(ladder []ffmpeg.Rendition) []string {
+	names := make([]string, 0, len(ladder))
+	for _, r := range ladder {
+		names = append(names, r.Name)
+	}
+	return names
+}
@@ -0,0 +1,146 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// posterCandidate is an extracted frame with its image quality score.
+type posterCandidate struct {
+	path       string
+	brightness float64 // mean luma, 0..1
+	contrast   float64 // luma standard deviation, 0..0.5
+	sharpness  float64 // variance of the Laplacian over 0..255 luma
+	score      float64
+}
+
+// writePosters extracts poster candidates at scene changes, topping up with
+// evenly spaced frames for sources with few cuts, and uploads them best
+// first as thumbnails/<user>/<upload>/poster_NN.jpg. It returns their URLs;
+// the first is the default poster.
+func (t *Transcoder) writePosters(ctx context.Context, evt *UploadEvent, inputPath, work string, duration float64) ([]string, error) {
+	n := max(t.opts.PosterCandidates, 1)
+	dir := filepath.Join(work, "posters")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	cmd := ffmpeg.BuildSceneCandidatesCommand(ctx, inputPath, dir, n)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		// not fatal: the evenly spaced frames below still give a poster
+		t.log.Warnw("scene detection failed", "uploadId", evt.UploadID, "err", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "scene_*.jpg"))
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; len(paths) < n && i < n; i++ {
+		if duration <= 0 && i > 0 {
+			break // unknown length: only the first frame is safe
+		}
+		offset := duration * float64(i+1) / float64(n+1)
+		p := filepath.Join(dir, fmt.Sprintf("frame_%02d.jpg", i+1))
+		if err := ffmpeg.BuildFrameGrabCommand(ctx, inputPath, p, offset).Run(); err != nil {
+			continue
+		}
+		paths = append(paths, p)
+	}
+
+	var cands []posterCandidate
+	for _, p := range paths {
+		c, err := scorePoster(p)
+		if err != nil {
+			t.log.Warnw("cannot score poster candidate", "uploadId", evt.UploadID, "file", filepath.Base(p), "err", err)
+			continue
+		}
+		cands = append(cands, c)
+	}
+	if len(cands) == 0 {
+		return nil, fmt.Errorf("no poster candidates extracted")
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].score > cands[j].score })
+
+	urls := make([]string, 0, len(cands))
+	for i, c := range cands {
+		blobPath := fmt.Sprintf("thumbnails/%s/%s/poster_%02d.jpg", evt.UserID, evt.UploadID, i+1)
+		if err := t.store.UploadFile(ctx, c.path, blobPath, "image/jpeg"); err != nil {
+			return nil, fmt.Errorf("upload %s: %w", blobPath, err)
+		}
+		urls = append(urls, t.buildAzureURL(blobPath))
+	}
+	best := cands[0]
+	t.log.Infow("poster selected", "uploadId", evt.UploadID, "candidates", len(cands), "file", filepath.Base(best.path),
+		"score", round2(best.score), "brightness", round2(best.brightness), "contrast", round2(best.contrast), "sharpness", round2(best.sharpness))
+	return urls, nil
+}
+
+// scorePoster rates a frame for use as a poster. Mid exposure, contrast and
+// sharpness each add to the score; near-black, blown-out and flat frames
+// (fades, title cards) are heavily penalised.
+func scorePoster(path string) (posterCandidate, error) {
+	c := posterCandidate{path: path}
+	f, err := os.Open(path)
+	if err != nil {
+		return c, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return c, err
+	}
+
+	// sample a grid of at most ~320 columns; plenty for global statistics
+	b := img.Bounds()
+	step := max(b.Dx()/320, 1)
+	w, h := b.Dx()/step, b.Dy()/step
+	if w < 3 || h < 3 {
+		return c, fmt.Errorf("frame too small: %dx%d", b.Dx(), b.Dy())
+	}
+	luma := make([]float64, w*h)
+	var sum, sumSq float64
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			l := float64(color.GrayModel.Convert(img.At(b.Min.X+x*step, b.Min.Y+y*step)).(color.Gray).Y)
+			luma[y*w+x] = l
+			sum += l
+			sumSq += l * l
+		}
+	}
+	px := float64(w * h)
+	mean := sum / px
+	c.brightness = mean / 255
+	c.contrast = math.Sqrt(math.Max(sumSq/px-mean*mean, 0)) / 255
+
+	var lapSum, lapSq float64
+	for y := 1; y < h-1; y++ {
+		for x := 1; x < w-1; x++ {
+			i := y*w + x
+			lap := luma[i-w] + luma[i+w] + luma[i-1] + luma[i+1] - 4*luma[i]
+			lapSum += lap
+			lapSq += lap * lap
+		}
+	}
+	inner := float64((w - 2) * (h - 2))
+	lapMean := lapSum / inner
+	c.sharpness = lapSq/inner - lapMean*lapMean
+
+	exposure := 1 - math.Abs(c.brightness-0.5)*2
+	contrast := math.Min(c.contrast/0.25, 1)
+	sharpness := c.sharpness / (c.sharpness + 100)
+	c.score = 0.3*exposure + 0.3*contrast + 0.4*sharpness
+	if c.brightness < 0.08 || c.brightness > 0.95 || c.contrast < 0.03 {
+		c.score *= 0.1
+	}
+	return c, nil
+}
+
+func round2(f float64) float64 { return math.Round(f*100) / 100 }
@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScorePoster(t *testing.T) {
+	solid := func(l uint8) func(x, y int) uint8 { return func(int, int) uint8 { return l } }
+	tests := []struct {
+		name          string
+		w, h          int
+		luma          func(x, y int) uint8
+		wantErr       bool
+		wantPenalised bool
+	}{
+		{name: "detailed mid exposure", w: 64, h: 36, luma: func(x, y int) uint8 { return uint8(64 + 128*((x/2+y/2)%2)) }},
+		{name: "smooth gradient", w: 64, h: 36, luma: func(x, y int) uint8 { return uint8(60 + 2*x) }},
+		{name: "black frame", w: 64, h: 36, luma: solid(5), wantPenalised: true},
+		{name: "blown out", w: 64, h: 36, luma: solid(250), wantPenalised: true},
+		{name: "flat title card", w: 64, h: 36, luma: solid(128), wantPenalised: true},
+		{name: "too small", w: 2, h: 2, luma: solid(128), wantErr: true},
+	}
+	scores := map[string]float64{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := image.NewGray(image.Rect(0, 0, tt.w, tt.h))
+			for y := 0; y < tt.h; y++ {
+				for x := 0; x < tt.w; x++ {
+					img.SetGray(x, y, color.Gray{Y: tt.luma(x, y)})
+				}
+			}
+			c, err := scorePoster(writeJPEG(t, img))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("scorePoster() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if penalised := c.score < 0.1; penalised != tt.wantPenalised {
+				t.Errorf("score = %.3f (brightness %.2f, contrast %.2f, sharpness %.1f), penalised %v, want %v",
+					c.score, c.brightness, c.contrast, c.sharpness, penalised, tt.wantPenalised)
+			}
+			scores[tt.name] = c.score
+		})
+	}
+	if scores["detailed mid exposure"] <= scores["smooth gradient"] {
+		t.Errorf("detailed frame scored %.3f, not above the smooth gradient's %.3f", scores["detailed mid exposure"], scores["smooth gradient"])
+	}
+}
+
+func TestScorePosterNotAnImage(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "poster.jpg")
+	if err := os.WriteFile(p, []byte("not a jpeg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := scorePoster(p); err == nil {
+		t.Fatal("scorePoster() succeeded on a non-image")
+	}
+}
+
+func writeJPEG(t *testing.T, img image.Image) string {
+	t.Helper()
+	p := filepath.Join(t.TempDir(), "poster.jpg")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := jpeg.Encode(f, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// writePreview renders the silent hover-preview clip from the most active
+// section of the source and uploads it as thumbnails/<user>/<upload>/preview.<ext>.
+// It returns the clip URL.
+func (t *Transcoder) writePreview(ctx context.Context, evt *UploadEvent, inputPath, work string, duration float64) (string, error) {
+	seconds := min(float64(t.opts.PreviewSeconds), duration)
+	samples, err := ffmpeg.MeasureActivity(ctx, inputPath)
+	if err != nil {
+		// the fallback window is still a usable preview
+		t.log.Warnw("activity measurement failed", "uploadId", evt.UploadID, "err", err)
+	}
+	start := ffmpeg.MostActiveWindow(samples, seconds, duration)
+
+	name := "preview." + t.opts.PreviewFormat
+	out := filepath.Join(work, name)
+	cmd := ffmpeg.BuildPreviewCommand(ctx, inputPath, out, start, seconds, t.opts.PreviewWidth, t.opts.PreviewFormat)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffmpeg preview: %w", err)
+	}
+	contentType := "video/mp4"
+	if t.opts.PreviewFormat == ffmpeg.PreviewWebP {
+		contentType = "image/webp"
+	}
+	blobPath := fmt.Sprintf("thumbnails/%s/%s/%s", evt.UserID, evt.UploadID, name)
+	if err := t.store.UploadFile(ctx, out, blobPath, contentType); err != nil {
+		return "", fmt.Errorf("upload %s: %w", name, err)
+	}
+	t.log.Infow("preview written", "uploadId", evt.UploadID, "start", start, "seconds", seconds, "format", t.opts.PreviewFormat)
+	return t.buildAzureURL(blobPath), nil
+}
@@ -0,0 +1,184 @@
+package pkg
+
+import (
+	"context"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// Pipeline stages reported on video.transcoding.progress events, in order.
+const (
+	StageDownloading = "downloading"
+	StageEncoding    = "encoding"   // video ladder
+	StageAudio       = "audio"      // audio renditions, loudnorm included
+	StageSubtitles   = "subtitles"  // WebVTT caption tracks
+	StagePackaging   = "packaging"  // DASH manifest
+	StageUploading   = "uploading"  // playlists and manifests
+	StageThumbnails  = "thumbnails" // posters, sprites, hover preview
+)
+
+// encodingShare is the part of the job-wide percentage the video ladder
+// fills; the later stages report fixed values above it, so a job never shows
+// 100% before it publishes.
+const encodingShare = 85
+
+var stagePercent = map[string]float64{
+	StageAudio:      85,
+	StageSubtitles:  90,
+	StagePackaging:  92,
+	StageUploading:  95,
+	StageThumbnails: 97,
+}
+
+// progressReporter turns ffmpeg progress from one or more encodes into
+// throttled video.transcoding.progress events for a single upload. Stage
+// changes are published immediately; encode updates at most once per interval.
+type progressReporter struct {
+	t        *Transcoder
+	ctx      context.Context
+	evt      *UploadEvent
+	duration float64 // source seconds, 0 when unknown
+	interval time.Duration
+
+	mu        sync.Mutex
+	stage     string
+	fractions map[string]float64 // per rendition, 0..1
+	current   []string
+	fps       float64
+	speed     float64
+	started   time.Time
+	last      time.Time
+}
+
+func (t *Transcoder) newProgressReporter(ctx context.Context, evt *UploadEvent) *progressReporter {
+	return &progressReporter{t: t, ctx: ctx, evt: evt, interval: t.opts.ProgressInterval, fractions: map[string]float64{}}
+}
+
+// setStage publishes a stage change. Entering StageEncoding resets the
+// per-rendition state for ladder.
+func (p *progressReporter) setStage(stage string, ladder []ffmpeg.Rendition) {
+	p.mu.Lock()
+	p.stage = stage
+	if stage == StageEncoding {
+		p.fractions = make(map[string]float64, len(ladder))
+		for _, r := range ladder {
+			p.fractions[r.Name] = 0
+		}
+		p.started = time.Now()
+	}
+	msg := p.messageLocked()
+	p.last = time.Now()
+	p.mu.Unlock()
+	p.publish(msg)
+}
+
+// update records ffmpeg progress for the renditions one process is encoding.
+// A single-decode encode passes the whole ladder.
+func (p *progressReporter) update(names []string, pr ffmpeg.Progress) {
+	if p.duration <= 0 {
+		return
+	}
+	f := pr.OutTime.Seconds() / p.duration
+	if pr.Done || f > 1 {
+		f = 1
+	}
+	p.mu.Lock()
+	for _, n := range names {
+		p.fractions[n] = f
+	}
+	p.current, p.fps, p.speed = names, pr.FPS, pr.Speed
+	if time.Since(p.last) < p.interval {
+		p.mu.Unlock()
+		return
+	}
+	msg := p.messageLocked()
+	p.last = time.Now()
+	p.mu.Unlock()
+	p.publish(msg)
+}
+
+func (p *progressReporter) messageLocked() map[string]any {
+	msg := map[string]any{
+		"uploadId":  p.evt.UploadID,
+		"userId":    p.evt.UserID,
+		"stage":     p.stage,
+		"timestamp": time.Now().UTC().Format(time.RFC3339),
+	}
+	msg["percent"] = math.Round(p.percentLocked()*10) / 10
+	if p.stage == StageEncoding {
+		msg["rendition"] = strings.Join(p.current, ",")
+		msg["fps"] = math.Round(p.fps*10) / 10
+		msg["speed"] = math.Round(p.speed*100) / 100
+		if eta, ok := p.etaLocked(); ok {
+			msg["etaSeconds"] = eta
+		}
+	}
+	return msg
+}
+
+// ladderPercentLocked is the mean progress over the renditions being
+// encoded.
+func (p *progressReporter) ladderPercentLocked() float64 {
+	if len(p.fractions) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, f := range p.fractions {
+		sum += f
+	}
+	return sum / float64(len(p.fractions)) * 100
+}
+
+// percentLocked is the job-wide progress in percent.
+func (p *progressReporter) percentLocked() float64 {
+	if p.stage == StageEncoding {
+		return p.ladderPercentLocked() * encodingShare / 100
+	}
+	return stagePercent[p.stage]
+}
+
+// etaLocked estimates the seconds left in the encoding stage once at least
+// 1% of the ladder is done. Linear extrapolation from elapsed time works for
+// every encode mode. The later stages have no estimate.
+func (p *progressReporter) etaLocked() (int, bool) {
+	pct := p.ladderPercentLocked()
+	if p.stage != StageEncoding || pct < 1 {
+		return 0, false
+	}
+	elapsed := time.Since(p.started).Seconds()
+	return int(elapsed*(100-pct)/pct + 0.5), true
+}
+
+// snapshot copies the current progress into info for the job API.
+func (p *progressReporter) snapshot(info *JobInfo) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	info.Stage = p.stage
+	info.Percent = math.Round(p.percentLocked()*10) / 10
+	if p.stage != StageEncoding {
+		return
+	}
+	info.Renditions = make(map[string]float64, len(p.fractions))
+	for name, f := range p.fractions {
+		info.Renditions[name] = math.Round(f*1000) / 10
+	}
+	info.FPS = math.Round(p.fps*10) / 10
+	info.Speed = math.Round(p.speed*100) / 100
+	if eta, ok := p.etaLocked(); ok {
+		info.ETASeconds = eta
+	}
+}
+
+// publish sends best-effort: a lost progress event must not fail the job.
+func (p *progressReporter) publish(msg map[string]any) {
+	if p.t.progress == nil {
+		return
+	}
+	if err := p.t.progress.PublishJSON(p.ctx, msg); err != nil {
+		p.t.log.Debugw("progress publish failed", "uploadId", p.evt.UploadID, "err", err)
+	}
+}
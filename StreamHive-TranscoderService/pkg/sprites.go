@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// writeSprites renders the scrub-preview sprite sheets and their WebVTT
+// track and uploads them under thumbnails/<user>/<upload>/. It returns the
+// track URL.
+func (t *Transcoder) writeSprites(ctx context.Context, evt *UploadEvent, inputPath, work string, duration float64, srcW, srcH int) (string, error) {
+	spec := t.opts.Sprites.ForSource(srcW, srcH)
+	dir := filepath.Join(work, "sprites")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	cmd := ffmpeg.BuildSpriteCommand(ctx, inputPath, dir, spec)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("ffmpeg sprites: %w", err)
+	}
+	sheets, err := filepath.Glob(filepath.Join(dir, "sprite_*.jpg"))
+	if err != nil {
+		return "", err
+	}
+	if len(sheets) == 0 {
+		return "", fmt.Errorf("ffmpeg wrote no sprite sheets")
+	}
+	vtt := ffmpeg.BuildSpriteVTT(duration, spec, len(sheets))
+	if err := os.WriteFile(filepath.Join(dir, ffmpeg.SpriteTrackName), []byte(vtt), 0o644); err != nil {
+		return "", err
+	}
+
+	// sheets first, so the track never references a missing image
+	prefix := fmt.Sprintf("thumbnails/%s/%s", evt.UserID, evt.UploadID)
+	for _, p := range sheets {
+		if err := t.store.UploadFile(ctx, p, prefix+"/"+filepath.Base(p), "image/jpeg"); err != nil {
+			return "", fmt.Errorf("upload %s: %w", filepath.Base(p), err)
+		}
+	}
+	trackPath := prefix + "/" + ffmpeg.SpriteTrackName
+	if err := t.store.UploadFile(ctx, filepath.Join(dir, ffmpeg.SpriteTrackName), trackPath, "text/vtt"); err != nil {
+		return "", fmt.Errorf("upload %s: %w", ffmpeg.SpriteTrackName, err)
+	}
+	t.log.Infow("sprites written", "uploadId", evt.UploadID, "sheets", len(sheets), "interval", spec.Interval, "tile", fmt.Sprintf("%dx%d", spec.Width, spec.Height))
+	return t.buildAzureURL(trackPath), nil
+}
@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/streamhive/transcoder/internal/ffmpeg"
+)
+
+// tsStartTime is where ffmpeg's MPEG-TS output starts when the encoded
+// renditions cannot be probed.
+const tsStartTime = 1.4
+
+// Caption is a WebVTT caption track as published on video.transcoded.
+type Caption struct {
+	Language    string `json:"language"` // RFC 5646, "und" when untagged
+	Name        string `json:"name"`
+	Default     bool   `json:"default"`
+	Forced      bool   `json:"forced"`
+	PlaylistURL string `json:"playlistUrl"`
+}
+
+// writeSubtitles converts the text subtitle tracks to segmented WebVTT in
+// outRoot/subtitles/<name>/ and uploads them under base. Cue times are
+// mapped onto the renditions' timeline via startTime.
+func (t *Transcoder) writeSubtitles(ctx context.Context, evt *UploadEvent, inputPath, outRoot, base string, subs []ffmpeg.SubtitleRendition, duration, startTime float64) error {
+	root := filepath.Join(outRoot, ffmpeg.SubtitleDir)
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return err
+	}
+	cmd := ffmpeg.BuildSubtitleExtractCommand(ctx, inputPath, root, subs)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("ffmpeg subtitles: %w", err)
+	}
+	for _, s := range subs {
+		data, err := os.ReadFile(filepath.Join(root, s.Name+".vtt"))
+		if err != nil {
+			return err
+		}
+		cues, err := ffmpeg.ParseVTT(data)
+		if err != nil {
+			return fmt.Errorf("parse %s: %w", s.Name, err)
+		}
+		dir := filepath.Join(root, s.Name)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		segs := ffmpeg.SegmentVTT(cues, duration, ffmpeg.SubtitleSegmentSeconds, startTime)
+		for i, seg := range segs {
+			if err := os.WriteFile(filepath.Join(dir, ffmpeg.SubtitleSegmentName(i)), seg, 0o644); err != nil {
+				return err
+			}
+		}
+		playlist := ffmpeg.BuildSubtitlePlaylist(len(segs), duration, ffmpeg.SubtitleSegmentSeconds)
+		if err := os.WriteFile(filepath.Join(dir, "index.m3u8"), []byte(playlist), 0o644); err != nil {
+			return err
+		}
+		if err := t.store.UploadDir(ctx, dir, fmt.Sprintf("%s/%s/%s", base, ffmpeg.SubtitleDir, s.Name)); err != nil {
+			return fmt.Errorf("upload subtitles %s: %w", s.Name, err)
+		}
+		t.log.Infow("subtitle track written", "uploadId", evt.UploadID, "track", s.Name, "codec", s.Track.Codec, "cues", len(cues), "segments", len(segs))
+	}
+	return nil
+}
+
+// mediaStartTime is the first presentation time of the encoded renditions,
+// which the WebVTT X-TIMESTAMP-MAP must match. Renditions resumed from
+// storage are not on disk, so it falls back to ffmpeg's known offsets.
+func (t *Transcoder) mediaStartTime(ctx context.Context, outRoot string, variants []variant, seg ffmpeg.SegmentType) float64 {
+	for _, v := range variants {
+		playlist := filepath.Join(outRoot, v.Name, "index.m3u8")
+		if _, err := os.Stat(playlist); err != nil {
+			continue
+		}
+		start, err := ffmpeg.ProbeStartTime(ctx, playlist)
+		if err != nil {
+			t.log.Warnw("probe start time failed", "res", v.Name, "err", err)
+			break
+		}
+		return start
+	}
+	if seg == ffmpeg.SegmentFMP4 {
+		return 0
+	}
+	return tsStartTime
+}
+
+// captions lists the written subtitle tracks for the transcoded event.
+func (t *Transcoder) captions(base string, subs []ffmpeg.SubtitleRendition) []Caption {
+	out := make([]Caption, 0, len(subs))
+	for _, s := range subs {
+		lang := ffmpeg.LanguageTag(s.Track.Language)
+		if lang == "" {
+			lang = "und"
+		}
+		out = append(out, Caption{
+			Language:    lang,
+			Name:        s.DisplayName(),
+			Default:     s.Track.Default,
+			Forced:      s.Track.Forced,
+			PlaylistURL: t.buildAzureURL(fmt.Sprintf("%s/%s/%s/index.m3u8", base, ffmpeg.SubtitleDir, s.Name)),
+		})
+	}
+	return out
+}
@@ -49,6 +49,14 @@ func main() {
 	}
 	defer consumer.Close()
 
+	// Publish video.deleted so the transcoder cancels jobs for deleted videos
+	deletedPub, err := consumer.NewPublisher(getEnv("AMQP_DELETED_ROUTING_KEY", "video.deleted"))
+	if err != nil {
+		sugar.Fatalf("Failed to initialize video.deleted publisher: %v", err)
+	}
+	defer deletedPub.Close()
+	videoService.SetDeletedPublisher(deletedPub)
+
 	// Start RabbitMQ consumer
 	go func() {
 		if err := consumer.StartConsuming(videoService); err != nil {
@@ -1,8 +1,10 @@
 package api
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -38,6 +40,10 @@ func SetupRoutes(router *gin.Engine, videoService *services.VideoService, logger
 			videos.GET("/:id", handler.GetVideo)
 			videos.PUT("/:id", handler.UpdateVideo)
 			videos.DELETE("/:id", handler.DeleteVideo)
+			videos.PUT("/:id/poster", handler.SetPoster)
+			videos.GET("/:id/captions", handler.ListCaptions)
+			videos.POST("/:id/captions", handler.AddCaption)
+			videos.DELETE("/:id/captions/:name", handler.DeleteCaption)
 			videos.GET("/search", handler.SearchVideos)
 			videos.GET("/upload/:uploadId", handler.GetVideoByUploadID)
 		}
@@ -178,6 +184,156 @@ func (h *VideoHandler) UpdateVideo(c *gin.Context) {
 	c.JSON(http.StatusOK, video)
 }
 
+// SetPoster handles PUT /api/v1/videos/:id/poster - picks one of the poster candidates
+func (h *VideoHandler) SetPoster(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	userID := c.GetHeader("X-User-ID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID required"})
+		return
+	}
+
+	var req models.PosterSelectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	video, err := h.videoService.SetPoster(uint(id), userID, *req.Index)
+	if err != nil {
+		switch err.Error() {
+		case "video not found":
+			c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+		case "forbidden":
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change the poster"})
+		case "invalid poster index":
+			c.JSON(http.StatusBadRequest, gin.H{"error": "index is not a poster candidate"})
+		default:
+			h.logger.Errorw("Failed to set poster", "error", err, "videoID", id)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set poster"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, video)
+}
+
+// ListCaptions handles GET /api/v1/videos/:id/captions - a private video's
+// tracks are listed only to its owner (X-User-ID)
+func (h *VideoHandler) ListCaptions(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	tracks, err := h.videoService.ListCaptions(uint(id), c.GetHeader("X-User-ID"))
+	if err != nil {
+		h.captionError(c, err, uint(id))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"captions": tracks})
+}
+
+// AddCaption handles POST /api/v1/videos/:id/captions - multipart upload of
+// an SRT or WebVTT file ("file") with its "language" and optional "label"
+// and "default"
+func (h *VideoHandler) AddCaption(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	userID := c.GetHeader("X-User-ID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID required"})
+		return
+	}
+
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Caption file required"})
+		return
+	}
+	if fh.Size > services.MaxCaptionBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Caption file too large"})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read caption file"})
+		return
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, services.MaxCaptionBytes+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot read caption file"})
+		return
+	}
+	isDefault, _ := strconv.ParseBool(c.DefaultPostForm("default", "false"))
+
+	track, err := h.videoService.AddCaption(uint(id), userID, c.PostForm("language"), c.PostForm("label"), isDefault, data)
+	if err != nil {
+		h.captionError(c, err, uint(id))
+		return
+	}
+
+	c.JSON(http.StatusCreated, track)
+}
+
+// DeleteCaption handles DELETE /api/v1/videos/:id/captions/:name
+func (h *VideoHandler) DeleteCaption(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid video ID"})
+		return
+	}
+
+	userID := c.GetHeader("X-User-ID")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User ID required"})
+		return
+	}
+
+	if err := h.videoService.DeleteCaption(uint(id), userID, c.Param("name")); err != nil {
+		h.captionError(c, err, uint(id))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Caption track deleted"})
+}
+
+// captionError maps caption service errors to responses
+func (h *VideoHandler) captionError(c *gin.Context, err error, id uint) {
+	msg := err.Error()
+	switch {
+	case msg == "video not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Video not found"})
+	case msg == "caption not found":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Caption track not found"})
+	case msg == "forbidden":
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can change captions"})
+	case msg == "video not ready":
+		c.JSON(http.StatusConflict, gin.H{"error": "Video is not ready yet"})
+	case msg == "invalid language":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "language must be an RFC 5646 tag such as en or pt-br"})
+	case strings.HasPrefix(msg, "invalid caption file"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+	case msg == "caption storage unavailable":
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Caption storage is not configured"})
+	default:
+		h.logger.Errorw("Caption request failed", "error", err, "videoID", id)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Caption request failed"})
+	}
+}
+
 // DeleteVideo handles DELETE /api/v1/videos/:id - permanently removes video and all files
 func (h *VideoHandler) DeleteVideo(c *gin.Context) {
 	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
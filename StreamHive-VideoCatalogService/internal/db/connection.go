@@ -35,6 +35,7 @@ func NewConnection() (*gorm.DB, error) {
 func RunMigrations(db *gorm.DB) error {
 	return db.AutoMigrate(
 		&models.Video{},
+		&models.DeletedUpload{},
 	)
 }
 
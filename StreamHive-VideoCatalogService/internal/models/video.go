@@ -22,11 +22,27 @@ type Video struct {
 	Category    string      `json:"category"`
 	Status      VideoStatus `json:"status" gorm:"default:'uploaded'"`
 
+	// Set when transcoding failed (status "failed")
+	FailureClass  string `json:"failure_class,omitempty"` // download | probe | ffmpeg | upload | internal
+	FailureReason string `json:"failure_reason,omitempty"`
+
 	// File information
 	OriginalFilename string `json:"original_filename"`
 	RawVideoPath     string `json:"raw_video_path"`
 	HLSMasterURL     string `json:"hls_master_url"`
+	DASHManifestURL  string `json:"dash_manifest_url"`
 	ThumbnailURL     string `json:"thumbnail_url"`
+	// Poster frames extracted by the transcoder, best scored first; the
+	// owner may pick any of them as ThumbnailURL
+	PosterCandidates     string   `json:"-" gorm:"type:text[]"`
+	PosterCandidatesList []string `json:"poster_candidates" gorm:"-"`
+	// WebVTT track of sprite-sheet tiles for seek-bar scrub previews
+	ThumbnailTrackURL string `json:"thumbnail_track_url"`
+	// Short silent clip (MP4 or animated WebP) for hover previews
+	PreviewURL string `json:"preview_url"`
+	// RFC 5646 languages of the WebVTT caption tracks in the HLS master
+	CaptionLanguages     string   `json:"-" gorm:"type:text[]"`
+	CaptionLanguagesList []string `json:"caption_languages" gorm:"-"`
 
 	// Video metadata
 	Duration     float64 `json:"duration"`
@@ -39,12 +55,42 @@ type Video struct {
 	AudioBitrate int     `json:"audio_bitrate"`
 	FrameRate    float64 `json:"frame_rate"`
 
+	// EBU R128 measurement of the default audio track, when normalized
+	Loudness AudioLoudness `json:"loudness" gorm:"embedded;embeddedPrefix:loudness_"`
+
+	// Latest transcoding progress reported by the transcoder
+	Progress TranscodeProgress `json:"progress" gorm:"embedded;embeddedPrefix:progress_"`
+
 	// Timestamps
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 }
 
+// TranscodeProgress is the last video.transcoding.progress update for a video
+type TranscodeProgress struct {
+	Stage      string     `json:"stage"` // downloading | encoding | audio | subtitles | packaging | uploading | thumbnails | done | failed
+	Percent    float64    `json:"percent"`
+	Rendition  string     `json:"rendition,omitempty"` // renditions being encoded, comma separated
+	FPS        float64    `json:"fps,omitempty"`
+	Speed      float64    `json:"speed,omitempty"`
+	ETASeconds int        `json:"eta_seconds,omitempty"`
+	ReportedAt *time.Time `json:"reported_at,omitempty"`
+}
+
+// AudioLoudness is the source loudness of the default audio track as
+// measured by the transcoder's loudnorm pass, and the target it was
+// normalized to. Track is empty when the audio was not normalized.
+type AudioLoudness struct {
+	Track          string  `json:"track,omitempty"`
+	IntegratedLUFS float64 `json:"integrated_lufs"`
+	TruePeakDBTP   float64 `json:"true_peak_dbtp"`
+	LRA            float64 `json:"lra_lu"`
+	ThresholdLUFS  float64 `json:"threshold_lufs"`
+	TargetOffsetLU float64 `json:"target_offset_lu"`
+	TargetLUFS     float64 `json:"target_lufs"`
+}
+
 // VideoStatus represents the processing status of a video
 type VideoStatus string
 
@@ -76,6 +122,23 @@ type VideoUpdateRequest struct {
 	Category    *string  `json:"category,omitempty"`
 }
 
+// PosterSelectRequest represents the request payload for choosing a poster
+// among the video's poster candidates
+type PosterSelectRequest struct {
+	Index *int `json:"index" binding:"required"` // position in poster_candidates
+}
+
+// CaptionTrack is a WebVTT caption track listed in a video's HLS master,
+// either extracted by the transcoder or uploaded as a sidecar file
+type CaptionTrack struct {
+	Name     string `json:"name"`     // directory under subtitles/, e.g. "en" or "en_2"
+	Language string `json:"language"` // RFC 5646
+	Label    string `json:"label"`
+	Default  bool   `json:"default"`
+	Forced   bool   `json:"forced"`
+	URL      string `json:"url"` // media playlist
+}
+
 // VideoListResponse represents the response for listing videos
 type VideoListResponse struct {
 	Videos     []Video `json:"videos"`
@@ -88,19 +151,64 @@ type VideoListResponse struct {
 // TranscodedEvent represents the event received when a video is transcoded
 // Now optionally carries original metadata so catalog can backfill if upload event missed.
 type TranscodedEvent struct {
-	UploadID         string         `json:"uploadId"`
-	UserID           string         `json:"userId"`
-	Title            string         `json:"title,omitempty"`
-	Description      string         `json:"description,omitempty"`
-	Tags             []string       `json:"tags,omitempty"`
-	Category         string         `json:"category,omitempty"`
-	IsPrivate        bool           `json:"isPrivate,omitempty"`
-	OriginalFilename string         `json:"originalFilename,omitempty"`
-	RawVideoPath     string         `json:"rawVideoPath,omitempty"`
-	HLS              HLSInfo        `json:"hls"`
-	ThumbnailURL     string         `json:"thumbnailUrl,omitempty"`
-	Ready            bool           `json:"ready"`
-	Metadata         *VideoMetadata `json:"metadata,omitempty"`
+	UploadID          string         `json:"uploadId"`
+	UserID            string         `json:"userId"`
+	Title             string         `json:"title,omitempty"`
+	Description       string         `json:"description,omitempty"`
+	Tags              []string       `json:"tags,omitempty"`
+	Category          string         `json:"category,omitempty"`
+	IsPrivate         bool           `json:"isPrivate,omitempty"`
+	OriginalFilename  string         `json:"originalFilename,omitempty"`
+	RawVideoPath      string         `json:"rawVideoPath,omitempty"`
+	HLS               HLSInfo        `json:"hls"` // legacy; prefer Manifests.HLS
+	Manifests         ManifestsInfo  `json:"manifests"`
+	ThumbnailURL      string         `json:"thumbnailUrl,omitempty"`
+	PosterCandidates  []string       `json:"posterCandidates,omitempty"`
+	ThumbnailTrackURL string         `json:"thumbnailTrackUrl,omitempty"`
+	PreviewURL        string         `json:"previewUrl,omitempty"`
+	Captions          []CaptionInfo  `json:"captions,omitempty"`
+	Loudness          *LoudnessInfo  `json:"loudness,omitempty"`
+	Ready             bool           `json:"ready"`
+	Metadata          *VideoMetadata `json:"metadata,omitempty"`
+}
+
+// ProgressEvent represents a video.transcoding.progress event from the transcoder
+type ProgressEvent struct {
+	UploadID   string    `json:"uploadId"`
+	UserID     string    `json:"userId"`
+	Stage      string    `json:"stage"`
+	Percent    float64   `json:"percent"`
+	Rendition  string    `json:"rendition,omitempty"`
+	FPS        float64   `json:"fps,omitempty"`
+	Speed      float64   `json:"speed,omitempty"`
+	ETASeconds int       `json:"etaSeconds,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// TranscodeFailedEvent represents a video.transcode_failed event from the transcoder
+type TranscodeFailedEvent struct {
+	UploadID   string    `json:"uploadId"`
+	UserID     string    `json:"userId"`
+	ErrorClass string    `json:"errorClass"`
+	Error      string    `json:"error"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// VideoDeletedEvent is published on video.deleted when a video is deleted, so
+// the transcoder can cancel a job still running for its upload
+type VideoDeletedEvent struct {
+	UploadID  string    `json:"uploadId"`
+	UserID    string    `json:"userId"`
+	VideoID   uint      `json:"videoId"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// DeletedUpload records the upload of a deleted video, so transcoder events
+// arriving after the deletion cannot bring the video back
+type DeletedUpload struct {
+	UploadID  string    `json:"upload_id" gorm:"primarykey"`
+	UserID    string    `json:"user_id"`
+	CreatedAt time.Time `json:"deleted_at"`
 }
 
 // UploadedEvent represents the initial upload event published by UploadService
@@ -119,9 +227,55 @@ type UploadedEvent struct {
 	BlobURL       string   `json:"blobUrl"`
 }
 
+// ManifestsInfo lists the streaming manifests produced by the transcoder
+type ManifestsInfo struct {
+	HLS  *HLSInfo  `json:"hls,omitempty"`
+	DASH *DASHInfo `json:"dash,omitempty"`
+}
+
 // HLSInfo contains HLS-related information
 type HLSInfo struct {
-	MasterURL string `json:"masterUrl"`
+	MasterURL   string `json:"masterUrl"`
+	SegmentType string `json:"segmentType,omitempty"` // mpegts | fmp4
+	Encryption  string `json:"encryption,omitempty"`  // AES-128 when segments are encrypted
+}
+
+// DASHInfo contains MPEG-DASH-related information
+type DASHInfo struct {
+	MPDURL string `json:"mpdUrl"`
+}
+
+// CaptionInfo is a WebVTT caption track listed on video.transcoded
+type CaptionInfo struct {
+	Language    string `json:"language"` // RFC 5646, "und" when untagged
+	Name        string `json:"name"`
+	Default     bool   `json:"default"`
+	Forced      bool   `json:"forced"`
+	PlaylistURL string `json:"playlistUrl"`
+}
+
+// LoudnessInfo is the loudnorm measurement of the default audio track
+// listed on video.transcoded
+type LoudnessInfo struct {
+	Track    string              `json:"track"`
+	Measured LoudnessMeasurement `json:"measured"`
+	Target   LoudnessTarget      `json:"target"`
+}
+
+// LoudnessMeasurement is the EBU R128 loudness of a source audio track
+type LoudnessMeasurement struct {
+	IntegratedLUFS float64 `json:"integratedLufs"`
+	TruePeakDBTP   float64 `json:"truePeakDbtp"`
+	LRA            float64 `json:"lraLu"`
+	ThresholdLUFS  float64 `json:"thresholdLufs"`
+	TargetOffsetLU float64 `json:"targetOffsetLu"`
+}
+
+// LoudnessTarget is the loudness the transcoder normalizes audio to
+type LoudnessTarget struct {
+	IntegratedLUFS float64 `json:"integratedLufs"`
+	TruePeakDBTP   float64 `json:"truePeakDbtp"`
+	LRA            float64 `json:"lraLu"`
 }
 
 // VideoMetadata contains video file metadata
@@ -255,21 +409,27 @@ func (e *UploadedEvent) SanitizeTags() {
 	e.Tags = sanitizedTags
 }
 
-// BeforeCreate hook to convert TagsList to Tags before database insert
+// BeforeCreate hook to convert the list fields before database insert
 func (v *Video) BeforeCreate(tx *gorm.DB) error {
 	v.Tags = convertSliceToPostgresArray(v.TagsList)
+	v.PosterCandidates = convertSliceToPostgresArray(v.PosterCandidatesList)
+	v.CaptionLanguages = convertSliceToPostgresArray(v.CaptionLanguagesList)
 	return nil
 }
 
-// BeforeUpdate hook to convert TagsList to Tags before database update
+// BeforeUpdate hook to convert the list fields before database update
 func (v *Video) BeforeUpdate(tx *gorm.DB) error {
 	v.Tags = convertSliceToPostgresArray(v.TagsList)
+	v.PosterCandidates = convertSliceToPostgresArray(v.PosterCandidatesList)
+	v.CaptionLanguages = convertSliceToPostgresArray(v.CaptionLanguagesList)
 	return nil
 }
 
-// AfterFind hook to convert Tags to TagsList after database query
+// AfterFind hook to convert the array columns after database query
 func (v *Video) AfterFind(tx *gorm.DB) error {
 	v.TagsList = convertPostgresArrayToSlice(v.Tags)
+	v.PosterCandidatesList = convertPostgresArrayToSlice(v.PosterCandidates)
+	v.CaptionLanguagesList = convertPostgresArrayToSlice(v.CaptionLanguages)
 	return nil
 }
 
@@ -288,6 +448,13 @@ func (v Video) MarshalJSON() ([]byte, error) {
 	return json.Marshal(aux)
 }
 
+// PostgresArray returns the value of a list column such as caption_languages
+// for slice. Column updates need it, since the hooks only convert the list
+// fields of a whole-row save.
+func PostgresArray(slice []string) string {
+	return convertSliceToPostgresArray(slice)
+}
+
 // Helper function to convert Go slice to PostgreSQL array string
 func convertSliceToPostgresArray(slice []string) string {
 	if len(slice) == 0 {
@@ -15,11 +15,14 @@ import (
 // Consumer represents a RabbitMQ consumer
 type Consumer struct {
 	conn    *amqp091.Connection
-	channel *amqp091.Channel
+	channel *amqp091.Channel // declares the queues; each consumer loop opens its own
 	logger  *zap.SugaredLogger
 	// routing keys
 	uploadedRoutingKey   string
 	transcodedRoutingKey string
+	progressRoutingKey   string
+	failedRoutingKey     string
+	retry                retryPolicy
 }
 
 // NewConsumer creates a new RabbitMQ consumer
@@ -43,6 +46,9 @@ func NewConsumer(logger *zap.SugaredLogger) (*Consumer, error) {
 		logger:               logger,
 		uploadedRoutingKey:   getEnv("AMQP_UPLOAD_ROUTING_KEY", "video.uploaded"),
 		transcodedRoutingKey: getEnv("AMQP_ROUTING_KEY", "video.transcoded"),
+		progressRoutingKey:   getEnv("AMQP_PROGRESS_ROUTING_KEY", "video.transcoding.progress"),
+		failedRoutingKey:     getEnv("AMQP_FAILED_ROUTING_KEY", "video.transcode_failed"),
+		retry:                retryPolicyFromEnv(),
 	}
 
 	if err := c.setupQueues(); err != nil {
@@ -52,71 +58,115 @@ func NewConsumer(logger *zap.SugaredLogger) (*Consumer, error) {
 	return c, nil
 }
 
-// setupQueues declares exchange and binds two queues (uploaded & transcoded)
+// setupQueues declares exchange and binds the uploaded, transcoded, progress and failed queues.
+// All but the progress queue get retry queues and a DLQ.
 func (c *Consumer) setupQueues() error {
 	exchangeName := getEnv("AMQP_EXCHANGE", "streamhive")
 	transcodedQueue := getEnv("AMQP_QUEUE", "video-catalog.video.transcoded")
 	uploadedQueue := getEnv("AMQP_UPLOAD_QUEUE", "video-catalog.video.uploaded")
+	progressQueue := getEnv("AMQP_PROGRESS_QUEUE", "video-catalog.video.transcoding.progress")
+	failedQueue := getEnv("AMQP_FAILED_QUEUE", "video-catalog.video.transcode_failed")
 
 	if err := c.channel.ExchangeDeclare(exchangeName, "topic", true, false, false, false, nil); err != nil {
 		return fmt.Errorf("declare exchange: %w", err)
 	}
-	if _, err := c.channel.QueueDeclare(transcodedQueue, true, false, false, false, nil); err != nil {
-		return fmt.Errorf("declare transcoded queue: %w", err)
+	if err := c.declareWithRetry(exchangeName, transcodedQueue, c.transcodedRoutingKey); err != nil {
+		return err
 	}
-	if _, err := c.channel.QueueDeclare(uploadedQueue, true, false, false, false, nil); err != nil {
-		return fmt.Errorf("declare uploaded queue: %w", err)
+	if err := c.declareWithRetry(exchangeName, uploadedQueue, c.uploadedRoutingKey); err != nil {
+		return err
 	}
-	if err := c.channel.QueueBind(transcodedQueue, c.transcodedRoutingKey, exchangeName, false, nil); err != nil {
-		return fmt.Errorf("bind transcoded queue: %w", err)
+	if err := c.declareWithRetry(exchangeName, failedQueue, c.failedRoutingKey); err != nil {
+		return err
 	}
-	if err := c.channel.QueueBind(uploadedQueue, c.uploadedRoutingKey, exchangeName, false, nil); err != nil {
-		return fmt.Errorf("bind uploaded queue: %w", err)
+	// progress is only useful while fresh, so let stale updates expire
+	progressArgs := amqp091.Table{"x-message-ttl": int32(60000)}
+	if _, err := c.channel.QueueDeclare(progressQueue, true, false, false, false, progressArgs); err != nil {
+		return fmt.Errorf("declare progress queue: %w", err)
+	}
+	if err := c.channel.QueueBind(progressQueue, c.progressRoutingKey, exchangeName, false, nil); err != nil {
+		return fmt.Errorf("bind progress queue: %w", err)
 	}
 
-	c.logger.Infow("Queue setup completed", "exchange", exchangeName, "transcodedQueue", transcodedQueue, "uploadedQueue", uploadedQueue, "uploadedRoutingKey", c.uploadedRoutingKey, "transcodedRoutingKey", c.transcodedRoutingKey)
+	c.logger.Infow("Queue setup completed", "exchange", exchangeName, "transcodedQueue", transcodedQueue, "uploadedQueue", uploadedQueue, "progressQueue", progressQueue, "failedQueue", failedQueue, "uploadedRoutingKey", c.uploadedRoutingKey, "transcodedRoutingKey", c.transcodedRoutingKey, "progressRoutingKey", c.progressRoutingKey, "failedRoutingKey", c.failedRoutingKey)
 	return nil
 }
 
-// StartConsuming starts consuming both uploaded & transcoded queues
+// StartConsuming starts consuming the uploaded, transcoded, progress and failed queues
 func (c *Consumer) StartConsuming(videoService *services.VideoService) error {
 	transcodedQueue := getEnv("AMQP_QUEUE", "video-catalog.video.transcoded")
 	uploadedQueue := getEnv("AMQP_UPLOAD_QUEUE", "video-catalog.video.uploaded")
-
-	if err := c.channel.Qos(1, 0, false); err != nil {
-		return fmt.Errorf("failed to set QoS: %w", err)
+	progressQueue := getEnv("AMQP_PROGRESS_QUEUE", "video-catalog.video.transcoding.progress")
+	failedQueue := getEnv("AMQP_FAILED_QUEUE", "video-catalog.video.transcode_failed")
+
+	loops := []struct {
+		queue  string
+		retry  bool
+		handle messageHandler
+	}{
+		{uploadedQueue, true, c.handleUploaded},
+		{transcodedQueue, true, c.handleTranscoded},
+		{progressQueue, false, c.handleProgress},
+		{failedQueue, true, c.handleFailed},
+	}
+	done := make(chan error, len(loops))
+	for _, l := range loops {
+		ch, msgs, err := c.consume(l.queue)
+		if err != nil {
+			return err
+		}
+		go c.consumeLoop(ch, msgs, videoService, l.queue, l.retry, l.handle, done)
 	}
 
-	transcodedMsgs, err := c.channel.Consume(transcodedQueue, "", false, false, false, false, nil)
+	c.logger.Infow("Started consuming messages", "transcodedQueue", transcodedQueue, "uploadedQueue", uploadedQueue, "progressQueue", progressQueue, "failedQueue", failedQueue)
+
+	// Block until one loop ends (on channel close)
+	return <-done
+}
+
+// consume opens a channel of its own for queue and starts consuming it. Each
+// loop publishes retries and acks on its channel only, since an AMQP channel
+// is not safe for concurrent use.
+func (c *Consumer) consume(queue string) (*amqp091.Channel, <-chan amqp091.Delivery, error) {
+	ch, err := c.conn.Channel()
 	if err != nil {
-		return fmt.Errorf("consume transcoded: %w", err)
+		return nil, nil, fmt.Errorf("open channel for %s: %w", queue, err)
+	}
+	if err := ch.Qos(1, 0, false); err != nil {
+		ch.Close()
+		return nil, nil, fmt.Errorf("failed to set QoS: %w", err)
 	}
-	uploadedMsgs, err := c.channel.Consume(uploadedQueue, "", false, false, false, false, nil)
+	msgs, err := ch.Consume(queue, "", false, false, false, false, nil)
 	if err != nil {
-		return fmt.Errorf("consume uploaded: %w", err)
+		ch.Close()
+		return nil, nil, fmt.Errorf("consume %s: %w", queue, err)
 	}
-
-	c.logger.Infow("Started consuming messages", "transcodedQueue", transcodedQueue, "uploadedQueue", uploadedQueue)
-
-	// Merge channels using goroutines
-	done := make(chan error, 2)
-	go c.consumeLoop(uploadedMsgs, videoService, true, done)
-	go c.consumeLoop(transcodedMsgs, videoService, false, done)
-	// Block until one loop ends (on channel close)
-	return <-done
+	return ch, msgs, nil
 }
 
-func (c *Consumer) consumeLoop(msgs <-chan amqp091.Delivery, videoService *services.VideoService, isUploaded bool, done chan<- error) {
+type messageHandler func(msg amqp091.Delivery, videoService *services.VideoService) error
+
+// consumeLoop handles deliveries from queue, received on ch. Failures are
+// retried with backoff and then parked in the queue's DLQ; without retry they
+// are dropped.
+func (c *Consumer) consumeLoop(ch *amqp091.Channel, msgs <-chan amqp091.Delivery, videoService *services.VideoService, queue string, retry bool, handle messageHandler, done chan<- error) {
 	for msg := range msgs {
-		var err error
-		if isUploaded {
-			err = c.handleUploaded(msg, videoService)
-		} else {
-			err = c.handleTranscoded(msg, videoService)
-		}
-		if err != nil {
-			c.logger.Errorw("Failed to handle message", "error", err, "uploaded", isUploaded)
-			msg.Nack(false, false)
+		if err := handle(msg, videoService); err != nil {
+			if !retry {
+				c.logger.Warnw("Failed to handle message, dropping", "error", err, "queue", queue)
+				msg.Nack(false, false)
+				continue
+			}
+			attempt := attempts(msg.Headers) + 1
+			parked, perr := c.retryOrPark(ch, queue, msg, err)
+			if perr != nil {
+				c.logger.Errorw("Failed to settle message", "error", perr, "queue", queue)
+			}
+			if parked {
+				c.logger.Errorw("Failed to handle message, parked in DLQ", "error", err, "queue", queue, "attempt", attempt, "dlq", dlqName(queue))
+			} else {
+				c.logger.Warnw("Failed to handle message, retrying", "error", err, "queue", queue, "attempt", attempt, "delay", c.retry.delay(attempt).String())
+			}
 			continue
 		}
 		msg.Ack(false)
@@ -128,7 +178,7 @@ func (c *Consumer) handleUploaded(msg amqp091.Delivery, videoService *services.V
 	c.logger.Debugw("Received upload event", "routingKey", msg.RoutingKey)
 	var event models.UploadedEvent
 	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		return fmt.Errorf("unmarshal uploaded: %w", err)
+		return permanent(fmt.Errorf("unmarshal uploaded: %w", err))
 	}
 	return videoService.HandleUploadedEvent(&event)
 }
@@ -137,11 +187,28 @@ func (c *Consumer) handleTranscoded(msg amqp091.Delivery, videoService *services
 	c.logger.Debugw("Received transcoded event", "routingKey", msg.RoutingKey)
 	var event models.TranscodedEvent
 	if err := json.Unmarshal(msg.Body, &event); err != nil {
-		return fmt.Errorf("unmarshal transcoded: %w", err)
+		return permanent(fmt.Errorf("unmarshal transcoded: %w", err))
 	}
 	return videoService.HandleTranscodedEvent(&event)
 }
 
+func (c *Consumer) handleProgress(msg amqp091.Delivery, videoService *services.VideoService) error {
+	var event models.ProgressEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		return fmt.Errorf("unmarshal progress: %w", err)
+	}
+	return videoService.HandleProgressEvent(&event)
+}
+
+func (c *Consumer) handleFailed(msg amqp091.Delivery, videoService *services.VideoService) error {
+	c.logger.Debugw("Received transcode_failed event", "routingKey", msg.RoutingKey)
+	var event models.TranscodeFailedEvent
+	if err := json.Unmarshal(msg.Body, &event); err != nil {
+		return permanent(fmt.Errorf("unmarshal transcode_failed: %w", err))
+	}
+	return videoService.HandleTranscodeFailedEvent(&event)
+}
+
 // Close closes the consumer connection
 func (c *Consumer) Close() {
 	if c.channel != nil {
@@ -0,0 +1,52 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Publisher publishes JSON events to the exchange under one routing key.
+type Publisher struct {
+	// HTTP handlers publish concurrently and an AMQP channel is not safe
+	// for concurrent use
+	mu         sync.Mutex
+	channel    *amqp091.Channel
+	exchange   string
+	routingKey string
+}
+
+// NewPublisher opens a channel on the consumer's connection for publishing
+// under routingKey.
+func (c *Consumer) NewPublisher(routingKey string) (*Publisher, error) {
+	channel, err := c.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open publisher channel: %w", err)
+	}
+	return &Publisher{channel: channel, exchange: getEnv("AMQP_EXCHANGE", "streamhive"), routingKey: routingKey}, nil
+}
+
+// PublishJSON publishes v as a persistent message.
+func (p *Publisher) PublishJSON(ctx context.Context, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.channel.PublishWithContext(ctx, p.exchange, p.routingKey, false, false, amqp091.Publishing{
+		ContentType:  "application/json",
+		DeliveryMode: amqp091.Persistent,
+		Body:         body,
+	})
+}
+
+// Close closes the publisher channel
+func (p *Publisher) Close() {
+	if p.channel != nil {
+		p.channel.Close()
+	}
+}
@@ -0,0 +1,181 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/rabbitmq/amqp091-go"
+)
+
+// Headers carried on retried and parked messages. They match the
+// transcoder's so its dlq command can list and replay catalog DLQs too.
+const (
+	headerAttempts      = "x-attempts"
+	headerLastError     = "x-last-error"
+	headerOriginalQueue = "x-original-queue"
+	headerParkedAt      = "x-parked-at"
+)
+
+// retryPolicy controls redelivery of failed messages before they are parked
+// in the queue's ".dlq".
+type retryPolicy struct {
+	maxAttempts int // total deliveries, including the first
+	baseDelay   time.Duration
+	maxDelay    time.Duration
+}
+
+func retryPolicyFromEnv() retryPolicy {
+	p := retryPolicy{
+		maxAttempts: getEnvInt("AMQP_MAX_ATTEMPTS", 5),
+		baseDelay:   time.Duration(getEnvInt("AMQP_RETRY_BASE_MS", 2000)) * time.Millisecond,
+		maxDelay:    time.Duration(getEnvInt("AMQP_RETRY_MAX_MS", 300000)) * time.Millisecond,
+	}
+	if p.maxAttempts < 1 {
+		p.maxAttempts = 1
+	}
+	if p.baseDelay <= 0 {
+		p.baseDelay = time.Second
+	}
+	if p.maxDelay < p.baseDelay {
+		p.maxDelay = p.baseDelay
+	}
+	return p
+}
+
+// delay is the wait before retry n (1-based), doubling up to maxDelay.
+func (p retryPolicy) delay(n int) time.Duration {
+	d := p.baseDelay
+	for i := 1; i < n && d < p.maxDelay; i++ {
+		d *= 2
+	}
+	return min(d, p.maxDelay)
+}
+
+func dlqName(queue string) string { return queue + ".dlq" }
+
+// retryQueueName embeds the delay so a policy change declares new queues
+// rather than conflicting with existing x-message-ttl arguments.
+func retryQueueName(queue string, d time.Duration) string {
+	return fmt.Sprintf("%s.retry.%dms", queue, d.Milliseconds())
+}
+
+// declareWithRetry declares queue (bound to routingKey) with a dead-letter
+// exchange feeding its DLQ, plus one TTL retry queue per backoff step that
+// dead-letters back into queue. A queue an older version declared without
+// the dead-letter arguments is migrated first (see migrateQueue).
+func (c *Consumer) declareWithRetry(exchange, queue, routingKey string) error {
+	dlx := exchange + ".dlx"
+	dlq := dlqName(queue)
+	if err := c.channel.ExchangeDeclare(dlx, "direct", true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare dlx: %w", err)
+	}
+	if _, err := c.channel.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("declare %s: %w", dlq, err)
+	}
+	if err := c.channel.QueueBind(dlq, dlq, dlx, false, nil); err != nil {
+		return fmt.Errorf("bind %s: %w", dlq, err)
+	}
+	args := amqp091.Table{"x-dead-letter-exchange": dlx, "x-dead-letter-routing-key": dlq}
+	if _, err := c.channel.QueueDeclare(queue, true, false, false, false, args); err != nil {
+		if !isPreconditionFailed(err) {
+			return fmt.Errorf("declare %s: %w", queue, err)
+		}
+		if err := migrateQueue(c.conn, exchange, queue, routingKey, args); err != nil {
+			return fmt.Errorf("migrate %s to dead-letter arguments: %w", queue, err)
+		}
+		// the refused declaration closed the channel
+		if c.channel, err = c.conn.Channel(); err != nil {
+			return fmt.Errorf("channel: %w", err)
+		}
+	} else if err := resumeMigration(c.conn, exchange, queue, routingKey); err != nil {
+		return fmt.Errorf("resume migration of %s: %w", queue, err)
+	}
+	if err := c.channel.QueueBind(queue, routingKey, exchange, false, nil); err != nil {
+		return fmt.Errorf("bind %s: %w", queue, err)
+	}
+	seen := map[time.Duration]bool{}
+	for n := 1; n < c.retry.maxAttempts; n++ {
+		d := c.retry.delay(n)
+		if seen[d] {
+			continue
+		}
+		seen[d] = true
+		rargs := amqp091.Table{
+			"x-message-ttl":             int32(d.Milliseconds()),
+			"x-dead-letter-exchange":    "",
+			"x-dead-letter-routing-key": queue,
+		}
+		if _, err := c.channel.QueueDeclare(retryQueueName(queue, d), true, false, false, false, rargs); err != nil {
+			return fmt.Errorf("declare retry queue: %w", err)
+		}
+	}
+	return nil
+}
+
+func attempts(h amqp091.Table) int {
+	switch v := h[headerAttempts].(type) {
+	case int32:
+		return int(v)
+	case int64:
+		return int(v)
+	case int:
+		return v
+	}
+	return 0
+}
+
+// errPermanent marks handler errors retrying cannot fix (malformed events).
+var errPermanent = errors.New("permanent")
+
+func permanent(err error) error { return fmt.Errorf("%w: %w", errPermanent, err) }
+
+// retryOrPark republishes a failed delivery to its retry queue, or to the
+// DLQ once attempts are used up, and acks the original on ch, the channel it
+// was delivered on. It reports whether the message was parked.
+func (c *Consumer) retryOrPark(ch *amqp091.Channel, queue string, msg amqp091.Delivery, herr error) (bool, error) {
+	n := attempts(msg.Headers) + 1
+	headers := amqp091.Table{}
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[headerAttempts] = int32(n)
+	headers[headerLastError] = herr.Error()
+
+	parked := n >= c.retry.maxAttempts || errors.Is(herr, errPermanent)
+	target := retryQueueName(queue, c.retry.delay(n))
+	if parked {
+		target = dlqName(queue)
+		headers[headerOriginalQueue] = queue
+		headers[headerParkedAt] = time.Now().UTC().Format(time.RFC3339)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := ch.PublishWithContext(ctx, "", target, false, false, amqp091.Publishing{
+		Headers:      headers,
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp091.Persistent,
+		MessageId:    msg.MessageId,
+		Timestamp:    msg.Timestamp,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		if parked {
+			// broker dead-letters it to the DLQ without our headers
+			return true, errors.Join(err, msg.Nack(false, false))
+		}
+		return false, errors.Join(err, msg.Nack(false, true))
+	}
+	return parked, msg.Ack(false)
+}
+
+func getEnvInt(key string, def int) int {
+	i, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return def
+	}
+	return i
+}
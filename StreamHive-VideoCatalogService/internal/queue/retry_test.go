@@ -0,0 +1,25 @@
+package queue
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := retryPolicy{maxAttempts: 5, baseDelay: 2 * time.Second, maxDelay: 10 * time.Second}
+	tests := []struct {
+		n    int
+		want time.Duration
+	}{
+		{1, 2 * time.Second},
+		{2, 4 * time.Second},
+		{3, 8 * time.Second},
+		{4, 10 * time.Second},
+		{50, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := p.delay(tt.n); got != tt.want {
+			t.Errorf("delay(%d) = %v, want %v", tt.n, got, tt.want)
+		}
+	}
+}
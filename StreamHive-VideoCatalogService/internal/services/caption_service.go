@@ -0,0 +1,387 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+
+	"github.com/streamhive/video-catalog-api/internal/models"
+)
+
+// Layout shared with the transcoder and PlaybackService.
+const (
+	subtitleGroupID = "subs"
+	subtitleDir     = "subtitles"
+)
+
+// MaxCaptionBytes caps the size of an uploaded caption file.
+const MaxCaptionBytes = 2 << 20
+
+// tsStartTicks is where the transcoder's MPEG-TS renditions start (1.4s in
+// 90kHz ticks); fMP4 renditions start at 0.
+const tsStartTicks = 126000
+
+// languageRe accepts RFC 5646 tags of the form the catalog stores: a two or
+// three letter primary language with optional subtags ("en", "pt-br").
+var languageRe = regexp.MustCompile(`^[a-z]{2,3}(-[a-z0-9]{2,8})*$`)
+
+// CaptionStorage is the storage the caption service needs: the HLS master
+// and the caption tracks live in the processed bucket.
+type CaptionStorage interface {
+	Get(ctx context.Context, bucket, key string) ([]byte, error)
+	Put(ctx context.Context, bucket, key string, body io.Reader, contentType string) error
+	DeletePrefix(ctx context.Context, bucket, prefix string) error
+}
+
+// CaptionService manages a ready video's WebVTT caption tracks: sidecar
+// uploads are converted to the transcoder's segmented layout and listed in
+// the HLS master's subtitle group.
+type CaptionService struct {
+	db              *gorm.DB
+	logger          *zap.SugaredLogger
+	storage         CaptionStorage
+	processedBucket string
+	// mu serializes master playlist rewrites within this instance
+	mu sync.Mutex
+}
+
+func NewCaptionService(db *gorm.DB, logger *zap.SugaredLogger, client CaptionStorage) *CaptionService {
+	return &CaptionService{
+		db:              db,
+		logger:          logger,
+		storage:         client,
+		processedBucket: os.Getenv("MINIO_PROCESSED_BUCKET"),
+	}
+}
+
+// captionVideo loads a ready video; owner is checked when userID is set.
+func (s *CaptionService) captionVideo(id uint, userID string) (*models.Video, error) {
+	video, err := s.findVideo(id)
+	if err != nil {
+		return nil, err
+	}
+	if userID != "" && video.UserID != userID {
+		return nil, fmt.Errorf("forbidden")
+	}
+	if err := videoReady(video); err != nil {
+		return nil, err
+	}
+	return video, nil
+}
+
+func (s *CaptionService) findVideo(id uint) (*models.Video, error) {
+	var video models.Video
+	if err := s.db.First(&video, id).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, fmt.Errorf("video not found")
+		}
+		return nil, fmt.Errorf("failed to get video: %w", err)
+	}
+	return &video, nil
+}
+
+func videoReady(video *models.Video) error {
+	if video.Status != models.StatusReady || video.HLSMasterURL == "" {
+		return fmt.Errorf("video not ready")
+	}
+	return nil
+}
+
+// visibleTo reports whether viewerID may see video: private videos are
+// only visible to their owner.
+func visibleTo(video *models.Video, viewerID string) bool {
+	return !video.IsPrivate || video.UserID == viewerID
+}
+
+func hlsPrefix(video *models.Video) string {
+	return fmt.Sprintf("hls/%s/%s", video.UserID, video.UploadID)
+}
+
+// ListCaptions returns the caption tracks in the video's HLS master. The
+// tracks of a private video are only listed to its owner, viewerID; anyone
+// else gets "video not found".
+func (s *CaptionService) ListCaptions(ctx context.Context, id uint, viewerID string) ([]models.CaptionTrack, error) {
+	video, err := s.findVideo(id)
+	if err != nil {
+		return nil, err
+	}
+	if !visibleTo(video, viewerID) {
+		return nil, fmt.Errorf("video not found")
+	}
+	if err := videoReady(video); err != nil {
+		return nil, err
+	}
+	master, err := s.storage.Get(ctx, s.processedBucket, hlsPrefix(video)+"/master.m3u8")
+	if err != nil {
+		return nil, fmt.Errorf("read master playlist: %w", err)
+	}
+	return subtitleTracks(string(master), video.HLSMasterURL), nil
+}
+
+// AddCaption validates an SRT or WebVTT file, stores it as a segmented
+// WebVTT track named after language and lists it in the HLS master. A track
+// for the same language is replaced. Only the owner may add captions.
+func (s *CaptionService) AddCaption(ctx context.Context, id uint, userID, language, label string, isDefault bool, data []byte) (*models.CaptionTrack, error) {
+	language = strings.ToLower(strings.TrimSpace(language))
+	if !languageRe.MatchString(language) {
+		return nil, fmt.Errorf("invalid language")
+	}
+	if len(data) > MaxCaptionBytes {
+		return nil, fmt.Errorf("invalid caption file: larger than %d bytes", MaxCaptionBytes)
+	}
+	cues, err := parseCaptions(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid caption file: %w", err)
+	}
+	video, err := s.captionVideo(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	label = strings.TrimSpace(label)
+	if label == "" {
+		label = language
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base := hlsPrefix(video)
+	masterPath := base + "/master.m3u8"
+	masterData, err := s.storage.Get(ctx, s.processedBucket, masterPath)
+	if err != nil {
+		return nil, fmt.Errorf("read master playlist: %w", err)
+	}
+	master := string(masterData)
+
+	duration := video.Duration
+	if duration <= 0 {
+		duration = cues[len(cues)-1].end
+	}
+	start := s.mediaStartTicks(ctx, base, master)
+	segs := segmentCaptions(cues, duration, start)
+	trackPrefix := fmt.Sprintf("%s/%s/%s", base, subtitleDir, language)
+	// a replaced track may have had more segments
+	if err := s.storage.DeletePrefix(ctx, s.processedBucket, trackPrefix+"/"); err != nil {
+		return nil, fmt.Errorf("delete old caption track: %w", err)
+	}
+	for i, seg := range segs {
+		if err := s.storage.Put(ctx, s.processedBucket, trackPrefix+"/"+captionSegmentName(i), bytes.NewReader(seg), "text/vtt"); err != nil {
+			return nil, fmt.Errorf("write caption segment: %w", err)
+		}
+	}
+	if err := s.storage.Put(ctx, s.processedBucket, trackPrefix+"/index.m3u8", strings.NewReader(captionPlaylist(len(segs), duration)), "application/vnd.apple.mpegurl"); err != nil {
+		return nil, fmt.Errorf("write caption playlist: %w", err)
+	}
+
+	track := models.CaptionTrack{Name: language, Language: language, Label: label, Default: isDefault}
+	master = setSubtitleMedia(master, track)
+	if err := s.saveMaster(ctx, video, masterPath, master); err != nil {
+		return nil, err
+	}
+	track.URL = subtitleURL(video.HLSMasterURL, track.Name)
+	s.logger.Infow("Caption track added", "videoID", id, "language", language, "cues", len(cues), "segments", len(segs))
+	return &track, nil
+}
+
+// DeleteCaption removes the caption track named name (usually its language)
+// from the HLS master and storage. Only the owner may delete captions.
+func (s *CaptionService) DeleteCaption(ctx context.Context, id uint, userID, name string) error {
+	video, err := s.captionVideo(id, userID)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	base := hlsPrefix(video)
+	masterPath := base + "/master.m3u8"
+	masterData, err := s.storage.Get(ctx, s.processedBucket, masterPath)
+	if err != nil {
+		return fmt.Errorf("read master playlist: %w", err)
+	}
+	master, ok := removeSubtitleMedia(string(masterData), name)
+	if !ok {
+		return fmt.Errorf("caption not found")
+	}
+	// drop the track from the master first so players never see a dangling URI
+	if err := s.saveMaster(ctx, video, masterPath, master); err != nil {
+		return err
+	}
+	trackPrefix := fmt.Sprintf("%s/%s/%s/", base, subtitleDir, name)
+	if err := s.storage.DeletePrefix(ctx, s.processedBucket, trackPrefix); err != nil {
+		s.logger.Warnw("Failed to delete caption files (continuing)", "error", err, "prefix", trackPrefix)
+	}
+	s.logger.Infow("Caption track deleted", "videoID", id, "track", name)
+	return nil
+}
+
+// saveMaster writes the master playlist back and refreshes the video's
+// caption languages from it.
+func (s *CaptionService) saveMaster(ctx context.Context, video *models.Video, masterPath, master string) error {
+	if err := s.storage.Put(ctx, s.processedBucket, masterPath, strings.NewReader(master), "application/vnd.apple.mpegurl"); err != nil {
+		return fmt.Errorf("write master playlist: %w", err)
+	}
+	langs := []string{}
+	for _, t := range subtitleTracks(master, video.HLSMasterURL) {
+		if t.Language != "" && !slices.Contains(langs, t.Language) {
+			langs = append(langs, t.Language)
+		}
+	}
+	// only the column, so a concurrent update of the video is not overwritten
+	if err := s.db.Model(video).Update("caption_languages", models.PostgresArray(langs)).Error; err != nil {
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+	video.CaptionLanguagesList = langs
+	return nil
+}
+
+// mediaStartTicks returns where the video's renditions start, which the
+// caption segments' X-TIMESTAMP-MAP must match: fMP4 renditions (their
+// playlist has an EXT-X-MAP) start at 0, MPEG-TS ones at 1.4s.
+func (s *CaptionService) mediaStartTicks(ctx context.Context, base, master string) int64 {
+	for _, line := range strings.Split(master, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		playlist, err := s.storage.Get(ctx, s.processedBucket, base+"/"+line)
+		if err != nil {
+			s.logger.Warnw("Failed to read variant playlist, assuming MPEG-TS", "error", err, "variant", line)
+			break
+		}
+		if strings.Contains(string(playlist), "#EXT-X-MAP:") {
+			return 0
+		}
+		break
+	}
+	return tsStartTicks
+}
+
+// attrRe matches one attribute of an HLS tag's attribute list.
+var attrRe = regexp.MustCompile(`([A-Z0-9-]+)=("[^"]*"|[^,]*)`)
+
+// subtitlesAttrRe matches the SUBTITLES attribute of an EXT-X-STREAM-INF.
+var subtitlesAttrRe = regexp.MustCompile(`,SUBTITLES="[^"]*"`)
+
+func parseAttrs(list string) map[string]string {
+	attrs := map[string]string{}
+	for _, m := range attrRe.FindAllStringSubmatch(list, -1) {
+		attrs[m[1]] = strings.Trim(m[2], `"`)
+	}
+	return attrs
+}
+
+// subtitleTrackName returns the track directory of a SUBTITLES EXT-X-MEDIA
+// line (subtitles/<name>/index.m3u8), or "" for any other line.
+func subtitleTrackName(line string) string {
+	list, ok := strings.CutPrefix(line, "#EXT-X-MEDIA:")
+	if !ok {
+		return ""
+	}
+	attrs := parseAttrs(list)
+	if attrs["TYPE"] != "SUBTITLES" {
+		return ""
+	}
+	uri := strings.TrimSuffix(attrs["URI"], "/index.m3u8")
+	_, name, ok := strings.Cut(uri, subtitleDir+"/")
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// subtitleTracks lists the SUBTITLES renditions of a master playlist.
+func subtitleTracks(master, masterURL string) []models.CaptionTrack {
+	tracks := []models.CaptionTrack{}
+	for _, line := range strings.Split(master, "\n") {
+		name := subtitleTrackName(strings.TrimSpace(line))
+		if name == "" {
+			continue
+		}
+		attrs := parseAttrs(strings.TrimPrefix(strings.TrimSpace(line), "#EXT-X-MEDIA:"))
+		tracks = append(tracks, models.CaptionTrack{
+			Name:     name,
+			Language: attrs["LANGUAGE"],
+			Label:    attrs["NAME"],
+			Default:  attrs["DEFAULT"] == "YES",
+			Forced:   attrs["FORCED"] == "YES",
+			URL:      subtitleURL(masterURL, name),
+		})
+	}
+	return tracks
+}
+
+func subtitleURL(masterURL, name string) string {
+	return fmt.Sprintf("%s/%s/%s/index.m3u8", strings.TrimSuffix(masterURL, "/master.m3u8"), subtitleDir, name)
+}
+
+// setSubtitleMedia lists track in the master's subtitle group, replacing a
+// track of the same name. A default track clears DEFAULT on the others.
+func setSubtitleMedia(master string, track models.CaptionTrack) string {
+	master, _ = removeSubtitleMedia(master, track.Name)
+	label := strings.NewReplacer(`"`, "'", "\n", " ", "\r", " ").Replace(track.Label)
+	media := fmt.Sprintf(`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="%s",NAME="%s",LANGUAGE="%s",DEFAULT=%s,AUTOSELECT=YES,FORCED=NO,URI="%s/%s/index.m3u8"`,
+		subtitleGroupID, label, track.Language, yesNo(track.Default), subtitleDir, track.Name)
+
+	lines := strings.Split(master, "\n")
+	out := make([]string, 0, len(lines)+1)
+	inserted := false
+	for _, line := range lines {
+		if track.Default && subtitleTrackName(line) != "" {
+			line = strings.Replace(line, "DEFAULT=YES", "DEFAULT=NO", 1)
+		}
+		if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+			if !inserted {
+				out = append(out, media)
+				inserted = true
+			}
+			if !subtitlesAttrRe.MatchString(line) {
+				line += fmt.Sprintf(`,SUBTITLES="%s"`, subtitleGroupID)
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+// removeSubtitleMedia drops the subtitle rendition named name from the
+// master, and the variants' SUBTITLES attribute with the last one. It
+// reports whether the track was listed.
+func removeSubtitleMedia(master, name string) (string, bool) {
+	lines := strings.Split(master, "\n")
+	out := make([]string, 0, len(lines))
+	found, remaining := false, 0
+	for _, line := range lines {
+		switch subtitleTrackName(strings.TrimSpace(line)) {
+		case "":
+		case name:
+			found = true
+			continue
+		default:
+			remaining++
+		}
+		out = append(out, line)
+	}
+	if remaining == 0 {
+		for i, line := range out {
+			if strings.HasPrefix(line, "#EXT-X-STREAM-INF:") {
+				out[i] = subtitlesAttrRe.ReplaceAllString(line, "")
+			}
+		}
+	}
+	return strings.Join(out, "\n"), found
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "YES"
+	}
+	return "NO"
+}
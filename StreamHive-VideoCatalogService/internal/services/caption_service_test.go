@@ -0,0 +1,28 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/streamhive/video-catalog-api/internal/models"
+)
+
+func TestVisibleTo(t *testing.T) {
+	tests := []struct {
+		name    string
+		private bool
+		viewer  string
+		want    bool
+	}{
+		{"public, anonymous", false, "", true},
+		{"public, other user", false, "u2", true},
+		{"private, owner", true, "u1", true},
+		{"private, other user", true, "u2", false},
+		{"private, anonymous", true, "", false},
+	}
+	for _, tt := range tests {
+		video := &models.Video{UserID: "u1", IsPrivate: tt.private}
+		if got := visibleTo(video, tt.viewer); got != tt.want {
+			t.Errorf("%s: visibleTo() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -9,14 +9,16 @@ import (
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
 	"github.com/streamhive/video-catalog-api/internal/models"
 )
 
+// StorageClient is the storage the delete service needs.
 type StorageClient interface {
-	DeleteBlob(ctx context.Context, bucket, blobPath string) error
-	DeleteBlobsWithPrefix(ctx context.Context, bucket, prefix string) error
-	BlobExists(ctx context.Context, bucket, blobPath string) (bool, error)
+	Delete(ctx context.Context, bucket, key string) error
+	DeletePrefix(ctx context.Context, bucket, prefix string) error
+	Exists(ctx context.Context, bucket, key string) (bool, error)
 }
 
 // VideoDeleteService handles video deletion including storage cleanup
@@ -26,6 +28,7 @@ type VideoDeleteService struct {
 	storage         StorageClient // Renamed from 'azure'
 	rawBucket       string
 	processedBucket string
+	keysBucket      string // HLS content keys of encrypted videos
 }
 
 func NewVideoDeleteService(db *gorm.DB, logger *zap.SugaredLogger, client StorageClient) *VideoDeleteService {
@@ -35,6 +38,7 @@ func NewVideoDeleteService(db *gorm.DB, logger *zap.SugaredLogger, client Storag
 		storage:         client,
 		rawBucket:       os.Getenv("MINIO_RAW_BUCKET"),
 		processedBucket: os.Getenv("MINIO_PROCESSED_BUCKET"),
+		keysBucket:      nonEmpty(os.Getenv("MINIO_KEYS_BUCKET"), "hls-keys"),
 	}
 }
 
@@ -56,9 +60,25 @@ func (s *VideoDeleteService) DeleteVideoCompletely(ctx context.Context, videoID
 		"userID", video.UserID,
 		"title", video.Title)
 
+	s.cleanupStorage(ctx, &video)
+	s.logger.Infow("Storage cleanup completed", "videoID", videoID)
+
+	// --- Now delete from database ---
+	if err := deleteVideoRow(s.db, &video); err != nil {
+		s.logger.Errorw("Failed to delete video from database", "error", err, "videoID", videoID)
+		return fmt.Errorf("failed to delete video from database: %w", err)
+	}
+
+	s.logger.Infow("Video completely deleted", "videoID", videoID, "uploadID", video.UploadID)
+	return nil
+}
+
+// cleanupStorage removes the video's raw file and everything the transcoder
+// wrote for it. Failures are logged and skipped.
+func (s *VideoDeleteService) cleanupStorage(ctx context.Context, video *models.Video) {
 	// 1. Raw video file from the raw bucket
 	if video.RawVideoPath != "" {
-		if err := s.storage.DeleteBlob(ctx, s.rawBucket, video.RawVideoPath); err != nil {
+		if err := s.storage.Delete(ctx, s.rawBucket, video.RawVideoPath); err != nil {
 			s.logger.Warnw("Failed to delete raw video file (continuing)", "error", err, "path", video.RawVideoPath)
 		} else {
 			s.logger.Infow("Deleted raw video", "path", video.RawVideoPath)
@@ -67,7 +87,7 @@ func (s *VideoDeleteService) DeleteVideoCompletely(ctx context.Context, videoID
 
 	// 2. HLS files from the processed bucket
 	hlsPrefix := fmt.Sprintf("hls/%s/%s/", video.UserID, video.UploadID)
-	if err := s.storage.DeleteBlobsWithPrefix(ctx, s.processedBucket, hlsPrefix); err != nil {
+	if err := s.storage.DeletePrefix(ctx, s.processedBucket, hlsPrefix); err != nil {
 		s.logger.Warnw("Failed to delete HLS files with prefix (continuing)", "error", err, "prefix", hlsPrefix)
 	} else {
 		s.logger.Infow("Deleted HLS files", "prefix", hlsPrefix)
@@ -75,22 +95,39 @@ func (s *VideoDeleteService) DeleteVideoCompletely(ctx context.Context, videoID
 
 	// 3. Thumbnail from the processed bucket
 	thumbnailPath := fmt.Sprintf("thumbnails/%s/%s.jpg", video.UserID, video.UploadID)
-	if err := s.storage.DeleteBlob(ctx, s.processedBucket, thumbnailPath); err != nil {
+	if err := s.storage.Delete(ctx, s.processedBucket, thumbnailPath); err != nil {
 		s.logger.Warnw("Failed to delete thumbnail file (continuing)", "error", err, "path", thumbnailPath)
 	} else {
 		s.logger.Infow("Deleted thumbnail", "path", thumbnailPath)
 	}
 
-	s.logger.Infow("Storage cleanup completed", "videoID", videoID)
+	// 4. Poster candidates and scrub-preview sprites from the processed bucket
+	thumbnailsPrefix := fmt.Sprintf("thumbnails/%s/%s/", video.UserID, video.UploadID)
+	if err := s.storage.DeletePrefix(ctx, s.processedBucket, thumbnailsPrefix); err != nil {
+		s.logger.Warnw("Failed to delete thumbnail files with prefix (continuing)", "error", err, "prefix", thumbnailsPrefix)
+	} else {
+		s.logger.Infow("Deleted thumbnail files", "prefix", thumbnailsPrefix)
+	}
 
-	// --- Now delete from database ---
-	if err := s.db.Unscoped().Delete(&video).Error; err != nil {
-		s.logger.Errorw("Failed to delete video from database", "error", err, "videoID", videoID)
-		return fmt.Errorf("failed to delete video from database: %w", err)
+	// 5. AES-128 content keys of an encrypted video from the keys bucket
+	keysPrefix := fmt.Sprintf("%s/%s/", video.UserID, video.UploadID)
+	if err := s.storage.DeletePrefix(ctx, s.keysBucket, keysPrefix); err != nil {
+		s.logger.Warnw("Failed to delete content keys with prefix (continuing)", "error", err, "prefix", keysPrefix)
+	} else {
+		s.logger.Infow("Deleted content keys", "prefix", keysPrefix)
 	}
+}
 
-	s.logger.Infow("Video completely deleted", "videoID", videoID, "uploadID", video.UploadID)
-	return nil
+// deleteVideoRow removes the video's row and records its upload as deleted,
+// in one transaction so a late transcoder event always finds one of them.
+func deleteVideoRow(db *gorm.DB, video *models.Video) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Delete(video).Error; err != nil {
+			return err
+		}
+		tombstone := &models.DeletedUpload{UploadID: video.UploadID, UserID: video.UserID}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tombstone).Error
+	})
 }
 
 // extractHLSPrefix extracts the HLS storage prefix from the master URL
@@ -3,37 +3,88 @@ package services
 import (
 	"context"
 	"fmt"
+	"slices"
+	"time"
 
 	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"github.com/streamhive/blobstore"
 	"github.com/streamhive/video-catalog-api/internal/models"
 )
 
 // VideoService handles video-related business logic
 type VideoService struct {
-	db            *gorm.DB
-	logger        *zap.SugaredLogger
-	deleteService *VideoDeleteService
+	db             *gorm.DB
+	logger         *zap.SugaredLogger
+	deleteService  *VideoDeleteService
+	captionService *CaptionService
+	// deletedEvents publishes video.deleted; nil disables it
+	deletedEvents EventPublisher
+}
+
+// EventPublisher publishes an event to the message broker
+type EventPublisher interface {
+	PublishJSON(ctx context.Context, v any) error
+}
+
+// SetDeletedPublisher makes DeleteVideo publish video.deleted, so the
+// transcoder cancels a job still running for the video
+func (s *VideoService) SetDeletedPublisher(p EventPublisher) {
+	s.deletedEvents = p
 }
 
 func NewVideoService(db *gorm.DB, logger *zap.SugaredLogger) *VideoService {
 	// --- START: MODIFIED SECTION ---
-	// Initialize S3 client for deletion operations
-	storageClient, err := NewS3ClientAdapterFromEnv()
+	// Initialize the blob store (STORAGE_BACKEND) for deletion operations
+	storageClient, err := blobstore.FromEnv(context.Background())
 	if err != nil {
-		logger.Warnw("Failed to initialize S3 client for video deletion, file cleanup will be disabled", "error", err)
+		logger.Warnw("Failed to initialize blob storage for video deletion, file cleanup will be disabled", "error", err, "backend", blobstore.Backend())
 		// Continue without deletion service - deletion will be database-only
 		return &VideoService{db: db, logger: logger, deleteService: nil}
 	}
 
 	deleteService := NewVideoDeleteService(db, logger, storageClient)
 	// --- END: MODIFIED SECTION ---
-	return &VideoService{db: db, logger: logger, deleteService: deleteService}
+	captionService := NewCaptionService(db, logger, storageClient)
+	return &VideoService{db: db, logger: logger, deleteService: deleteService, captionService: captionService}
+}
+
+// ListCaptions returns the video's caption tracks; a private video's only to
+// its owner
+func (s *VideoService) ListCaptions(id uint, viewerID string) ([]models.CaptionTrack, error) {
+	if s.captionService == nil {
+		return nil, fmt.Errorf("caption storage unavailable")
+	}
+	return s.captionService.ListCaptions(context.Background(), id, viewerID)
+}
+
+// AddCaption stores a sidecar SRT or WebVTT caption track for a ready video
+func (s *VideoService) AddCaption(id uint, userID, language, label string, isDefault bool, data []byte) (*models.CaptionTrack, error) {
+	if s.captionService == nil {
+		return nil, fmt.Errorf("caption storage unavailable")
+	}
+	return s.captionService.AddCaption(context.Background(), id, userID, language, label, isDefault, data)
+}
+
+// DeleteCaption removes a caption track from a video
+func (s *VideoService) DeleteCaption(id uint, userID, name string) error {
+	if s.captionService == nil {
+		return fmt.Errorf("caption storage unavailable")
+	}
+	return s.captionService.DeleteCaption(context.Background(), id, userID, name)
 }
 
 // DeleteVideo completely removes a video and all associated files
 func (s *VideoService) DeleteVideo(id uint) error {
+	video, err := s.GetVideo(id)
+	if err != nil {
+		return err
+	}
+	// Stop a running transcode first so it does not upload into the
+	// prefixes being cleaned up; the transcoder removes what it already wrote
+	s.publishDeleted(video)
+
 	// Use the delete service if available for complete cleanup
 	if s.deleteService != nil {
 		ctx := context.Background()
@@ -44,9 +95,9 @@ func (s *VideoService) DeleteVideo(id uint) error {
 		return nil
 	}
 
-	// Fallback to database-only deletion if S3 client unavailable
+	// Fallback to database-only deletion if blob storage unavailable
 	s.logger.Warnw("Storage client not available - performing database-only deletion", "videoID", id)
-	if err := s.db.Unscoped().Delete(&models.Video{}, id).Error; err != nil {
+	if err := deleteVideoRow(s.db, video); err != nil {
 		s.logger.Errorw("Failed to delete video from database", "error", err, "videoID", id)
 		return fmt.Errorf("failed to delete video: %w", err)
 	}
@@ -54,6 +105,40 @@ func (s *VideoService) DeleteVideo(id uint) error {
 	return nil
 }
 
+// publishDeleted announces the video's deletion. It is best-effort: without
+// it a running transcode finishes, but its result is still ignored.
+func (s *VideoService) publishDeleted(video *models.Video) {
+	if s.deletedEvents == nil {
+		return
+	}
+	event := models.VideoDeletedEvent{
+		UploadID:  video.UploadID,
+		UserID:    video.UserID,
+		VideoID:   video.ID,
+		Timestamp: time.Now().UTC(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.deletedEvents.PublishJSON(ctx, event); err != nil {
+		s.logger.Warnw("Failed to publish video.deleted", "error", err, "videoID", video.ID, "uploadID", video.UploadID)
+		return
+	}
+	s.logger.Infow("Published video.deleted", "videoID", video.ID, "uploadID", video.UploadID)
+}
+
+// uploadDeleted reports whether the upload's video has been deleted, in
+// which case events for it are ignored
+func (s *VideoService) uploadDeleted(uploadID, eventType string) (bool, error) {
+	var n int64
+	if err := s.db.Model(&models.DeletedUpload{}).Where("upload_id = ?", uploadID).Count(&n).Error; err != nil {
+		return false, fmt.Errorf("check deleted upload: %w", err)
+	}
+	if n > 0 {
+		s.logger.Infow("Ignoring event for deleted video", "event", eventType, "uploadID", uploadID)
+	}
+	return n > 0, nil
+}
+
 // CreateVideo creates a new video record (manual creation path)
 func (s *VideoService) CreateVideo(userID string, req *models.VideoCreateRequest) (*models.Video, error) {
 	if req.UploadID == "" {
@@ -139,6 +224,30 @@ func (s *VideoService) UpdateVideo(id uint, req *models.VideoUpdateRequest) (*mo
 	return video, nil
 }
 
+// SetPoster makes the poster candidate at index the video's thumbnail.
+// Only the video's owner may change it.
+func (s *VideoService) SetPoster(id uint, userID string, index int) (*models.Video, error) {
+	video, err := s.GetVideo(id)
+	if err != nil {
+		return nil, err
+	}
+	if video.UserID != userID {
+		return nil, fmt.Errorf("forbidden")
+	}
+	if index < 0 || index >= len(video.PosterCandidatesList) {
+		return nil, fmt.Errorf("invalid poster index")
+	}
+
+	video.ThumbnailURL = video.PosterCandidatesList[index]
+	if err := s.db.Save(video).Error; err != nil {
+		s.logger.Errorw("Failed to set poster", "error", err, "videoID", id)
+		return nil, fmt.Errorf("failed to update video: %w", err)
+	}
+
+	s.logger.Infow("Poster changed", "videoID", id, "index", index)
+	return video, nil
+}
+
 
 
 // ListVideos retrieves a paginated list of videos for a user
@@ -192,6 +301,9 @@ func (s *VideoService) HandleUploadedEvent(event *models.UploadedEvent) error {
 	if event.UploadID == "" || event.UserID == "" {
 		return fmt.Errorf("invalid uploaded event")
 	}
+	if deleted, err := s.uploadDeleted(event.UploadID, "video.uploaded"); err != nil || deleted {
+		return err
+	}
 
 	var existing models.Video
 	err := s.db.Where("upload_id = ?", event.UploadID).First(&existing).Error
@@ -267,8 +379,19 @@ func (s *VideoService) HandleUploadedEvent(event *models.UploadedEvent) error {
 	return nil
 }
 
-// HandleTranscodedEvent processes video.transcoded events
+// HandleTranscodedEvent processes video.transcoded events. Events for
+// deleted videos are dropped and any output they left behind is removed.
 func (s *VideoService) HandleTranscodedEvent(event *models.TranscodedEvent) error {
+	if deleted, err := s.uploadDeleted(event.UploadID, "video.transcoded"); err != nil {
+		return err
+	} else if deleted {
+		// the job may have finished uploading after the video's files were removed
+		if s.deleteService != nil && event.UserID != "" {
+			s.deleteService.cleanupStorage(context.Background(), &models.Video{UploadID: event.UploadID, UserID: event.UserID})
+		}
+		return nil
+	}
+
 	video, err := s.GetVideoByUploadID(event.UploadID)
 	if err != nil {
 		video = &models.Video{
@@ -315,13 +438,57 @@ func (s *VideoService) HandleTranscodedEvent(event *models.TranscodedEvent) erro
 	}
 
 	video.HLSMasterURL = event.HLS.MasterURL
+	if m := event.Manifests.HLS; m != nil && m.MasterURL != "" {
+		video.HLSMasterURL = m.MasterURL
+	}
+	if m := event.Manifests.DASH; m != nil {
+		video.DASHManifestURL = m.MPDURL
+	}
 	video.Status = models.StatusReady
+	video.FailureClass, video.FailureReason = "", ""
+	now := time.Now()
+	video.Progress = models.TranscodeProgress{Stage: "done", Percent: 100, ReportedAt: &now}
 
 	// Set thumbnail URL if provided
-	if event.ThumbnailURL != "" {
+	if event.ThumbnailURL != "" && len(event.PosterCandidates) == 0 {
 		video.ThumbnailURL = event.ThumbnailURL
 		updated = true
 	}
+	if len(event.PosterCandidates) > 0 {
+		// keep an owner's choice across re-transcodes when it is still offered
+		if !slices.Contains(event.PosterCandidates, video.ThumbnailURL) {
+			video.ThumbnailURL = event.PosterCandidates[0]
+		}
+		video.PosterCandidatesList = event.PosterCandidates
+	}
+	if event.ThumbnailTrackURL != "" {
+		video.ThumbnailTrackURL = event.ThumbnailTrackURL
+	}
+	if event.PreviewURL != "" {
+		video.PreviewURL = event.PreviewURL
+	}
+	if event.Captions != nil {
+		langs := []string{}
+		for _, c := range event.Captions {
+			if c.Language != "" && !slices.Contains(langs, c.Language) {
+				langs = append(langs, c.Language)
+			}
+		}
+		video.CaptionLanguagesList = langs
+	}
+	// describes the audio just published, so cleared when it was not normalized
+	video.Loudness = models.AudioLoudness{}
+	if l := event.Loudness; l != nil {
+		video.Loudness = models.AudioLoudness{
+			Track:          l.Track,
+			IntegratedLUFS: l.Measured.IntegratedLUFS,
+			TruePeakDBTP:   l.Measured.TruePeakDBTP,
+			LRA:            l.Measured.LRA,
+			ThresholdLUFS:  l.Measured.ThresholdLUFS,
+			TargetOffsetLU: l.Measured.TargetOffsetLU,
+			TargetLUFS:     l.Target.IntegratedLUFS,
+		}
+	}
 
 	if event.Metadata != nil {
 		video.Duration = event.Metadata.Duration
@@ -349,6 +516,80 @@ func (s *VideoService) HandleTranscodedEvent(event *models.TranscodedEvent) erro
 	return nil
 }
 
+// HandleTranscodeFailedEvent marks a video as failed and keeps the reason
+// for its owner. A video that is already ready is left alone.
+func (s *VideoService) HandleTranscodeFailedEvent(event *models.TranscodeFailedEvent) error {
+	if event.UploadID == "" {
+		return fmt.Errorf("invalid transcode_failed event")
+	}
+	if deleted, err := s.uploadDeleted(event.UploadID, "video.transcode_failed"); err != nil || deleted {
+		return err
+	}
+	video, err := s.GetVideoByUploadID(event.UploadID)
+	if err != nil {
+		if err.Error() != "video not found" {
+			return err
+		}
+		video = &models.Video{
+			UploadID: event.UploadID,
+			UserID:   event.UserID,
+			Title:    "Untitled Video",
+		}
+	}
+	if video.Status == models.StatusReady {
+		s.logger.Warnw("Ignoring transcode_failed for ready video", "uploadID", event.UploadID, "class", event.ErrorClass)
+		return nil
+	}
+
+	now := time.Now()
+	video.Status = models.StatusFailed
+	video.FailureClass = event.ErrorClass
+	video.FailureReason = event.Error
+	video.Progress.Stage = "failed"
+	video.Progress.ETASeconds = 0
+	video.Progress.ReportedAt = &now
+	if err := s.db.Save(video).Error; err != nil {
+		s.logger.Errorw("Failed to mark video failed", "error", err, "uploadID", event.UploadID)
+		return fmt.Errorf("failed to update video: %w", err)
+	}
+	s.logger.Infow("Video marked failed from transcode_failed event", "uploadID", event.UploadID, "videoID", video.ID, "class", event.ErrorClass)
+	return nil
+}
+
+// HandleProgressEvent records the latest video.transcoding.progress update.
+// Progress for videos that are no longer processing, or older than what is
+// stored, is dropped so late events cannot overwrite a finished job.
+func (s *VideoService) HandleProgressEvent(event *models.ProgressEvent) error {
+	if event.UploadID == "" {
+		return fmt.Errorf("invalid progress event")
+	}
+	reported := event.Timestamp
+	if reported.IsZero() {
+		reported = time.Now()
+	}
+
+	res := s.db.Model(&models.Video{}).
+		Where("upload_id = ? AND status IN ?", event.UploadID, []models.VideoStatus{models.StatusUploaded, models.StatusProcessing}).
+		Where("progress_reported_at IS NULL OR progress_reported_at <= ?", reported).
+		Updates(map[string]interface{}{
+			"status":               models.StatusProcessing,
+			"progress_stage":       event.Stage,
+			"progress_percent":     event.Percent,
+			"progress_rendition":   event.Rendition,
+			"progress_fps":         event.FPS,
+			"progress_speed":       event.Speed,
+			"progress_eta_seconds": event.ETASeconds,
+			"progress_reported_at": reported,
+		})
+	if res.Error != nil {
+		return fmt.Errorf("failed to update progress: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		s.logger.Debugw("Ignored progress event", "uploadID", event.UploadID, "stage", event.Stage)
+	}
+	return nil
+}
+
 func nonEmpty(v, def string) string {
 	if v == "" {
 		return def
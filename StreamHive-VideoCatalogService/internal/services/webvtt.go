@@ -0,0 +1,185 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// captionSegmentSeconds matches the transcoder's HLS segment length, so
+// sidecar tracks are laid out like extracted ones.
+const captionSegmentSeconds = 6
+
+// captionCue is one timed caption.
+type captionCue struct {
+	start, end float64 // seconds
+	settings   string  // WebVTT cue settings; SRT has none
+	text       string
+}
+
+var (
+	// srtFontRe matches SRT <font> tags, which WebVTT does not support.
+	srtFontRe = regexp.MustCompile(`(?i)</?font[^>]*>`)
+	// assOverrideRe matches ASS override blocks some SRT files carry ({\an8}).
+	assOverrideRe = regexp.MustCompile(`\{\\[^}]*\}`)
+)
+
+// parseCaptions reads an SRT or WebVTT file. WebVTT is recognized by its
+// header; anything else is parsed as SRT and its markup reduced to what
+// WebVTT supports.
+func parseCaptions(data []byte) ([]captionCue, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return nil, fmt.Errorf("caption file is not UTF-8")
+	}
+	isVTT := bytes.HasPrefix(data, []byte("WEBVTT"))
+
+	var cues []captionCue
+	var block []string
+	flush := func() error {
+		defer func() { block = block[:0] }()
+		for i, line := range block {
+			if !strings.Contains(line, "-->") {
+				continue
+			}
+			c, err := parseCueTiming(line)
+			if err != nil {
+				return fmt.Errorf("cue %d: %w", len(cues)+1, err)
+			}
+			text := strings.Join(block[i+1:], "\n")
+			if !isVTT {
+				text = assOverrideRe.ReplaceAllString(srtFontRe.ReplaceAllString(text, ""), "")
+				c.settings = ""
+			}
+			if strings.TrimSpace(text) == "" {
+				return nil
+			}
+			c.text = text
+			cues = append(cues, c)
+			return nil
+		}
+		return nil
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		block = append(block, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	if len(cues) == 0 {
+		return nil, fmt.Errorf("no cues found")
+	}
+	return cues, nil
+}
+
+func parseCueTiming(line string) (captionCue, error) {
+	var c captionCue
+	from, rest, _ := strings.Cut(line, "-->")
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return c, fmt.Errorf("bad timing line %q", line)
+	}
+	var err error
+	if c.start, err = parseCueTimestamp(strings.TrimSpace(from)); err != nil {
+		return c, err
+	}
+	if c.end, err = parseCueTimestamp(fields[0]); err != nil {
+		return c, err
+	}
+	if c.end <= c.start {
+		return c, fmt.Errorf("cue ends before it starts: %q", line)
+	}
+	c.settings = strings.Join(fields[1:], " ")
+	return c, nil
+}
+
+// parseCueTimestamp accepts hh:mm:ss.ttt and mm:ss.ttt with a dot (WebVTT)
+// or comma (SRT) before the milliseconds.
+func parseCueTimestamp(s string) (float64, error) {
+	parts := strings.Split(strings.Replace(s, ",", ".", 1), ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("bad timestamp %q", s)
+	}
+	var sec float64
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(p, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("bad timestamp %q", s)
+		}
+		sec = sec*60 + v
+	}
+	return sec, nil
+}
+
+func formatCueTimestamp(sec float64) string {
+	ms := int64(math.Round(sec * 1000))
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
+
+// segmentCaptions splits cues into WebVTT segments covering duration, in
+// the layout the transcoder writes: a cue spanning a boundary is repeated in
+// every segment it overlaps, and each segment maps cue time 0 to the media's
+// first presentation time (90kHz ticks).
+func segmentCaptions(cues []captionCue, duration float64, mpegts int64) [][]byte {
+	n := max(int(math.Ceil(duration/captionSegmentSeconds)), 1)
+	header := fmt.Sprintf("WEBVTT\nX-TIMESTAMP-MAP=MPEGTS:%d,LOCAL:00:00:00.000\n", mpegts)
+	segs := make([][]byte, n)
+	for i := range segs {
+		from, to := float64(i*captionSegmentSeconds), float64((i+1)*captionSegmentSeconds)
+		var b strings.Builder
+		b.WriteString(header)
+		for _, c := range cues {
+			if c.end <= from || (c.start >= to && i < n-1) {
+				continue
+			}
+			fmt.Fprintf(&b, "\n%s --> %s", formatCueTimestamp(c.start), formatCueTimestamp(c.end))
+			if c.settings != "" {
+				b.WriteString(" " + c.settings)
+			}
+			b.WriteString("\n" + c.text + "\n")
+		}
+		segs[i] = []byte(b.String())
+	}
+	return segs
+}
+
+func captionSegmentName(n int) string {
+	return fmt.Sprintf("seg_%05d.vtt", n)
+}
+
+// captionPlaylist renders the media playlist for segments WebVTT segments
+// covering duration.
+func captionPlaylist(segments int, duration float64) string {
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	b.WriteString("#EXT-X-VERSION:3\n")
+	fmt.Fprintf(&b, "#EXT-X-TARGETDURATION:%d\n", captionSegmentSeconds)
+	b.WriteString("#EXT-X-MEDIA-SEQUENCE:0\n")
+	b.WriteString("#EXT-X-PLAYLIST-TYPE:VOD\n")
+	for i := 0; i < segments; i++ {
+		d := math.Min(captionSegmentSeconds, duration-float64(i*captionSegmentSeconds))
+		if d <= 0 {
+			d = captionSegmentSeconds
+		}
+		fmt.Fprintf(&b, "#EXTINF:%.3f,\n%s\n", d, captionSegmentName(i))
+	}
+	b.WriteString("#EXT-X-ENDLIST\n")
+	return b.String()
+}
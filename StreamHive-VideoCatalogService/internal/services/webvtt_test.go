@@ -0,0 +1,112 @@
+package services
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCaptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		want    []captionCue
+		wantErr bool
+	}{
+		{
+			name: "srt",
+			in:   "1\r\n00:00:01,000 --> 00:00:02,500\r\nHello\r\n\r\n2\r\n00:00:03,000 --> 00:00:04,000\r\nTwo\r\nlines\r\n",
+			want: []captionCue{
+				{start: 1, end: 2.5, text: "Hello"},
+				{start: 3, end: 4, text: "Two\nlines"},
+			},
+		},
+		{
+			name: "srt markup reduced to webvtt",
+			in:   "\xef\xbb\xbf1\n00:00:01,000 --> 00:00:02,000 X1:10\n{\\an8}<font color=\"red\"><i>Hi</i></font>\n",
+			want: []captionCue{{start: 1, end: 2, text: "<i>Hi</i>"}},
+		},
+		{
+			name: "webvtt keeps settings",
+			in:   "WEBVTT\n\nintro\n00:01.000 --> 00:02.000 line:10% align:start\n<b>Hi</b>\n",
+			want: []captionCue{{start: 1, end: 2, settings: "line:10% align:start", text: "<b>Hi</b>"}},
+		},
+		{
+			name: "empty cue skipped",
+			in:   "1\n00:00:01,000 --> 00:00:02,000\n{\\an8}\n\n2\n00:00:03,000 --> 00:00:04,000\nKept\n",
+			want: []captionCue{{start: 3, end: 4, text: "Kept"}},
+		},
+		{name: "ends before start", in: "1\n00:00:02,000 --> 00:00:01,000\nx\n", wantErr: true},
+		{name: "bad timestamp", in: "1\n00:00:aa,000 --> 00:00:01,000\nx\n", wantErr: true},
+		{name: "no cues", in: "WEBVTT\n\nNOTE nothing here\n", wantErr: true},
+		{name: "not utf-8", in: "1\n00:00:01,000 --> 00:00:02,000\n\xff\xfe\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCaptions([]byte(tt.in))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCaptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCaptions() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCueTimestamps(t *testing.T) {
+	tests := []struct {
+		in   string
+		sec  float64
+		want string
+	}{
+		{"00:00:01,500", 1.5, "00:00:01.500"},
+		{"01:02:03.004", 3723.004, "01:02:03.004"},
+		{"02:03.250", 123.25, "00:02:03.250"},
+	}
+	for _, tt := range tests {
+		sec, err := parseCueTimestamp(tt.in)
+		if err != nil || sec != tt.sec {
+			t.Errorf("parseCueTimestamp(%q) = %v, %v, want %v", tt.in, sec, err, tt.sec)
+			continue
+		}
+		if got := formatCueTimestamp(sec); got != tt.want {
+			t.Errorf("formatCueTimestamp(%v) = %q, want %q", sec, got, tt.want)
+		}
+	}
+}
+
+func TestSegmentCaptions(t *testing.T) {
+	cues := []captionCue{
+		{start: 1, end: 2, text: "first"},
+		{start: 5, end: 7, settings: "align:start", text: "spans"},
+		{start: 13, end: 14, text: "last"},
+	}
+	segs := segmentCaptions(cues, 13, 126000)
+	tests := []struct {
+		seg     int
+		want    []string
+		notWant []string
+	}{
+		{0, []string{"MPEGTS:126000", "00:00:01.000 --> 00:00:02.000\nfirst", "00:00:05.000 --> 00:00:07.000 align:start\nspans"}, []string{"last"}},
+		{1, []string{"spans"}, []string{"first", "last"}},
+		// cues past the duration land in the final segment
+		{2, []string{"last"}, []string{"first", "spans"}},
+	}
+	if len(segs) != len(tests) {
+		t.Fatalf("got %d segments, want %d", len(segs), len(tests))
+	}
+	for _, tt := range tests {
+		s := string(segs[tt.seg])
+		for _, w := range tt.want {
+			if !strings.Contains(s, w) {
+				t.Errorf("segment %d lacks %q:\n%s", tt.seg, w, s)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(s, w) {
+				t.Errorf("segment %d contains %q:\n%s", tt.seg, w, s)
+			}
+		}
+	}
+}
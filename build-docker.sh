@@ -46,6 +46,8 @@ REPOS=(
 )
 
 BASE_DIR=$(pwd)
+# The Go services share StreamHive-Blobstore through a replace directive, so
+# their images are built with the repository root as context.
 
 # === Build Frontend ===
 print_build "Building Frontend Service..."
@@ -98,7 +100,7 @@ CGO_ENABLED=0 GOOS=linux go build -a -installsuffix cgo -o bin/transcoder ./cmd/
     print_error "Failed to build transcoder binary"
     exit 1
 }
-docker build -t "${IMAGE_REPOSITORY}/streamhive-transcoder-service:${BUILD_TAG}" . || {
+docker build -f Dockerfile -t "${IMAGE_REPOSITORY}/streamhive-transcoder-service:${BUILD_TAG}" "$BASE_DIR" || {
     print_error "Failed to build transcoder service Docker image"
     exit 1
 }
@@ -115,7 +117,7 @@ CGO_ENABLED=0 GOOS=linux go build -a -installsuffix cgo -o bin/video-catalog ./c
     print_error "Failed to build video catalog binary"
     exit 1
 }
-docker build -t "${IMAGE_REPOSITORY}/streamhive-video-catalog-service:${BUILD_TAG}" . || {
+docker build -f Dockerfile -t "${IMAGE_REPOSITORY}/streamhive-video-catalog-service:${BUILD_TAG}" "$BASE_DIR" || {
     print_error "Failed to build video catalog service Docker image"
     exit 1
 }
@@ -140,7 +142,7 @@ CGO_ENABLED=0 GOOS=linux go build -a -installsuffix cgo -o bin/playback ./cmd/pl
     print_error "Failed to build playback binary"
     exit 1
 }
-docker build -t "${IMAGE_REPOSITORY}/streamhive-playback-service:${BUILD_TAG}" . || {
+docker build -f Dockerfile -t "${IMAGE_REPOSITORY}/streamhive-playback-service:${BUILD_TAG}" "$BASE_DIR" || {
     print_error "Failed to build playback service Docker image"
     exit 1
 }